@@ -0,0 +1,60 @@
+package sevenzip
+
+import (
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// AferoFs returns a read-only [afero.Fs] view of the archive, for
+// applications that use afero to combine it with other filesystems. Names
+// may be rooted, so "/dir/file" and "dir/file" refer to the same entry. Any
+// attempt to modify the filesystem fails with [fs.ErrPermission].
+func (z *Reader) AferoFs() afero.Fs {
+	return &aferoFs{afero.FromIOFS{FS: z}, z}
+}
+
+type aferoFs struct {
+	afero.FromIOFS
+	z *Reader
+}
+
+var _ afero.Lstater = new(aferoFs)
+
+// unroot maps a name that may be rooted or unclean, as is common with afero
+// and net/http, to a valid fs.FS name.
+func unroot(name string) string {
+	name = path.Clean("/" + filepath.ToSlash(name))[1:]
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
+func (a *aferoFs) Name() string { return "sevenzip" }
+
+func (a *aferoFs) Open(name string) (afero.File, error) {
+	return a.FromIOFS.Open(unroot(name)) //nolint:wrapcheck
+}
+
+func (a *aferoFs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrPermission}
+	}
+
+	return a.Open(name)
+}
+
+func (a *aferoFs) Stat(name string) (iofs.FileInfo, error) {
+	return a.z.Stat(unroot(name))
+}
+
+func (a *aferoFs) LstatIfPossible(name string) (iofs.FileInfo, bool, error) {
+	fi, err := a.z.Lstat(unroot(name))
+
+	return fi, true, err
+}
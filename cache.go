@@ -0,0 +1,104 @@
+package sevenzip
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"github.com/bodgit/sevenzip/internal/util"
+)
+
+// cacheable reports whether the stream containing f can be held in the cache.
+func (z *Reader) cacheable(f *File) bool {
+	return z.cache != nil && z.si.unpackInfo.folder[f.folder].unpackSize() <= uint64(z.cache.Size()) //nolint:gosec
+}
+
+// openCached returns a reader for f from the decoded contents of its stream,
+// decoding the stream and adding it to the cache first if necessary.
+func (f *File) openCached() (*sectionReader, error) {
+	b, err := f.zip.cachedStream(f)
+	if err != nil {
+		return nil, err
+	}
+
+	end := f.offset + int64(f.UncompressedSize) //nolint:gosec
+	if end > int64(len(b)) {
+		return nil, newReadError(f, f.folder, false, io.ErrUnexpectedEOF)
+	}
+
+	// Without a checksum for the whole stream, the wrong password can only
+	// be detected with the checksum of the file
+	if f.zip.si.unpackInfo.folder[f.folder].encrypted() && f.CRC32 != 0 {
+		if crc32.ChecksumIEEE(b[f.offset:end]) != f.CRC32 {
+			return nil, newReadError(f, f.folder, true, ErrWrongPassword)
+		}
+	}
+
+	return &sectionReader{
+		SectionReader: io.NewSectionReader(bytes.NewReader(b), f.offset, int64(f.UncompressedSize)), //nolint:gosec
+		f:             f,
+	}, nil
+}
+
+func (z *Reader) cachedStream(f *File) ([]byte, error) {
+	if b, ok := z.cache.Get(f.folder); ok {
+		z.stats.cacheHits.Add(1)
+
+		return b, nil
+	}
+
+	// Only decode the stream once if several files in it are opened at
+	// the same time
+	v, err, _ := z.cacheGroup.Do(strconv.Itoa(f.folder), func() (interface{}, error) {
+		if b, ok := z.cache.Get(f.folder); ok {
+			z.stats.cacheHits.Add(1)
+
+			return b, nil
+		}
+
+		z.stats.cacheMisses.Add(1)
+
+		b, err := z.decodeStream(f)
+		if err != nil {
+			return nil, err
+		}
+
+		z.cache.Put(f.folder, b)
+
+		return b, nil
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return v.([]byte), nil //nolint:forcetypeassert
+}
+
+func (z *Reader) decodeStream(f *File) (_ []byte, err error) {
+	fr, crc, encrypted, err := z.folderReader(z.si, f.folder, f)
+	if err != nil {
+		return nil, newReadError(f, f.folder, encrypted, err)
+	}
+
+	defer func() {
+		err = errors.Join(err, fr.Close())
+	}()
+
+	b := make([]byte, fr.Size())
+
+	if _, err = io.ReadFull(fr, b); err != nil {
+		return nil, newReadError(f, f.folder, fr.hasEncryption, wrongPassword(err, fr.hasEncryption))
+	}
+
+	if crc != 0 && !util.CRC32Equal(fr.Checksum(), crc) {
+		if fr.hasEncryption {
+			return nil, newReadError(f, f.folder, true, ErrWrongPassword)
+		}
+
+		return nil, newReadError(f, f.folder, false, errChecksum)
+	}
+
+	return b, nil
+}
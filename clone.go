@@ -0,0 +1,101 @@
+package sevenzip
+
+import (
+	"bytes"
+)
+
+// Clone returns a new [Reader] for the same archive that shares the parsed
+// header with z but has its own pool of partially-read streams, statistics
+// and [File] values, so that several goroutines can each extract from their
+// own clone without re-parsing the header or contending with each other. A
+// [WithSharedPool] or [WithCache] option is still shared with z.
+//
+// The clone reads from the same underlying [io.ReaderAt] so if z is a
+// [ReadCloser] the clone can't be used once it has been closed. Calling
+// [Reader.Wipe] on the clone doesn't affect z, or vice versa.
+func (z *Reader) Clone() (*Reader, error) {
+	c := &Reader{
+		r:     z.r,
+		start: z.start,
+		end:   z.end,
+		major: z.major,
+		minor: z.minor,
+		si:    z.si,
+		p:     z.p,
+		pb:    bytes.Clone(z.pb),
+
+		passwordCallback: z.passwordCallback,
+		headerEncrypted:  z.headerEncrypted,
+		headerCompressed: z.headerCompressed,
+		headerSize:       z.headerSize,
+
+		caseInsensitive: z.caseInsensitive,
+		duplicates:      z.duplicates,
+		nameMapping:     z.nameMapping,
+		namePolicy:      z.namePolicy,
+
+		searchLimit:      z.searchLimit,
+		archiveOffset:    z.archiveOffset,
+		archiveOffsetSet: z.archiveOffsetSet,
+		maxEntries:       z.maxEntries,
+
+		maxMemory: z.maxMemory,
+		maxDict:   z.maxDict,
+		zstd:      z.zstd,
+		listOnly:  z.listOnly,
+
+		decompressors: z.decompressors,
+
+		cache: z.cache,
+
+		readBlockSize: z.readBlockSize,
+		readBlocks:    z.readBlocks,
+		bufferSize:    z.bufferSize,
+		readAheadSize: z.readAheadSize,
+		readAheads:    z.readAheads,
+		detectChanges: z.detectChanges,
+		changed:       z.changed,
+		mmap:          z.mmap,
+		maxVolumes:    z.maxVolumes,
+		rateLimit:     z.rateLimit,
+		metrics:       z.metrics,
+		logger:        z.logger,
+
+		poolSize:     z.poolSize,
+		maxSeek:      z.maxSeek,
+		newPool:      z.newPool,
+		sharedPool:   z.sharedPool,
+		sharedPoolID: z.sharedPoolID,
+		noPooling:    z.noPooling,
+		openWait:     z.openWait,
+	}
+
+	filesPerStream := make(map[int]int, z.si.Folders())
+
+	c.File = make([]*File, 0, len(z.File))
+
+	for _, f := range z.File {
+		nf := &File{
+			FileHeader: f.FileHeader,
+			zip:        c,
+			folder:     f.folder,
+			offset:     f.offset,
+		}
+
+		if !f.isEmptyStream && !f.isEmptyFile {
+			filesPerStream[f.folder]++
+		}
+
+		c.File = append(c.File, nf)
+	}
+
+	if z.listOnly {
+		return c, nil
+	}
+
+	if err := c.initPools(filesPerStream); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,138 @@
+// Command sevenzip lists, tests and extracts 7-zip archives.
+//
+// Usage:
+//
+//	sevenzip [flags] archive.7z
+//
+// With no flags the contents of the archive are listed. The flags are:
+//
+//	-t
+//		test the archive by decompressing every file and checking its CRC
+//	-x
+//		extract the archive, checking the CRC of every file
+//	-o dir
+//		extract into dir rather than the current directory
+//	-p password
+//		password for encrypted archives
+//	-j n
+//		decompress up to n streams concurrently when testing, defaults to
+//		the number of CPUs. It can't be used with -x
+//	-json
+//		list the contents of the archive as JSON
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/spf13/afero"
+)
+
+var (
+	errTooManyModes   = errors.New("sevenzip: only one of -t, -x and -json can be used")
+	errWorkersExtract = errors.New("sevenzip: -j can't be used with -x")
+	errNeedArchive    = errors.New("sevenzip: need exactly one archive")
+)
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sevenzip", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: sevenzip [flags] archive.7z")
+		fs.PrintDefaults()
+	}
+
+	var (
+		test     = fs.Bool("t", false, "test the archive")
+		extract  = fs.Bool("x", false, "extract the archive")
+		dir      = fs.String("o", ".", "extract into `dir`")
+		password = fs.String("p", "", "`password` for encrypted archives")
+		workers  = fs.Int("j", 0, "decompress up to `n` streams concurrently when testing")
+		asJSON   = fs.Bool("json", false, "list the archive as JSON")
+	)
+
+	if err := fs.Parse(args); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if *test && *extract || *asJSON && (*test || *extract) {
+		return errTooManyModes
+	}
+
+	if *extract && *workers != 0 {
+		return errWorkersExtract
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+
+		return errNeedArchive
+	}
+
+	r, err := sevenzip.OpenReaderWithPassword(fs.Arg(0), *password)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	defer r.Close()
+
+	switch {
+	case *test:
+		return testArchive(ctx, &r.Reader, *workers, stdout)
+	case *extract:
+		return r.ExtractToContext(ctx, afero.NewOsFs(), *dir) //nolint:wrapcheck
+	default:
+		return list(&r.Reader, stdout, *asJSON)
+	}
+}
+
+func list(r *sevenzip.Reader, w io.Writer, asJSON bool) error {
+	l := r.Listing()
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(l) //nolint:wrapcheck
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "Modified\tMode\tSize\tPacked\tMethod\t\tName")
+
+	for _, e := range l.Entries {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t\t%s\n",
+			e.Modified.UTC().Format(time.DateTime), e.Mode, e.Size, e.PackedSize, strings.Join(e.Methods, " "), e.Name)
+	}
+
+	fmt.Fprintf(tw, "\t\t%d\t%d\t\t\t%d files\n", l.Size, l.PackedSize, len(l.Entries))
+
+	return tw.Flush() //nolint:wrapcheck
+}
+
+func testArchive(ctx context.Context, r *sevenzip.Reader, workers int, w io.Writer) error {
+	if err := r.Verify(ctx, workers); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(w, "Everything is Ok, %d files\n", len(r.File))
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		args    []string
+		archive string
+		corrupt bool
+		output  string
+		err     error
+	}{
+		{
+			name:    "list",
+			archive: "bcj.7z",
+			output:  "LZMA2 BCJ  bcj\n",
+		},
+		{
+			name:    "json",
+			args:    []string{"-json"},
+			archive: "bcj.7z",
+			output:  `"name": "bcj",`,
+		},
+		{
+			name:    "test",
+			args:    []string{"-t"},
+			archive: "lzma1900.7z",
+			output:  "Everything is Ok",
+		},
+		{
+			name:    "password",
+			args:    []string{"-t", "-p", "password"},
+			archive: "t5.7z",
+			output:  "Everything is Ok, 2 files\n",
+		},
+		{
+			name:    "wrong password",
+			args:    []string{"-t", "-p", "notpassword"},
+			archive: "t5.7z",
+			err:     sevenzip.ErrWrongPassword,
+		},
+		{
+			name:    "corrupt test",
+			args:    []string{"-t"},
+			archive: "copy.7z",
+			corrupt: true,
+			err:     new(sevenzip.ReadError),
+		},
+		{
+			name:    "corrupt extract",
+			args:    []string{"-x"},
+			archive: "copy.7z",
+			corrupt: true,
+			err:     new(sevenzip.ReadError),
+		},
+		{
+			name:    "too many modes",
+			args:    []string{"-t", "-x"},
+			archive: "t5.7z",
+			err:     errTooManyModes,
+		},
+		{
+			name:    "workers with extract",
+			args:    []string{"-x", "-j", "2"},
+			archive: "t5.7z",
+			err:     errWorkersExtract,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			archive := filepath.Join("..", "..", "testdata", table.archive)
+			args := table.args
+
+			if table.corrupt {
+				archive = corruptArchive(t, archive)
+			}
+
+			if slices.Contains(args, "-x") {
+				args = append([]string{"-o", t.TempDir()}, args...)
+			}
+
+			stdout := new(bytes.Buffer)
+			err := run(context.Background(), append(args, archive), stdout, new(bytes.Buffer))
+
+			var re *sevenzip.ReadError
+
+			switch {
+			case errors.As(table.err, &re):
+				assert.ErrorAs(t, err, &re)
+
+				return
+			case table.err != nil:
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Contains(t, stdout.String(), table.output)
+		})
+	}
+}
+
+// corruptArchive returns a copy of archive with a byte of the first file's
+// data flipped.
+func corruptArchive(t *testing.T, archive string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(archive)
+	require.NoError(t, err)
+
+	b[40] ^= 0xff
+
+	name := filepath.Join(t.TempDir(), filepath.Base(archive))
+	require.NoError(t, os.WriteFile(name, b, 0o600))
+
+	return name
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	tables := []string{
+		"lzma1900.7z",
+		"symlink.7z",
+		"bcj2.7z",
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table, func(t *testing.T) {
+			t.Parallel()
+
+			archive := filepath.Join("..", "..", "testdata", table)
+			dir := t.TempDir()
+
+			require.NoError(t, run(context.Background(), []string{"-x", "-o", dir, archive}, new(bytes.Buffer), new(bytes.Buffer)))
+
+			r, err := sevenzip.OpenReader(archive)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			for _, f := range r.File {
+				name := filepath.Join(dir, filepath.FromSlash(f.Name))
+
+				fi, err := os.Lstat(name)
+				require.NoError(t, err)
+				assert.Equal(t, f.Mode().Type(), fi.Mode().Type(), f.Name)
+
+				switch {
+				case f.IsSymlink():
+					link, err := os.Readlink(name)
+					require.NoError(t, err)
+
+					expected, err := f.Readlink()
+					require.NoError(t, err)
+					assert.Equal(t, expected, link)
+				case f.Mode().IsRegular():
+					b, err := os.ReadFile(name)
+					require.NoError(t, err)
+
+					expected, err := fs.ReadFile(r, f.Name)
+					require.NoError(t, err)
+					assert.Equal(t, expected, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package sevenzip
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// dumpWriter writes formatted lines to w, remembering the first error so
+// that it only has to be checked once.
+type dumpWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dumpWriter) printf(format string, a ...any) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format+"\n", a...)
+	}
+}
+
+func (d *dumpWriter) flag(key string, v bool) {
+	if v {
+		d.printf("%s = +", key)
+	} else {
+		d.printf("%s = -", key)
+	}
+}
+
+func (d *dumpWriter) time(key string, t time.Time) {
+	if !t.IsZero() {
+		d.printf("%s = %s", key, t.UTC().Format("2006-01-02 15:04:05.0000000"))
+	}
+}
+
+// DumpHeader writes a description of the parsed header of the archive to w,
+// in the "Key = Value" style of "7z l -slt". As well as every file it lists
+// each packed stream and each folder, the decoding unit referred to as a
+// stream elsewhere in this package, with its chain of coders and how they
+// are bound together. It's intended to help diagnose archives that are
+// rejected or read incorrectly and the format may change.
+//
+//nolint:cyclop,funlen
+func (z *Reader) DumpHeader(w io.Writer) error {
+	d := &dumpWriter{w: w}
+
+	d.printf("Version = %d.%d", z.major, z.minor)
+	d.printf("Offset = %d", z.ArchiveOffset())
+	d.printf("Headers Offset = %d", z.end)
+	d.printf("Headers Size = %d", z.headerSize)
+	d.flag("Headers Compressed", z.headerCompressed)
+	d.flag("Headers Encrypted", z.headerEncrypted)
+
+	if si := z.si; si != nil && si.packInfo != nil {
+		d.printf("")
+		d.printf("Pack Position = %d", si.packInfo.position)
+		d.printf("Pack Streams = %d", len(si.packInfo.size))
+
+		for i, size := range si.packInfo.size {
+			d.printf("")
+			d.printf("Pack Stream = %d", i)
+			d.printf("Size = %d", size)
+
+			if si.packInfo.digest != nil {
+				d.printf("CRC = %08X", si.packInfo.digest[i])
+			}
+		}
+	}
+
+	for i := 0; i < z.si.Folders(); i++ {
+		f := z.si.unpackInfo.folder[i]
+
+		d.printf("")
+		d.printf("Folder = %d", i)
+		d.printf("Offset = %d", z.si.folderOffset(i))
+		d.printf("Packed Size = %d", z.si.folderPackedSize(i))
+		d.printf("Size = %d", f.unpackSize())
+
+		if z.si.unpackInfo.digest != nil {
+			d.printf("CRC = %08X", z.si.unpackInfo.digest[i])
+		}
+
+		for j, c := range f.coder {
+			d.printf("Coder = %d %s in=%d out=%d props=%X", j, MethodName(c.id), c.in, c.out, c.properties)
+		}
+
+		for _, bp := range f.bindPair {
+			d.printf("Bind Pair = in%d <- out%d", bp.in, bp.out)
+		}
+
+		for j, in := range f.packed {
+			d.printf("Packed Stream = %d -> in%d", z.si.packedStream[i]+uint64(j), in) //nolint:gosec
+		}
+
+		sizes := make([]string, 0, len(f.size))
+		for _, size := range f.size {
+			sizes = append(sizes, fmt.Sprint(size))
+		}
+
+		d.printf("Coder Sizes = %s", strings.Join(sizes, ", "))
+
+		if z.si.subStreamsInfo != nil && i < len(z.si.subStreamsInfo.streams) {
+			d.printf("Files = %d", z.si.subStreamsInfo.streams[i])
+		}
+	}
+
+	for _, f := range z.File {
+		d.printf("")
+		d.printf("Path = %s", f.Name)
+		d.printf("Size = %d", f.UncompressedSize)
+		d.printf("Packed Size = %d", f.PackedSize)
+
+		if !f.isEmptyStream && !f.isEmptyFile {
+			d.printf("Folder = %d", f.folder)
+			d.printf("Folder Offset = %d", f.offset)
+			d.printf("CRC = %08X", f.CRC32)
+		}
+
+		d.time("Modified", f.Modified)
+		d.time("Created", f.Created)
+		d.time("Accessed", f.Accessed)
+		d.printf("Attributes = %08X %s", f.Attributes, f.Mode())
+		d.flag("Empty Stream", f.isEmptyStream)
+		d.flag("Empty File", f.isEmptyFile)
+		d.flag("Anti", f.IsAnti)
+
+		if f.hasStartPos {
+			d.printf("Position = %d", f.StartPos)
+		}
+	}
+
+	return d.err //nolint:wrapcheck
+}
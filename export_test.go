@@ -1,6 +1,39 @@
 package sevenzip
 
 var (
-	ErrMissingUnpackInfo = errMissingUnpackInfo
-	ErrNegativeSize      = errNegativeSize
+	ErrChecksum               = errChecksum
+	ErrDuplicate              = errDuplicate
+	ErrFormat                 = errFormat
+	ErrInvalidArchiveOffset   = errInvalidArchiveOffset
+	ErrInvalidBufferSize      = errInvalidBufferSize
+	ErrInvalidCacheSize       = errInvalidCacheSize
+	ErrInvalidDuplicatePolicy = errInvalidDuplicatePolicy
+	ErrInvalidMaxDictionary   = errInvalidMaxDictionary
+	ErrInvalidMaxEntries      = errInvalidMaxEntries
+	ErrInvalidMaxMemory       = errInvalidMaxMemory
+	ErrInvalidMaxVolumes      = errInvalidMaxVolumes
+	ErrInvalidNamePolicy      = errInvalidNamePolicy
+	ErrInvalidOpenWait        = errInvalidOpenWait
+	ErrInvalidPoolSize        = errInvalidPoolSize
+	ErrInvalidRateLimit       = errInvalidRateLimit
+	ErrInvalidReadAhead       = errInvalidReadAhead
+	ErrInvalidReadCache       = errInvalidReadCache
+	ErrInvalidSearchLimit     = errInvalidSearchLimit
+	ErrInvalidSeekDistance    = errInvalidSeekDistance
+	ErrInvalidZstdConcurrency = errInvalidZstdConcurrency
+	ErrInvalidZstdWindow      = errInvalidZstdWindow
+	ErrListOnly               = errListOnly
+	ErrMissingUnpackInfo      = errMissingUnpackInfo
+	ErrMissingStream          = errMissingStream
+	ErrNegativeSize           = errNegativeSize
+	ErrNilCollector           = errNilCollector
+	ErrNilLogger              = errNilLogger
+	ErrNilNameMapping         = errNilNameMapping
+	ErrNilPasswordCallback    = errNilPasswordCallback
+	ErrNilPoolConstructor     = errNilPoolConstructor
+	ErrNilSharedPool          = errNilSharedPool
+	ErrNoSuchStream           = errNoSuchStream
+	ErrTooManyEntries         = errTooManyEntries
+	ErrTruncated              = errTruncated
+	ErrUnexpectedID           = errUnexpectedID
 )
@@ -0,0 +1,142 @@
+package sevenzip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type contextReader struct {
+	ctx context.Context //nolint:containedctx
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return cr.r.Read(p) //nolint:wrapcheck
+}
+
+// ExtractConcurrent calls fn for each file in the archive with a reader
+// providing the file contents. The files in each stream are passed to fn in
+// order by the same goroutine, which is the most efficient way to read them,
+// and up to workers streams are processed concurrently. If workers is less
+// than one then [runtime.NumCPU] is used.
+//
+// The reader is only valid until fn returns. If fn returns an error or ctx is
+// cancelled then no further files are processed and the first error is
+// returned.
+func (z *Reader) ExtractConcurrent(ctx context.Context, workers int, fn func(*File, io.Reader) error) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(workers)
+
+	for _, files := range z.streamFiles() {
+		files := files
+
+		eg.Go(func() error {
+			for _, i := range files {
+				if err := extractFile(ctx, z.File[i], fn); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return eg.Wait() //nolint:wrapcheck
+}
+
+// ExtractAll is like [Reader.ExtractConcurrent] but carries on after fn
+// returns an error or a file can't be read, so that everything salvageable
+// is extracted in one pass. The outcome for every file is returned in a
+// [Report], with any files not reached because ctx was cancelled marked as
+// [OutcomeSkipped]. The error is only non-nil if ctx is cancelled.
+func (z *Reader) ExtractAll(ctx context.Context, workers int, fn func(*File, io.Reader) error) (*Report, error) {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	report := &Report{
+		Files: make([]FileResult, len(z.File)),
+	}
+
+	for i, f := range z.File {
+		report.Files[i] = FileResult{
+			File:    f,
+			Outcome: OutcomeSkipped,
+		}
+	}
+
+	var eg errgroup.Group
+
+	eg.SetLimit(workers)
+
+	for _, files := range z.streamFiles() {
+		files := files
+
+		eg.Go(func() error {
+			for _, i := range files {
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				err := extractFile(ctx, z.File[i], fn)
+				report.Files[i].Outcome, report.Files[i].Err = classify(err), err
+			}
+
+			return nil
+		})
+	}
+
+	_ = eg.Wait()
+
+	return report, ctx.Err() //nolint:wrapcheck
+}
+
+// streamFiles returns the index of each file grouped by stream, in the
+// order each stream is first used.
+func (z *Reader) streamFiles() [][]int {
+	streams := make(map[int]int, z.si.Folders())
+	files := make([][]int, 0, z.si.Folders())
+
+	for i, f := range z.File {
+		j, ok := streams[f.Stream]
+		if !ok {
+			j = len(files)
+			streams[f.Stream] = j
+			files = append(files, nil)
+		}
+
+		files[j] = append(files[j], i)
+	}
+
+	return files
+}
+
+func extractFile(ctx context.Context, f *File, fn func(*File, io.Reader) error) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("sevenzip: error opening %s: %w", f.Name, err)
+	}
+
+	defer func() {
+		err = errors.Join(err, rc.Close())
+	}()
+
+	return fn(f, &contextReader{ctx, rc})
+}
@@ -0,0 +1,150 @@
+package sevenzip
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// ExtractTo extracts every file in the archive into dir on fsys, which can
+// be any [afero.Fs] such as an in-memory filesystem, one restricted to a
+// base path, or simply [afero.NewOsFs]. Every name is checked with
+// [SanitizePath] before anything is written; if any are unsafe nothing is
+// extracted and an error for each of them is returned. Files are
+// decompressed concurrently with [Reader.ExtractConcurrent], keeping their
+// permissions and modification times where fsys supports it, and anti items
+// are ignored. Each file is checked against its CRC32 as it's written, a
+// mismatch is returned as a [*ReadError].
+//
+// Symbolic links are created last so that nothing can be written through
+// one. If fsys doesn't implement [afero.Linker] then extracting an archive
+// containing a symbolic link fails with [afero.ErrNoSymlink].
+func (z *Reader) ExtractTo(fsys afero.Fs, dir string) error {
+	return z.ExtractToContext(context.Background(), fsys, dir)
+}
+
+// ExtractToContext is like [Reader.ExtractTo] but stops extracting and
+// returns the error from ctx if it's cancelled.
+func (z *Reader) ExtractToContext(ctx context.Context, fsys afero.Fs, dir string) error {
+	// Check every name before anything is written so an unsafe name can't
+	// leave a partial extraction behind
+	targets := make(map[*File]string, len(z.File))
+
+	var errs []error
+
+	for _, f := range z.File {
+		if f.IsAnti {
+			continue
+		}
+
+		name, err := SanitizePath(f.Name)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		targets[f] = filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// Create every directory first so the files can be extracted in any
+	// order
+	for _, f := range z.File {
+		if !f.Mode().IsDir() || f.IsAnti {
+			continue
+		}
+
+		if err := fsys.MkdirAll(targets[f], 0o755); err != nil { //nolint:gosec
+			return err //nolint:wrapcheck
+		}
+	}
+
+	if err := z.ExtractConcurrent(ctx, 0, func(f *File, r io.Reader) error {
+		if f.Mode().IsDir() || f.IsSymlink() || f.IsAnti {
+			return nil
+		}
+
+		h := crc32.NewIEEE()
+		if err := extractToFile(fsys, f, io.TeeReader(r, h), targets[f]); err != nil {
+			return err
+		}
+
+		return z.checkCRC(f, h.Sum32())
+	}); err != nil {
+		return err
+	}
+
+	for _, f := range z.File {
+		if !f.IsSymlink() || f.IsAnti {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := extractToSymlink(fsys, f, targets[f]); err != nil {
+			return err
+		}
+	}
+
+	// Set the directory times last as creating their contents updates them
+	for _, f := range z.File {
+		if !f.Mode().IsDir() || f.IsAnti {
+			continue
+		}
+
+		errs = append(errs, fsys.Chtimes(targets[f], f.Accessed, f.Modified))
+	}
+
+	return errors.Join(errs...)
+}
+
+func extractToFile(fsys afero.Fs, f *File, r io.Reader, name string) (err error) {
+	if err = fsys.MkdirAll(filepath.Dir(name), 0o755); err != nil { //nolint:gosec
+		return err //nolint:wrapcheck
+	}
+
+	w, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0o200)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	defer func() {
+		err = errors.Join(err, w.Close())
+		if err == nil {
+			err = fsys.Chtimes(name, f.Accessed, f.Modified)
+		}
+	}()
+
+	_, err = io.Copy(w, r)
+
+	return err //nolint:wrapcheck
+}
+
+func extractToSymlink(fsys afero.Fs, f *File, name string) error {
+	link, err := f.Readlink()
+	if err != nil {
+		return err
+	}
+
+	linker, ok := fsys.(afero.Linker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: link, New: name, Err: afero.ErrNoSymlink}
+	}
+
+	if err := fsys.MkdirAll(filepath.Dir(name), 0o755); err != nil { //nolint:gosec
+		return err //nolint:wrapcheck
+	}
+
+	return linker.SymlinkIfPossible(link, name) //nolint:wrapcheck
+}
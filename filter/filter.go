@@ -0,0 +1,115 @@
+// Package filter provides the branch conversion, BCJ2 and Delta filters used
+// by 7-zip archives so that other archive tooling can reuse them. Each
+// New*Reader function has the same signature as
+// [github.com/bodgit/sevenzip.Decompressor] so they can also be registered
+// with custom method IDs using
+// [github.com/bodgit/sevenzip.RegisterDecompressor].
+package filter
+
+import (
+	"io"
+
+	"github.com/bodgit/sevenzip/internal/bcj2"
+	"github.com/bodgit/sevenzip/internal/bra"
+	"github.com/bodgit/sevenzip/internal/delta"
+)
+
+// Converter converts the relative addresses of branch instructions for a
+// particular architecture to absolute addresses when encoding, and back again
+// when decoding, which makes executables compress better. A Converter keeps
+// track of the position within the stream so successive calls must be passed
+// consecutive data.
+type Converter interface {
+	// Size returns the number of bytes Convert needs to be passed to be
+	// able to convert an instruction.
+	Size() int
+	// Convert converts the instructions in b in place, encoding if
+	// encoding is true or decoding otherwise, and returns how many bytes
+	// of b were processed. Any remaining bytes should be passed again at
+	// the start of the next call along with more data.
+	Convert(b []byte, encoding bool) int
+}
+
+// NewARMConverter returns a new ARM [Converter].
+func NewARMConverter() Converter { return bra.NewARM() }
+
+// NewARM64Converter returns a new ARM64 [Converter].
+func NewARM64Converter() Converter { return bra.NewARM64() }
+
+// NewARMTConverter returns a new ARM Thumb [Converter].
+func NewARMTConverter() Converter { return bra.NewARMT() }
+
+// NewBCJConverter returns a new x86 [Converter].
+func NewBCJConverter() Converter { return bra.NewBCJ() }
+
+// NewIA64Converter returns a new IA64 [Converter].
+func NewIA64Converter() Converter { return bra.NewIA64() }
+
+// NewPPCConverter returns a new PowerPC [Converter].
+func NewPPCConverter() Converter { return bra.NewPPC() }
+
+// NewRISCVConverter returns a new RISC-V [Converter].
+func NewRISCVConverter() Converter { return bra.NewRISCV() }
+
+// NewSPARCConverter returns a new SPARC [Converter].
+func NewSPARCConverter() Converter { return bra.NewSPARC() }
+
+// NewReader returns a new io.ReadCloser that reads from rc, decoding the
+// branch instructions with conv.
+func NewReader(conv Converter, rc io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewReader([]io.ReadCloser{rc}, conv) //nolint:wrapcheck
+}
+
+// NewARMReader returns a new ARM io.ReadCloser.
+func NewARMReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewARMReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewARM64Reader returns a new ARM64 io.ReadCloser. The properties can
+// optionally hold the start offset of the stream as a little-endian uint32.
+func NewARM64Reader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewARM64Reader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewARMTReader returns a new ARM Thumb io.ReadCloser.
+func NewARMTReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewARMTReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewBCJReader returns a new x86 io.ReadCloser. The properties can
+// optionally hold the start offset of the stream as a little-endian uint32.
+func NewBCJReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewBCJReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewIA64Reader returns a new IA64 io.ReadCloser.
+func NewIA64Reader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewIA64Reader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewPPCReader returns a new PowerPC io.ReadCloser.
+func NewPPCReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewPPCReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewRISCVReader returns a new RISC-V io.ReadCloser.
+func NewRISCVReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewRISCVReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewSPARCReader returns a new SPARC io.ReadCloser.
+func NewSPARCReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bra.NewSPARCReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewBCJ2Reader returns a new BCJ2 io.ReadCloser. It needs four readers: the
+// main stream, the call stream, the jump stream and the range-coded stream.
+func NewBCJ2Reader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return bcj2.NewReader(p, s, readers) //nolint:wrapcheck
+}
+
+// NewDeltaReader returns a new Delta io.ReadCloser. The single property byte
+// is the distance between the bytes being subtracted, minus one.
+func NewDeltaReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return delta.NewReader(p, s, readers) //nolint:wrapcheck
+}
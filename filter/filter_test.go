@@ -0,0 +1,136 @@
+package filter_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/bodgit/sevenzip/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		conv func() filter.Converter
+	}{
+		{"ARM", filter.NewARMConverter},
+		{"ARM64", filter.NewARM64Converter},
+		{"ARMT", filter.NewARMTConverter},
+		{"BCJ", filter.NewBCJConverter},
+		{"IA64", filter.NewIA64Converter},
+		{"PPC", filter.NewPPCConverter},
+		{"RISCV", filter.NewRISCVConverter},
+		{"SPARC", filter.NewSPARCConverter},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := make([]byte, 1<<16)
+			_, _ = rand.New(rand.NewSource(1)).Read(b) //nolint:gosec
+
+			encoded := bytes.Clone(b)
+			table.conv().Convert(encoded, true)
+			assert.NotEqual(t, b, encoded)
+
+			rc, err := filter.NewReader(table.conv(), io.NopCloser(bytes.NewReader(encoded)))
+			require.NoError(t, err)
+
+			decoded, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			assert.Equal(t, b, decoded)
+		})
+	}
+}
+
+func TestStartOffset(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name             string
+		reader           func([]byte, uint64, []io.ReadCloser) (io.ReadCloser, error)
+		props            []byte
+		encoded, decoded []byte
+		err              bool
+	}{
+		{
+			name:   "ARM64",
+			reader: filter.NewARM64Reader,
+			props:  []byte{0x00, 0x10, 0x00, 0x00},
+			// BL at 0x1004 to 0x1044
+			encoded: []byte{0x1f, 0x20, 0x03, 0xd5, 0x11, 0x04, 0x00, 0x94},
+			decoded: []byte{0x1f, 0x20, 0x03, 0xd5, 0x10, 0x00, 0x00, 0x94},
+		},
+		{
+			name:    "ARM64 no offset",
+			reader:  filter.NewARM64Reader,
+			encoded: []byte{0x1f, 0x20, 0x03, 0xd5, 0x11, 0x00, 0x00, 0x94},
+			decoded: []byte{0x1f, 0x20, 0x03, 0xd5, 0x10, 0x00, 0x00, 0x94},
+		},
+		{
+			name:   "ARM64 unaligned",
+			reader: filter.NewARM64Reader,
+			props:  []byte{0x02, 0x00, 0x00, 0x00},
+			err:    true,
+		},
+		{
+			name:   "ARM64 invalid",
+			reader: filter.NewARM64Reader,
+			props:  []byte{0x00},
+			err:    true,
+		},
+		{
+			name:   "BCJ",
+			reader: filter.NewBCJReader,
+			props:  []byte{0x00, 0x10, 0x00, 0x00},
+			// CALL at 0x1000 to 0x1105
+			encoded: []byte{0xe8, 0x05, 0x11, 0x00, 0x00, 0x90, 0x90, 0x90, 0x90},
+			decoded: []byte{0xe8, 0x00, 0x01, 0x00, 0x00, 0x90, 0x90, 0x90, 0x90},
+		},
+		{
+			name:    "BCJ no offset",
+			reader:  filter.NewBCJReader,
+			encoded: []byte{0xe8, 0x05, 0x01, 0x00, 0x00, 0x90, 0x90, 0x90, 0x90},
+			decoded: []byte{0xe8, 0x00, 0x01, 0x00, 0x00, 0x90, 0x90, 0x90, 0x90},
+		},
+		{
+			name:   "BCJ invalid",
+			reader: filter.NewBCJReader,
+			props:  []byte{0x00, 0x10},
+			err:    true,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			rc, err := table.reader(table.props, uint64(len(table.encoded)), []io.ReadCloser{io.NopCloser(bytes.NewReader(table.encoded))})
+			if table.err {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			decoded, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			assert.Equal(t, table.decoded, decoded)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package sevenzip
+
+import (
+	"sync"
+	"time"
+)
+
+// A flight is a partially-read stream in use by an open file, which will be
+// returned to the pool at end once the file is closed.
+type flight struct {
+	end  int64
+	done chan struct{}
+}
+
+// flights tracks the readers of a stream in use by open files, so that
+// opening another file in the same stream can wait for one of them to be
+// returned to the pool instead of decoding the stream again.
+type flights struct {
+	mutex sync.Mutex
+	f     map[*flight]struct{}
+}
+
+func newFlights() *flights {
+	return &flights{
+		f: make(map[*flight]struct{}),
+	}
+}
+
+// start records a reader in use until it reaches end.
+func (fs *flights) start(end int64) *flight {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fl := &flight{
+		end:  end,
+		done: make(chan struct{}),
+	}
+	fs.f[fl] = struct{}{}
+
+	return fl
+}
+
+// finish records that the reader is no longer in use, waking anything
+// waiting for it.
+func (fs *flights) finish(fl *flight) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, ok := fs.f[fl]; ok {
+		delete(fs.f, fl)
+		close(fl.done)
+	}
+}
+
+// closest returns the reader in use that will finish closest before offset
+// and no more than maxSeek bytes behind it, if maxSeek is positive.
+func (fs *flights) closest(offset, maxSeek int64) *flight {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var closest *flight
+
+	for fl := range fs.f {
+		if fl.end > offset || (maxSeek > 0 && offset-fl.end > maxSeek) {
+			continue
+		}
+
+		if closest == nil || fl.end > closest.end {
+			closest = fl
+		}
+	}
+
+	return closest
+}
+
+// awaitReader waits for up to the duration set with [WithOpenWait] for
+// another file in the same stream as f, but before it, to be closed so its
+// partially-read reader can be taken from the pool. It returns nil if there
+// isn't one or it takes too long.
+func (z *Reader) awaitReader(f *File) SizeReadSeekCloser {
+	if z.flights == nil || z.flights[f.folder] == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(z.openWait)
+	defer timer.Stop()
+
+	for {
+		fl := z.flights[f.folder].closest(f.offset, z.maxSeek)
+		if fl == nil {
+			return nil
+		}
+
+		select {
+		case <-fl.done:
+			if rc := z.pooledReader(f); rc != nil {
+				return rc
+			}
+		case <-timer.C:
+			return nil
+		}
+	}
+}
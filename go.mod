@@ -12,7 +12,6 @@ require (
 	github.com/spf13/afero v1.11.0
 	github.com/stretchr/testify v1.10.0
 	github.com/ulikunitz/xz v0.5.12
-	go4.org v0.0.0-20200411211856-f5505b9728dd
 	golang.org/x/sync v0.10.0
 	golang.org/x/text v0.21.0
 )
@@ -0,0 +1,214 @@
+package sevenzip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/bodgit/sevenzip/internal/util"
+)
+
+// Header is the header of an archive as returned by [ParseHeader], which
+// describes how the archive is stored without needing to open it with a
+// [Reader].
+type Header struct {
+	// Encoded is set if the header is itself compressed. Streams then
+	// describes the packed stream holding the real header, which can also
+	// be passed to ParseHeader once decompressed, and Files is empty.
+	Encoded bool
+
+	// Streams describes the packed streams and how they are unpacked, it's
+	// nil if the archive doesn't have any.
+	Streams *StreamsInfo
+
+	// Files is every entry in the archive in the order they are stored.
+	// The fields that come from Streams, such as [FileHeader.Stream] and
+	// [FileHeader.CRC32], are filled in.
+	Files []FileHeader
+}
+
+// StreamsInfo describes the packed streams of an archive and the folders
+// they are unpacked by, which [FileHeader.Stream] refers to.
+type StreamsInfo struct {
+	// PackPosition is the offset of the first packed stream, following
+	// the 32-byte signature header at the start of the archive.
+	PackPosition uint64
+
+	// PackSizes and PackDigests have the size and CRC32 of each packed
+	// stream in order, the digests are zero or nil if they aren't stored.
+	PackSizes   []uint64
+	PackDigests []uint32
+
+	Folders []Folder
+}
+
+// Folder describes how one or more consecutive packed streams are decoded
+// by a graph of coders into a single unpacked stream, which holds one or
+// more files.
+type Folder struct {
+	Coders []Coder
+
+	// BindPairs connect the output of one coder to the input of another,
+	// numbering the inputs and outputs of every coder in turn.
+	BindPairs []BindPair
+
+	// PackedStreams is the input fed by each of the folder's packed
+	// streams, in order.
+	PackedStreams []uint64
+
+	// UnpackSizes is the size of each coder output, in the same order.
+	UnpackSizes []uint64
+
+	// Digest is the CRC32 of the unpacked stream, or zero if it isn't
+	// stored.
+	Digest uint32
+
+	// SubStreamSizes and SubStreamDigests have the size and CRC32 of each
+	// file stored in the unpacked stream, the digests are nil if they
+	// aren't stored.
+	SubStreamSizes   []uint64
+	SubStreamDigests []uint32
+}
+
+// Coder is a single method in a [Folder].
+type Coder struct {
+	// ID is the method ID, [MethodName] returns its name.
+	ID         []byte
+	InStreams  uint64
+	OutStreams uint64
+	Properties []byte
+}
+
+// BindPair connects the output stream OutIndex of one coder in a [Folder]
+// to the input stream InIndex of another.
+type BindPair struct {
+	InIndex  uint64
+	OutIndex uint64
+}
+
+// ParseHeader parses the header of an archive from r, which should start at
+// the header itself, found using the offset and size stored in the
+// signature header. This is intended for inspecting damaged archives that
+// can't be opened with a [Reader], so nothing is decompressed and no
+// checksums are verified, however the same limits on the size of the
+// header apply.
+func ParseHeader(r io.Reader) (*Header, error) {
+	br, ok := r.(util.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	id, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("sevenzip: error reading header id: %w", err)
+	}
+
+	var opts headerOptions
+
+	switch id {
+	case idHeader:
+		h, err := readHeader(br, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		header := &Header{
+			Streams: newStreamsInfo(h.streamsInfo),
+		}
+
+		if h.filesInfo != nil {
+			header.Files = h.filesInfo.file
+			for i := range header.Files {
+				header.Files[i].Index = i
+
+				if i < len(h.filesInfo.rawName) {
+					header.Files[i].RawName = string(h.filesInfo.rawName[i])
+				}
+			}
+		}
+
+		return header, nil
+	case idEncodedHeader:
+		si, err := readStreamsInfo(br, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Header{
+			Encoded: true,
+			Streams: newStreamsInfo(si),
+		}, nil
+	default:
+		return nil, errUnexpectedID
+	}
+}
+
+func newStreamsInfo(si *streamsInfo) *StreamsInfo {
+	if si == nil {
+		return nil
+	}
+
+	s := new(StreamsInfo)
+
+	if si.packInfo != nil {
+		s.PackPosition = si.packInfo.position
+		s.PackSizes = si.packInfo.size
+		s.PackDigests = si.packInfo.digest
+	}
+
+	if si.unpackInfo == nil {
+		return s
+	}
+
+	ss := si.subStreamsInfo
+	s.Folders = make([]Folder, len(si.unpackInfo.folder))
+
+	var j uint64 // substreams seen so far in all folders
+
+	for i, f := range si.unpackInfo.folder {
+		folder := &s.Folders[i]
+		folder.PackedStreams = f.packed
+		folder.UnpackSizes = f.size
+
+		if si.unpackInfo.digest != nil {
+			folder.Digest = si.unpackInfo.digest[i]
+		}
+
+		for _, c := range f.coder {
+			folder.Coders = append(folder.Coders, Coder{
+				ID:         c.id,
+				InStreams:  c.in,
+				OutStreams: c.out,
+				Properties: c.properties,
+			})
+		}
+
+		for _, bp := range f.bindPair {
+			folder.BindPairs = append(folder.BindPairs, BindPair{
+				InIndex:  bp.in,
+				OutIndex: bp.out,
+			})
+		}
+
+		// A folder has one file unless the substreams say otherwise
+		n := uint64(1)
+		if ss != nil {
+			n = ss.streams[i]
+		}
+
+		switch {
+		case ss != nil && ss.size != nil:
+			folder.SubStreamSizes = ss.size[j : j+n]
+		case n == 1:
+			folder.SubStreamSizes = []uint64{f.unpackSize()}
+		}
+
+		if ss != nil && ss.digest != nil {
+			folder.SubStreamDigests = ss.digest[j : j+n]
+		}
+
+		j += n
+	}
+
+	return s
+}
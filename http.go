@@ -0,0 +1,132 @@
+package sevenzip
+
+import (
+	"io"
+	iofs "io/fs"
+	"net/http"
+)
+
+// HTTPFileSystem returns an [http.FileSystem] view of the archive so that its
+// contents can be served with [http.FileServer], including directory
+// listings and Last-Modified headers taken from the modification time of
+// each file. Unlike wrapping the [Reader] with [http.FS], files are seekable
+// even when compressed, which range requests and content type detection
+// rely on; seeking backwards decompresses the file again from the start.
+func (z *Reader) HTTPFileSystem() http.FileSystem {
+	return httpFS{z}
+}
+
+type httpFS struct {
+	z *Reader
+}
+
+func (h httpFS) Open(name string) (http.File, error) {
+	name = unroot(name)
+
+	f, err := h.z.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpFile{File: f, z: h.z, name: name}, nil
+}
+
+// httpFile adds the Readdir and Seek methods needed by http.File. If the
+// file isn't an io.Seeker, seeking is emulated by tracking the offset and
+// reading forward to it, or reopening the file to seek backwards.
+type httpFile struct {
+	iofs.File
+	z    *Reader
+	name string
+	off  int64 // offset seen by the caller
+	pos  int64 // offset of File
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	if _, ok := f.File.(io.Seeker); ok {
+		return f.File.Read(p) //nolint:wrapcheck
+	}
+
+	if f.off < f.pos {
+		if err := f.File.Close(); err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+
+		file, err := f.z.Open(f.name)
+		if err != nil {
+			return 0, err
+		}
+
+		f.File, f.pos = file, 0
+	}
+
+	if f.off > f.pos {
+		n, err := io.Copy(io.Discard, io.LimitReader(f.File, f.off-f.pos))
+		f.pos += n
+
+		if err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+
+		if f.off > f.pos {
+			return 0, io.EOF
+		}
+	}
+
+	n, err := f.File.Read(p)
+	f.pos += int64(n)
+	f.off = f.pos
+
+	return n, err //nolint:wrapcheck
+}
+
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence) //nolint:wrapcheck
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		fi, err := f.File.Stat()
+		if err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+
+		offset += fi.Size()
+	default:
+		return 0, errInvalidWhence
+	}
+
+	if offset < 0 {
+		return 0, errNegativeSeek
+	}
+
+	f.off = offset
+
+	return offset, nil
+}
+
+func (f *httpFile) Readdir(count int) ([]iofs.FileInfo, error) {
+	d, ok := f.File.(iofs.ReadDirFile)
+	if !ok {
+		return nil, &iofs.PathError{Op: "readdir", Path: f.name, Err: errNotDirectory}
+	}
+
+	entries, err := d.ReadDir(count)
+
+	infos := make([]iofs.FileInfo, 0, len(entries))
+
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			return infos, err //nolint:wrapcheck
+		}
+
+		infos = append(infos, fi)
+	}
+
+	return infos, err //nolint:wrapcheck
+}
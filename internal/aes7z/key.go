@@ -13,8 +13,10 @@ import (
 	"golang.org/x/text/transform"
 )
 
+// cacheKey identifies a derived key. The password is hashed so the cache
+// doesn't hold on to it in a string that can't be wiped.
 type cacheKey struct {
-	password string
+	password [sha256.Size]byte
 	cycles   int
 	salt     string // []byte isn't comparable
 }
@@ -26,14 +28,14 @@ var once = sync.OnceValues(func() (*lru.Cache[cacheKey, []byte], error) {
 	return lru.New[cacheKey, []byte](cacheSize)
 })
 
-func calculateKey(password string, cycles int, salt []byte) ([]byte, error) {
+func calculateKey(password []byte, cycles int, salt []byte) ([]byte, error) {
 	cache, err := once()
 	if err != nil {
 		return nil, fmt.Errorf("aes7z: error creating cache: %w", err)
 	}
 
 	ck := cacheKey{
-		password: password,
+		password: sha256.Sum256(password),
 		cycles:   cycles,
 		salt:     hex.EncodeToString(salt),
 	}
@@ -42,28 +44,83 @@ func calculateKey(password string, cycles int, salt []byte) ([]byte, error) {
 		return key, nil
 	}
 
-	b := bytes.NewBuffer(salt)
+	key := deriveKey(password, cycles, salt)
+
+	_ = cache.Add(ck, key)
+
+	return key, nil
+}
+
+// Forget removes any cached keys derived from password and zeroes them.
+func Forget(password []byte) {
+	cache, err := once()
+	if err != nil {
+		return
+	}
+
+	digest := sha256.Sum256(password)
+
+	for _, ck := range cache.Keys() {
+		if ck.password != digest {
+			continue
+		}
+
+		if key, ok := cache.Peek(ck); ok {
+			clear(key)
+		}
+
+		_ = cache.Remove(ck)
+	}
+}
+
+// keyBatch is how many rounds are hashed with each write.
+const keyBatch = 64
+
+// deriveKey hashes 2^cycles rounds of the salt, the UTF-16LE password and
+// the round counter. As each round is tiny, they are written to the hash in
+// batches to cut the per-write overhead, leaving the time dominated by the
+// SHA-256 block function, which uses the SHA extensions where the CPU has
+// them.
+func deriveKey(password []byte, cycles int, salt []byte) []byte {
+	b := bytes.NewBuffer(bytes.Clone(salt))
 
 	// Convert password to UTF-16LE
 	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
 	t := transform.NewWriter(b, utf16le.NewEncoder())
-	_, _ = t.Write([]byte(password))
+	_, _ = t.Write(password)
+
+	// Don't leave copies of the password lying around
+	defer clear(b.Bytes())
 
 	key := make([]byte, sha256.Size)
 	if cycles == 0x3f {
 		copy(key, b.Bytes())
-	} else {
-		h := sha256.New()
-		for i := uint64(0); i < 1<<cycles; i++ {
-			// These will never error
-			_, _ = h.Write(b.Bytes())
-			_ = binary.Write(h, binary.LittleEndian, i)
-		}
 
-		copy(key, h.Sum(nil))
+		return key
 	}
 
-	_ = cache.Add(ck, key)
+	// Each round is the salt and password followed by the counter
+	round := b.Len() + 8
+	rounds := uint64(1) << cycles
+	batch := make([]byte, round*int(min(rounds, keyBatch)))
 
-	return key, nil
+	defer clear(batch)
+
+	for i := 0; i < len(batch); i += round {
+		copy(batch[i:], b.Bytes())
+	}
+
+	h := sha256.New()
+
+	for i := uint64(0); i < rounds; {
+		n := 0
+		for ; n < len(batch) && i < rounds; n, i = n+round, i+1 {
+			binary.LittleEndian.PutUint64(batch[n+round-8:], i)
+		}
+
+		// This will never error
+		_, _ = h.Write(batch[:n])
+	}
+
+	return h.Sum(key[:0])
 }
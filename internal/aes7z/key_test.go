@@ -0,0 +1,77 @@
+package aes7z
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// referenceKey derives the key a round at a time.
+func referenceKey(password string, cycles int, salt []byte) []byte {
+	b := bytes.NewBuffer(bytes.Clone(salt))
+
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	t := transform.NewWriter(b, utf16le.NewEncoder())
+	_, _ = t.Write([]byte(password))
+
+	h := sha256.New()
+	for i := uint64(0); i < 1<<cycles; i++ {
+		_, _ = h.Write(b.Bytes())
+		_ = binary.Write(h, binary.LittleEndian, i)
+	}
+
+	return h.Sum(nil)
+}
+
+func TestDeriveKey(t *testing.T) {
+	t.Parallel()
+
+	for _, cycles := range []int{0, 1, 6, 7, 19} {
+		cycles := cycles
+
+		t.Run(strconv.Itoa(cycles), func(t *testing.T) {
+			t.Parallel()
+
+			salt := []byte{0x01, 0x02, 0x03, 0x04}
+
+			assert.Equal(t, referenceKey("password", cycles, salt), deriveKey([]byte("password"), cycles, salt))
+			assert.Equal(t, referenceKey("", cycles, nil), deriveKey(nil, cycles, nil))
+		})
+	}
+}
+
+func BenchmarkDeriveKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = deriveKey([]byte("password"), 19, nil)
+	}
+}
+
+func BenchmarkReferenceKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = referenceKey("password", 19, nil)
+	}
+}
+
+func TestForget(t *testing.T) {
+	t.Parallel()
+
+	// Use a password no other test uses
+	password := []byte("forget")
+
+	key, err := calculateKey(password, 1, nil)
+	require.NoError(t, err)
+
+	Forget(password)
+	assert.Equal(t, make([]byte, len(key)), key)
+
+	again, err := calculateKey(password, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, deriveKey(password, 1, nil), again)
+}
@@ -2,7 +2,6 @@
 package aes7z
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"errors"
@@ -23,7 +22,8 @@ type readCloser struct {
 	salt, iv []byte
 	cycles   int
 	cbc      cipher.BlockMode
-	buf      bytes.Buffer
+	block    [aes.BlockSize]byte
+	buf      []byte // decrypted bytes left in block
 }
 
 func (rc *readCloser) Close() error {
@@ -41,6 +41,15 @@ func (rc *readCloser) Close() error {
 }
 
 func (rc *readCloser) Password(p string) error {
+	b := []byte(p)
+	defer clear(b)
+
+	return rc.PasswordBytes(b)
+}
+
+// PasswordBytes is like Password but takes the password as a byte slice,
+// which isn't retained.
+func (rc *readCloser) PasswordBytes(p []byte) error {
 	key, err := calculateKey(p, rc.cycles, rc.salt)
 	if err != nil {
 		return err
@@ -65,30 +74,62 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 		return 0, errNoPasswordSet
 	}
 
-	var block [aes.BlockSize]byte
+	// Start with anything left over from decrypting a single block
+	n := copy(p, rc.buf)
+	rc.buf = rc.buf[n:]
 
-	for rc.buf.Len() < len(p) {
-		if _, err := io.ReadFull(rc.rc, block[:]); err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
+	// Decrypt as many whole blocks as fit directly into p
+	if blocks := (len(p) - n) &^ (aes.BlockSize - 1); blocks > 0 {
+		m, err := rc.readBlocks(p[n : n+blocks])
+		n += m
 
-			return 0, fmt.Errorf("aes7z: error reading block: %w", err)
+		if err != nil || m < blocks {
+			return eofIfEmpty(n, err)
 		}
+	}
 
-		rc.cbc.CryptBlocks(block[:], block[:])
+	// Fill any remainder smaller than a block from a single block
+	if n < len(p) {
+		if _, err := rc.readBlocks(rc.block[:]); err != nil {
+			return eofIfEmpty(n, err)
+		}
 
-		_, _ = rc.buf.Write(block[:])
+		m := copy(p[n:], rc.block[:])
+		rc.buf = rc.block[m:]
+		n += m
 	}
 
-	n, err := rc.buf.Read(p)
-	if err != nil && !errors.Is(err, io.EOF) {
-		err = fmt.Errorf("aes7z: error reading: %w", err)
+	return n, nil
+}
+
+// eofIfEmpty only returns io.EOF if nothing has been read.
+func eofIfEmpty(n int, err error) (int, error) {
+	if n > 0 && errors.Is(err, io.EOF) {
+		return n, nil
 	}
 
 	return n, err
 }
 
+// readBlocks reads and decrypts enough blocks to fill p, or as many as are
+// left.
+func (rc *readCloser) readBlocks(p []byte) (int, error) {
+	n, err := io.ReadFull(rc.rc, p)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return 0, io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF) && n%aes.BlockSize == 0:
+		// The stream ended on a block boundary
+	case err != nil:
+		return 0, fmt.Errorf("aes7z: error reading block: %w", err)
+	}
+
+	rc.cbc.CryptBlocks(p[:n], p[:n])
+
+	return n, nil
+}
+
 // NewReader returns a new AES-256-CBC & SHA-256 io.ReadCloser. The Password
 // method must be called before attempting to call Read so that the block
 // cipher is correctly initialised.
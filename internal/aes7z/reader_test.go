@@ -0,0 +1,120 @@
+package aes7z
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"strconv"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encrypt returns the properties and ciphertext for plaintext, which must be
+// a multiple of the block size, as encrypted by 7-zip with password.
+func encrypt(tb testing.TB, password string, plaintext []byte) ([]byte, []byte) {
+	tb.Helper()
+
+	// One salt byte and a full IV, with 2^1 rounds to keep it quick
+	props := []byte{0xc0 | 1, 0x00 | (aes.BlockSize - 1), 0x5a}
+	iv := make([]byte, aes.BlockSize)
+	_, err := rand.Read(iv)
+	require.NoError(tb, err)
+
+	props = append(props, iv...)
+
+	block, err := aes.NewCipher(deriveKey([]byte(password), 1, props[2:3]))
+	require.NoError(tb, err)
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	return props, ciphertext
+}
+
+func newTestReader(tb testing.TB, props, ciphertext []byte) io.ReadCloser {
+	tb.Helper()
+
+	rc, err := NewReader(props, 0, []io.ReadCloser{io.NopCloser(bytes.NewReader(ciphertext))})
+	require.NoError(tb, err)
+
+	require.NoError(tb, rc.(*readCloser).PasswordBytes([]byte("password"))) //nolint:forcetypeassert
+
+	return rc
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	plaintext := make([]byte, 64<<10)
+	_, err := rand.Read(plaintext)
+	require.NoError(t, err)
+
+	props, ciphertext := encrypt(t, "password", plaintext)
+
+	for _, size := range []int{1, 7, aes.BlockSize, 100, 4096, len(plaintext) + 1} {
+		size := size
+
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			t.Parallel()
+
+			rc := newTestReader(t, props, ciphertext)
+			defer rc.Close()
+
+			b, err := io.ReadAll(&limitedReader{rc, size})
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, b)
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+
+		rc := newTestReader(t, props, ciphertext[:len(ciphertext)-1])
+		defer rc.Close()
+
+		_, err := io.ReadAll(rc)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("one byte", func(t *testing.T) {
+		t.Parallel()
+
+		rc := newTestReader(t, props, ciphertext)
+		defer rc.Close()
+
+		require.NoError(t, iotest.TestReader(rc, plaintext))
+	})
+}
+
+// limitedReader reads at most n bytes at a time.
+type limitedReader struct {
+	r io.Reader
+	n int
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	return lr.r.Read(p[:min(len(p), lr.n)]) //nolint:wrapcheck
+}
+
+func BenchmarkReader(b *testing.B) {
+	plaintext := make([]byte, 4<<20)
+	props, ciphertext := encrypt(b, "password", plaintext)
+
+	b.SetBytes(int64(len(plaintext)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rc := newTestReader(b, props, ciphertext)
+
+		if _, err := io.Copy(io.Discard, rc); err != nil {
+			b.Fatal(err)
+		}
+
+		_ = rc.Close()
+	}
+}
@@ -2,7 +2,6 @@
 package bcj2
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -12,7 +11,7 @@ import (
 )
 
 type readCloser struct {
-	main util.ReadCloser
+	main io.ReadCloser
 	call io.ReadCloser
 	jump io.ReadCloser
 
@@ -25,9 +24,19 @@ type readCloser struct {
 	previous byte
 	written  uint32
 
-	buf *bytes.Buffer
+	// buf holds bytes read from the main stream, of which buf[pos:end]
+	// are still to be processed
+	buf      []byte
+	pos, end int
+
+	// dest holds a converted branch target, of which pending is what
+	// didn't fit in the caller's buffer
+	dest    [4]byte
+	pending []byte
 }
 
+const bufSize = 1 << 16
+
 const (
 	numMoveBits               = 5
 	numbitModelTotalBits      = 11
@@ -39,6 +48,7 @@ const (
 var (
 	errAlreadyClosed   = errors.New("bcj2: already closed")
 	errNeedFourReaders = errors.New("bcj2: need exactly four readers")
+	errInvalidRead     = errors.New("bcj2: invalid read count")
 )
 
 func isJcc(b0, b1 byte) bool {
@@ -67,14 +77,13 @@ func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, erro
 	}
 
 	rc := &readCloser{
-		main:   util.ByteReadCloser(readers[0]),
+		main:   readers[0],
 		call:   readers[1],
 		jump:   readers[2],
 		rd:     util.ByteReadCloser(readers[3]),
 		nrange: 0xffffffff,
-		buf:    new(bytes.Buffer),
+		buf:    make([]byte, bufSize),
 	}
-	rc.buf.Grow(1 << 16)
 
 	b := make([]byte, 5)
 	if _, err := io.ReadFull(rc.rd, b); err != nil {
@@ -110,21 +119,81 @@ func (rc *readCloser) Close() error {
 	return nil
 }
 
+//nolint:cyclop
 func (rc *readCloser) Read(p []byte) (int, error) {
 	if rc.main == nil || rc.call == nil || rc.jump == nil || rc.rd == nil {
 		return 0, errAlreadyClosed
 	}
 
-	if err := rc.read(); err != nil && !errors.Is(err, io.EOF) {
-		return 0, err
-	}
+	n := copy(p, rc.pending)
+	rc.pending = rc.pending[n:]
+
+	for n < len(p) {
+		if rc.pos == rc.end {
+			if err := rc.fill(); err != nil {
+				if n > 0 && errors.Is(err, io.EOF) {
+					err = nil
+				}
+
+				return n, err
+			}
+		}
+
+		// Copy everything up to the next branch opcode in one go
+		chunk := rc.buf[rc.pos:min(rc.end, rc.pos+len(p)-n)]
+		previous := rc.previous
+
+		i := 0
+		for ; i < len(chunk) && !isJ(previous, chunk[i]); i++ {
+			previous = chunk[i]
+		}
+
+		n += copy(p[n:], chunk[:i])
+		rc.pos += i
+		rc.written += uint32(i) //nolint:gosec
+		rc.previous = previous
+
+		if i == len(chunk) {
+			continue
+		}
 
-	n, err := rc.buf.Read(p)
-	if err != nil && !errors.Is(err, io.EOF) {
-		err = fmt.Errorf("bcj2: error reading: %w", err)
+		b := chunk[i]
+		p[n] = b
+		n++
+		rc.pos++
+		rc.written++
+
+		if err := rc.branch(b); err != nil {
+			return n, err
+		}
+
+		m := copy(p[n:], rc.pending)
+		rc.pending = rc.pending[m:]
+		n += m
 	}
 
-	return n, err
+	return n, nil
+}
+
+// fill reads more of the main stream.
+func (rc *readCloser) fill() error {
+	for {
+		n, err := rc.main.Read(rc.buf)
+		if n < 0 || n > len(rc.buf) {
+			return errInvalidRead
+		}
+
+		rc.pos, rc.end = 0, n
+
+		switch {
+		case n > 0:
+			return nil
+		case errors.Is(err, io.EOF):
+			return io.EOF
+		case err != nil:
+			return fmt.Errorf("bcj2: error reading: %w", err)
+		}
+	}
 }
 
 func (rc *readCloser) update() error {
@@ -166,67 +235,40 @@ func (rc *readCloser) decode(i int) (bool, error) {
 	return true, nil
 }
 
-//nolint:cyclop,funlen
-func (rc *readCloser) read() error {
-	var (
-		b   byte
-		err error
-	)
-
-	for {
-		if b, err = rc.main.ReadByte(); err != nil {
-			if !errors.Is(err, io.EOF) {
-				err = fmt.Errorf("bcj2: error reading byte: %w", err)
-			}
-
-			return err
-		}
-
-		rc.written++
-		_ = rc.buf.WriteByte(b)
-
-		if isJ(rc.previous, b) {
-			break
-		}
+// branch decodes whether the branch opcode b was converted and, if it was,
+// converts its target back and leaves it pending.
+func (rc *readCloser) branch(b byte) error {
+	bit, err := rc.decode(index(rc.previous, b))
+	if err != nil {
+		return err
+	}
 
+	if !bit {
 		rc.previous = b
 
-		if rc.buf.Len() == rc.buf.Cap() {
-			return nil
-		}
+		return nil
 	}
 
-	bit, err := rc.decode(index(rc.previous, b))
-	if err != nil {
-		return err
+	r := rc.jump
+	if b == 0xe8 {
+		r = rc.call
 	}
 
-	//nolint:nestif
-	if bit {
-		var r io.Reader
-		if b == 0xe8 {
-			r = rc.call
-		} else {
-			r = rc.jump
+	if _, err := io.ReadFull(r, rc.dest[:]); err != nil {
+		// The main stream needs a target that isn't there
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
 		}
 
-		var dest uint32
-		if err = binary.Read(r, binary.BigEndian, &dest); err != nil {
-			if !errors.Is(err, io.EOF) {
-				err = fmt.Errorf("bcj2: error reading uint32: %w", err)
-			}
-
-			return err
-		}
+		return fmt.Errorf("bcj2: error reading uint32: %w", err)
+	}
 
-		dest -= rc.written + 4
-		_ = binary.Write(rc.buf, binary.LittleEndian, dest)
+	dest := binary.BigEndian.Uint32(rc.dest[:]) - (rc.written + 4)
+	binary.LittleEndian.PutUint32(rc.dest[:], dest)
+	rc.pending = rc.dest[:]
 
-		rc.previous = byte(dest >> 24)
-		rc.written += 4
-	} else {
-		rc.previous = b
-	}
+	rc.previous = byte(dest >> 24)
+	rc.written += 4
 
 	return nil
 }
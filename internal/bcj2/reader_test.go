@@ -0,0 +1,320 @@
+package bcj2_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/bodgit/sevenzip/internal/bcj2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encoder produces the four streams that the reader decodes, converting
+// each branch target that convert allows.
+type encoder struct {
+	main, call, jump []byte
+
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize uint64
+	rc        []byte
+
+	sd [256 + 2]uint32
+}
+
+func (e *encoder) shiftLow() {
+	if uint32(e.low) < 0xff000000 || e.low>>32 != 0 {
+		temp := e.cache
+
+		for {
+			e.rc = append(e.rc, temp+byte(e.low>>32))
+			temp = 0xff
+
+			if e.cacheSize--; e.cacheSize == 0 {
+				break
+			}
+		}
+
+		e.cache = byte(uint32(e.low) >> 24)
+	}
+
+	e.cacheSize++
+	e.low = uint64(uint32(e.low) << 8)
+}
+
+func (e *encoder) encodeBit(i int, bit bool) {
+	bound := (e.rng >> 11) * e.sd[i]
+
+	if bit {
+		e.low += uint64(bound)
+		e.rng -= bound
+		e.sd[i] -= e.sd[i] >> 5
+	} else {
+		e.rng = bound
+		e.sd[i] += (1<<11 - e.sd[i]) >> 5
+	}
+
+	for e.rng < 1<<24 {
+		e.rng <<= 8
+		e.shiftLow()
+	}
+}
+
+func isJ(b0, b1 byte) bool {
+	return b1&0xfe == 0xe8 || b0 == 0x0f && b1&0xf0 == 0x80
+}
+
+func encode(b []byte, convert func(int) bool) *encoder {
+	e := &encoder{rng: 0xffffffff, cacheSize: 1}
+	for i := range e.sd {
+		e.sd[i] = 1 << 10
+	}
+
+	var previous byte
+
+	for i := 0; i < len(b); {
+		c := b[i]
+		e.main = append(e.main, c)
+		i++
+
+		if !isJ(previous, c) {
+			previous = c
+
+			continue
+		}
+
+		index := 257
+
+		switch c {
+		case 0xe8:
+			index = int(previous)
+		case 0xe9:
+			index = 256
+		}
+
+		bit := i+4 <= len(b) && convert(i)
+		e.encodeBit(index, bit)
+
+		if !bit {
+			previous = c
+
+			continue
+		}
+
+		dest := binary.BigEndian.AppendUint32(nil, binary.LittleEndian.Uint32(b[i:])+uint32(i)+4) //nolint:gosec
+		if c == 0xe8 {
+			e.call = append(e.call, dest...)
+		} else {
+			e.jump = append(e.jump, dest...)
+		}
+
+		previous = b[i+3]
+		i += 4
+	}
+
+	for i := 0; i < 5; i++ {
+		e.shiftLow()
+	}
+
+	return e
+}
+
+func newReader(main, call, jump, rc []byte) (io.ReadCloser, error) {
+	return bcj2.NewReader(nil, 0, []io.ReadCloser{
+		io.NopCloser(bytes.NewReader(main)),
+		io.NopCloser(bytes.NewReader(call)),
+		io.NopCloser(bytes.NewReader(jump)),
+		io.NopCloser(bytes.NewReader(rc)),
+	})
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	// The range coder streams decode a single 1 and 0 bit respectively
+	one := []byte{0x00, 0x7f, 0xff, 0xfc, 0x00}
+	zero := []byte{0x00, 0x00, 0x00, 0x00, 0x00}
+
+	tables := []struct {
+		name                 string
+		main, call, jump, rc []byte
+		want                 []byte
+	}{
+		{
+			name: "no branches",
+			main: []byte("hello, world"),
+			rc:   zero,
+			want: []byte("hello, world"),
+		},
+		{
+			name: "call",
+			main: []byte{0xe8},
+			call: []byte{0x12, 0x34, 0x56, 0x7d},
+			rc:   one,
+			want: []byte{0xe8, 0x78, 0x56, 0x34, 0x12},
+		},
+		{
+			name: "jump",
+			main: []byte{0x90, 0xe9},
+			jump: []byte{0x12, 0x34, 0x56, 0x7e},
+			rc:   one,
+			want: []byte{0x90, 0xe9, 0x78, 0x56, 0x34, 0x12},
+		},
+		{
+			name: "conditional jump",
+			main: []byte{0x0f, 0x84},
+			jump: []byte{0x12, 0x34, 0x56, 0x7e},
+			rc:   one,
+			want: []byte{0x0f, 0x84, 0x78, 0x56, 0x34, 0x12},
+		},
+		{
+			name: "unconverted call",
+			main: []byte{0xe8, 0x01, 0x02},
+			rc:   zero,
+			want: []byte{0xe8, 0x01, 0x02},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			rc, err := newReader(table.main, table.call, table.jump, table.rc)
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			assert.Equal(t, table.want, got)
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// Build something with plenty of branch opcodes, more than fits in
+	// the buffer for the main stream
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	opcodes := []byte{0xe8, 0xe9, 0x0f, 0x80, 0x85, 0x8f}
+
+	b := make([]byte, 1<<17+3)
+	for i := range b {
+		if rnd.Intn(4) == 0 {
+			b[i] = opcodes[rnd.Intn(len(opcodes))]
+		} else {
+			b[i] = byte(rnd.Intn(256))
+		}
+	}
+
+	e := encode(b, func(int) bool { return rnd.Intn(3) != 0 })
+	require.NotEmpty(t, e.call)
+	require.NotEmpty(t, e.jump)
+
+	readers := []struct {
+		name string
+		fn   func(io.Reader) io.Reader
+	}{
+		{"full", func(r io.Reader) io.Reader { return r }},
+		{"half", iotest.HalfReader},
+		{"onebyte", iotest.OneByteReader},
+	}
+
+	for _, r := range readers {
+		r := r
+
+		t.Run(r.name, func(t *testing.T) {
+			t.Parallel()
+
+			rc, err := newReader(e.main, e.call, e.jump, e.rc)
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(r.fn(rc))
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			assert.Equal(t, b, got)
+		})
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	t.Parallel()
+
+	one := []byte{0x00, 0x7f, 0xff, 0xfc, 0x00}
+
+	tables := []struct {
+		name                 string
+		main, call, jump, rc []byte
+		err                  error
+	}{
+		{
+			name: "no range coder",
+			err:  io.EOF,
+		},
+		{
+			name: "short range coder",
+			rc:   one[:3],
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "short call",
+			main: []byte{0xe8},
+			call: []byte{0x12, 0x34},
+			rc:   one,
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "short jump",
+			main: []byte{0xe9},
+			jump: []byte{0x12, 0x34, 0x56},
+			rc:   one,
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "missing call",
+			main: []byte{0xe8},
+			rc:   one,
+			err:  io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			rc, err := newReader(table.main, table.call, table.jump, table.rc)
+			if err == nil {
+				_, err = io.ReadAll(rc)
+			}
+
+			assert.ErrorIs(t, err, table.err)
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	t.Parallel()
+
+	_, err := bcj2.NewReader(nil, 0, []io.ReadCloser{io.NopCloser(bytes.NewReader(nil))})
+	assert.Error(t, err)
+
+	rc, err := newReader(nil, nil, nil, make([]byte, 5))
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	assert.Error(t, rc.Close())
+
+	_, err = rc.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package bra
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const arm64Alignment = 4
+
+type arm64 struct {
+	ip uint32
+}
+
+func (c *arm64) Size() int { return arm64Alignment }
+
+func (c *arm64) Convert(b []byte, encoding bool) int {
+	if len(b) < c.Size() {
+		return 0
+	}
+
+	var i int
+
+	for i = 0; i < len(b) & ^(arm64Alignment-1); i += arm64Alignment {
+		v := binary.LittleEndian.Uint32(b[i:])
+		pc := c.ip + uint32(i) //nolint:gosec
+
+		switch {
+		case v>>26 == 0x25:
+			// BL, with a 26-bit word offset
+			pc >>= 2
+			if !encoding {
+				pc = -pc
+			}
+
+			v = 0x94000000 | (v+pc)&0x03ffffff
+		case v&0x9f000000 == 0x90000000:
+			// ADRP, with a 21-bit page offset split across the
+			// instruction. Only offsets within +/-512 MiB are
+			// converted, which is enough for most executables while
+			// leaving unrelated data alone
+			src := (v>>29)&3 | (v>>3)&0x001ffffc
+			if (src+0x00020000)&0x001c0000 != 0 {
+				continue
+			}
+
+			pc >>= 12
+			if !encoding {
+				pc = -pc
+			}
+
+			dest := src + pc
+			v &= 0x9000001f
+			v |= (dest & 3) << 29
+			v |= (dest & 0x0003fffc) << 3
+			v |= -(dest & 0x00020000) & 0x00e00000
+		default:
+			continue
+		}
+
+		binary.LittleEndian.PutUint32(b[i:], v)
+	}
+
+	c.ip += uint32(i) //nolint:gosec
+
+	return i
+}
+
+// NewARM64Reader returns a new ARM64 io.ReadCloser. The properties can
+// optionally hold the start offset of the stream.
+func NewARM64Reader(p []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	ip, err := startOffset(p, arm64Alignment)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReader(readers, &arm64{ip: ip})
+}
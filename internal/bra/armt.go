@@ -0,0 +1,60 @@
+package bra
+
+import (
+	"io"
+)
+
+const (
+	armtAlignment = 2
+	armtLookAhead = 4
+)
+
+type armt struct {
+	ip uint32
+}
+
+func (c *armt) Size() int { return armtLookAhead }
+
+func (c *armt) Convert(b []byte, encoding bool) int {
+	if len(b) < c.Size() {
+		return 0
+	}
+
+	var i int
+
+	for i = 0; i <= len(b)-armtLookAhead; i += armtAlignment {
+		if b[i+1]&0xf8 != 0xf0 || b[i+3]&0xf8 != 0xf8 {
+			continue
+		}
+
+		// The BL instruction is split across two 16-bit halves
+		v := (uint32(b[i+1])&7)<<19 | uint32(b[i+0])<<11 | (uint32(b[i+3])&7)<<8 | uint32(b[i+2])
+		v <<= 1
+
+		ip := c.ip + armtLookAhead + uint32(i) //nolint:gosec
+
+		if encoding {
+			v += ip
+		} else {
+			v -= ip
+		}
+
+		v >>= 1
+
+		b[i+1] = 0xf0 | byte((v>>19)&7)
+		b[i+0] = byte(v >> 11)
+		b[i+3] = 0xf8 | byte((v>>8)&7)
+		b[i+2] = byte(v)
+
+		i += armtAlignment
+	}
+
+	c.ip += uint32(i) //nolint:gosec
+
+	return i
+}
+
+// NewARMTReader returns a new ARMT io.ReadCloser.
+func NewARMTReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return newReader(readers, new(armt))
+}
@@ -1,7 +1,40 @@
 // Package bra implements the branch rewriting filter for binaries.
 package bra
 
-type converter interface {
+import "io"
+
+// Converter converts the branch instructions of a particular architecture.
+type Converter interface {
 	Size() int
 	Convert(b []byte, encoding bool) int
 }
+
+// NewARM returns a new ARM Converter.
+func NewARM() Converter { return new(arm) }
+
+// NewARM64 returns a new ARM64 Converter.
+func NewARM64() Converter { return new(arm64) }
+
+// NewARMT returns a new ARM Thumb Converter.
+func NewARMT() Converter { return new(armt) }
+
+// NewBCJ returns a new x86 Converter.
+func NewBCJ() Converter { return new(bcj) }
+
+// NewIA64 returns a new IA64 Converter.
+func NewIA64() Converter { return new(ia64) }
+
+// NewPPC returns a new PowerPC Converter.
+func NewPPC() Converter { return new(ppc) }
+
+// NewRISCV returns a new RISC-V Converter.
+func NewRISCV() Converter { return new(riscv) }
+
+// NewSPARC returns a new SPARC Converter.
+func NewSPARC() Converter { return new(sparc) }
+
+// NewReader returns a new io.ReadCloser that decodes the branches converted
+// by conv.
+func NewReader(readers []io.ReadCloser, conv Converter) (io.ReadCloser, error) {
+	return newReader(readers, conv)
+}
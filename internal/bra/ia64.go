@@ -0,0 +1,88 @@
+package bra
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const ia64Alignment = 16
+
+//nolint:gochecknoglobals
+var ia64BranchTable = [32]byte{
+	0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	4, 4, 6, 6, 0, 0, 7, 7,
+	4, 4, 0, 0, 4, 4, 0, 0,
+}
+
+type ia64 struct {
+	ip uint32
+}
+
+func (c *ia64) Size() int { return ia64Alignment }
+
+func (c *ia64) Convert(b []byte, encoding bool) int {
+	if len(b) < c.Size() {
+		return 0
+	}
+
+	var i int
+
+	for i = 0; i <= len(b)-ia64Alignment; i += ia64Alignment {
+		// Each bundle has a 5-bit template followed by three 41-bit
+		// instruction slots, the template says which slots can hold
+		// branch instructions
+		mask := ia64BranchTable[b[i]&0x1f]
+
+		for slot, bitPos := 0, 5; slot < 3; slot, bitPos = slot+1, bitPos+41 {
+			if (mask>>slot)&1 == 0 {
+				continue
+			}
+
+			bytePos, bitRes := i+bitPos>>3, bitPos&7
+
+			var buf [8]byte
+
+			copy(buf[:], b[bytePos:bytePos+6])
+			instruction := binary.LittleEndian.Uint64(buf[:])
+			norm := instruction >> bitRes
+
+			if (norm>>37)&0xf != 0x5 || (norm>>9)&0x7 != 0 {
+				continue
+			}
+
+			v := uint32((norm >> 13) & 0xfffff)
+			v |= uint32((norm>>36)&1) << 20
+			v <<= 4
+
+			ip := c.ip + uint32(i) //nolint:gosec
+
+			if encoding {
+				v += ip
+			} else {
+				v -= ip
+			}
+
+			v >>= 4
+
+			norm &^= uint64(0x8fffff) << 13
+			norm |= uint64(v&0xfffff) << 13
+			norm |= uint64(v&0x100000) << (36 - 20)
+
+			instruction &= 1<<bitRes - 1
+			instruction |= norm << bitRes
+
+			binary.LittleEndian.PutUint64(buf[:], instruction)
+			copy(b[bytePos:bytePos+6], buf[:])
+		}
+	}
+
+	c.ip += uint32(i) //nolint:gosec
+
+	return i
+}
+
+// NewIA64Reader returns a new IA64 io.ReadCloser.
+func NewIA64Reader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return newReader(readers, new(ia64))
+}
@@ -2,6 +2,7 @@ package bra
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -11,14 +12,19 @@ type readCloser struct {
 	rc   io.ReadCloser
 	buf  bytes.Buffer
 	n    int
-	conv converter
+	conv Converter
 }
 
 var (
-	errAlreadyClosed = errors.New("bra: already closed")
-	errNeedOneReader = errors.New("bra: need exactly one reader")
+	errAlreadyClosed      = errors.New("bra: already closed")
+	errNeedOneReader      = errors.New("bra: need exactly one reader")
+	errInvalidStartOffset = errors.New("bra: invalid start offset")
 )
 
+// maxBuffer is the most that is buffered ahead of the converter, however
+// large the caller's buffer.
+const maxBuffer = 1 << 16
+
 func (rc *readCloser) Close() error {
 	if rc.rc == nil {
 		return errAlreadyClosed
@@ -38,11 +44,15 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 		return 0, errAlreadyClosed
 	}
 
-	if _, err := io.CopyN(&rc.buf, rc.rc, int64(max(len(p), rc.conv.Size())-rc.buf.Len())); err != nil {
+	if _, err := io.CopyN(&rc.buf, rc.rc, int64(max(min(len(p), maxBuffer), rc.conv.Size())-rc.buf.Len())); err != nil {
 		if !errors.Is(err, io.EOF) {
 			return 0, fmt.Errorf("bra: error buffering: %w", err)
 		}
 
+		if rc.buf.Len() == 0 {
+			return 0, io.EOF
+		}
+
 		if rc.buf.Len() < rc.conv.Size() {
 			rc.n = rc.buf.Len()
 		}
@@ -60,7 +70,7 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func newReader(readers []io.ReadCloser, conv converter) (io.ReadCloser, error) {
+func newReader(readers []io.ReadCloser, conv Converter) (io.ReadCloser, error) {
 	if len(readers) != 1 {
 		return nil, errNeedOneReader
 	}
@@ -70,3 +80,22 @@ func newReader(readers []io.ReadCloser, conv converter) (io.ReadCloser, error) {
 		conv: conv,
 	}, nil
 }
+
+// startOffset returns the start offset of the stream from the properties,
+// which are either empty or hold it as a little-endian uint32 that must be a
+// multiple of alignment.
+func startOffset(p []byte, alignment uint32) (uint32, error) {
+	switch len(p) {
+	case 0:
+		return 0, nil
+	case 4:
+		ip := binary.LittleEndian.Uint32(p)
+		if ip%alignment != 0 {
+			return 0, errInvalidStartOffset
+		}
+
+		return ip, nil
+	default:
+		return 0, errInvalidStartOffset
+	}
+}
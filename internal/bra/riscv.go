@@ -0,0 +1,143 @@
+package bra
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	riscvAlignment = 2
+	riscvLookAhead = 8
+)
+
+type riscv struct {
+	ip uint32
+}
+
+func (c *riscv) Size() int { return riscvLookAhead }
+
+// notAUIPCPair reports whether inst2 doesn't use the register set by the
+// AUIPC instruction or doesn't have the lowest two opcode bits set.
+func notAUIPCPair(auipc, inst2 uint32) bool {
+	return (auipc<<8^(inst2-3))&0xf8003 != 0
+}
+
+// notSpecialAUIPC reports whether the AUIPC instruction doesn't use the x2
+// register with the lowest two opcode bits of the packed second instruction
+// set, or if the packed register is x0 or x2.
+func notSpecialAUIPC(auipc, rs1 uint32) bool {
+	return (auipc-0x3117)<<18 >= rs1&0x1d
+}
+
+func (c *riscv) jal(b []byte, pc uint32, encoding bool) {
+	b1, b2, b3 := uint32(b[1]), uint32(b[2]), uint32(b[3])
+
+	if encoding {
+		addr := (b1&0xf0)<<8 | (b2&0x0f)<<16 | (b2&0x10)<<7 | (b2&0xe0)>>4 | (b3&0x7f)<<4 | (b3&0x80)<<13
+		addr += pc
+
+		b[1] = byte(b1&0x0f | (addr>>13)&0xf0)
+		b[2] = byte(addr >> 9)
+		b[3] = byte(addr >> 1)
+
+		return
+	}
+
+	addr := (b1&0xf0)<<13 | b2<<9 | b3<<1
+	addr -= pc
+
+	b[1] = byte(b1&0x0f | (addr>>8)&0xf0)
+	b[2] = byte((addr>>16)&0x0f | (addr>>7)&0x10 | (addr<<4)&0xe0)
+	b[3] = byte((addr>>4)&0x7f | (addr>>13)&0x80)
+}
+
+//nolint:cyclop,funlen
+func (c *riscv) Convert(b []byte, encoding bool) int {
+	if len(b) < c.Size() {
+		return 0
+	}
+
+	var i int
+
+	for i = 0; i <= len(b)-riscvLookAhead; i += riscvAlignment {
+		pc := c.ip + uint32(i) //nolint:gosec
+
+		switch inst := uint32(b[i]); {
+		case inst == 0xef:
+			// JAL with rd set to x1 or x5
+			if b[i+1]&0x0d != 0 {
+				continue
+			}
+
+			c.jal(b[i:], pc, encoding)
+
+			i += 4 - riscvAlignment
+		case inst&0x7f == 0x17:
+			// AUIPC, possibly paired with the following instruction
+			inst = binary.LittleEndian.Uint32(b[i:])
+
+			var inst2 uint32
+
+			switch {
+			case inst&0xe80 != 0:
+				// The rd register isn't x0 or x2
+				inst2 = binary.LittleEndian.Uint32(b[i+4:])
+
+				if notAUIPCPair(inst, inst2) {
+					i += 6 - riscvAlignment
+
+					continue
+				}
+
+				addr := inst & 0xfffff000
+
+				if encoding {
+					addr += inst2>>20 - (inst2>>19)&0x1000
+					addr += pc
+					inst = 0x17 | 2<<7 | inst2<<12
+
+					binary.BigEndian.PutUint32(b[i+4:], addr)
+				} else {
+					addr += inst2 >> 20
+					inst = 0x17 | 2<<7 | inst2<<12
+
+					binary.LittleEndian.PutUint32(b[i+4:], addr)
+				}
+			default:
+				rs1 := inst >> 27
+
+				if notSpecialAUIPC(inst, rs1) {
+					i += 4 - riscvAlignment
+
+					continue
+				}
+
+				if encoding {
+					addr := binary.LittleEndian.Uint32(b[i+4:])
+					inst2 = inst>>12 | addr<<20
+					inst = 0x17 | rs1<<7 | addr&0xfffff000
+				} else {
+					addr := binary.BigEndian.Uint32(b[i+4:])
+					addr -= pc
+					inst2 = inst>>12 | addr<<20
+					inst = 0x17 | rs1<<7 | (addr+0x800)&0xfffff000
+				}
+
+				binary.LittleEndian.PutUint32(b[i+4:], inst2)
+			}
+
+			binary.LittleEndian.PutUint32(b[i:], inst)
+
+			i += 8 - riscvAlignment
+		}
+	}
+
+	c.ip += uint32(i) //nolint:gosec
+
+	return i
+}
+
+// NewRISCVReader returns a new RISC-V io.ReadCloser.
+func NewRISCVReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return newReader(readers, new(riscv))
+}
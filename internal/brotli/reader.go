@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"sync"
 
 	"github.com/andybalholm/brotli"
 	"github.com/bodgit/plumbing"
@@ -25,9 +24,6 @@ const (
 )
 
 var (
-	//nolint:gochecknoglobals
-	brotliReaderPool sync.Pool
-
 	errAlreadyClosed = errors.New("brotli: already closed")
 	errNeedOneReader = errors.New("brotli: need exactly one reader")
 )
@@ -42,7 +38,7 @@ type headerFrame struct {
 }
 
 func (rc *readCloser) Close() error {
-	if rc.c == nil || rc.r == nil {
+	if rc.c == nil {
 		return errAlreadyClosed
 	}
 
@@ -50,14 +46,14 @@ func (rc *readCloser) Close() error {
 		return fmt.Errorf("brotli: error closing: %w", err)
 	}
 
-	brotliReaderPool.Put(rc.r)
-	rc.c, rc.r = nil, nil
+	// Keep the decoder so Reset can reuse it
+	rc.c = nil
 
 	return nil
 }
 
 func (rc *readCloser) Read(p []byte) (int, error) {
-	if rc.r == nil {
+	if rc.c == nil {
 		return 0, errAlreadyClosed
 	}
 
@@ -69,10 +65,10 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 	return n, err
 }
 
-// NewReader returns a new Brotli io.ReadCloser.
-func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+// Reset reinitialises rc to read a new stream, reusing the decoder.
+func (rc *readCloser) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
 	if len(readers) != 1 {
-		return nil, errNeedOneReader
+		return errNeedOneReader
 	}
 
 	hr, b := new(headerFrame), new(bytes.Buffer)
@@ -86,7 +82,7 @@ func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, erro
 			err = fmt.Errorf("brotli: error reading frame: %w", err)
 		}
 
-		return nil, err
+		return err
 	}
 
 	var reader io.ReadCloser
@@ -99,15 +95,24 @@ func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, erro
 		reader = plumbing.MultiReadCloser(io.NopCloser(b), readers[0])
 	}
 
-	r, ok := brotliReaderPool.Get().(*brotli.Reader)
-	if ok {
-		_ = r.Reset(reader)
-	} else {
-		r = brotli.NewReader(reader)
+	if rc.r == nil {
+		rc.r = brotli.NewReader(reader)
+	} else if err := rc.r.Reset(reader); err != nil {
+		return fmt.Errorf("brotli: error resetting: %w", err)
+	}
+
+	rc.c = readers[0]
+
+	return nil
+}
+
+// NewReader returns a new Brotli io.ReadCloser.
+func NewReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	rc := new(readCloser)
+
+	if err := rc.Reset(p, s, readers); err != nil {
+		return nil, err
 	}
 
-	return &readCloser{
-		c: readers[0],
-		r: r,
-	}, nil
+	return rc, nil
 }
@@ -0,0 +1,98 @@
+// Package cache implements a cache of decoded streams.
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a LRU cache of byte slices keyed by their stream index. It is
+// bounded by the total size of the byte slices rather than their number.
+type Cache struct {
+	mutex     sync.Mutex
+	size      int64
+	used      int64
+	evictList *list.List
+	items     map[int]*list.Element
+}
+
+type entry struct {
+	key   int
+	value []byte
+}
+
+// New returns a Cache that holds at most size bytes.
+func New(size int64) *Cache {
+	return &Cache{
+		size:      size,
+		evictList: list.New(),
+		items:     make(map[int]*list.Element),
+	}
+}
+
+// Size returns the maximum number of bytes the cache holds.
+func (c *Cache) Size() int64 {
+	return c.size
+}
+
+// Get returns the byte slice for key, if present.
+func (c *Cache) Get(key int) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if ent, ok := c.items[key]; ok {
+		c.evictList.MoveToFront(ent)
+
+		return ent.Value.(*entry).value, true //nolint:forcetypeassert
+	}
+
+	return nil, false
+}
+
+// Put adds the byte slice for key, evicting the least recently used entries
+// to make room. It reports whether b was added, which it isn't if it's
+// larger than the cache.
+func (c *Cache) Put(key int, b []byte) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if int64(len(b)) > c.size {
+		return false
+	}
+
+	if _, ok := c.items[key]; ok {
+		return true
+	}
+
+	for c.used+int64(len(b)) > c.size {
+		c.removeOldest()
+	}
+
+	c.items[key] = c.evictList.PushFront(&entry{key, b})
+	c.used += int64(len(b))
+
+	return true
+}
+
+// Remove removes the byte slice for key, if present.
+func (c *Cache) Remove(key int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if ent, ok := c.items[key]; ok {
+		c.removeElement(ent)
+	}
+}
+
+func (c *Cache) removeOldest() {
+	if ent := c.evictList.Back(); ent != nil {
+		c.removeElement(ent)
+	}
+}
+
+func (c *Cache) removeElement(e *list.Element) {
+	c.evictList.Remove(e)
+	kv := e.Value.(*entry) //nolint:forcetypeassert
+	delete(c.items, kv.key)
+	c.used -= int64(len(kv.value))
+}
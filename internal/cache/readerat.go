@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+var errInvalidBlockSize = errors.New("cache: block size must be positive")
+
+// ReaderAt is an io.ReaderAt that reads an underlying io.ReaderAt in fixed
+// size blocks, caching the most recently used. Reading a 7-zip archive makes
+// many small reads from around the same offsets, such as when parsing the
+// header or when several streams are read in parallel, which this turns into
+// far fewer, larger reads. It is safe for concurrent use.
+type ReaderAt struct {
+	r         io.ReaderAt
+	blockSize int64
+	cache     *lru.Cache[int64, []byte]
+}
+
+// NewReaderAt returns a ReaderAt reading r in blocks of blockSize bytes and
+// caching up to blocks of them.
+func NewReaderAt(r io.ReaderAt, blockSize, blocks int) (*ReaderAt, error) {
+	if blockSize <= 0 {
+		return nil, errInvalidBlockSize
+	}
+
+	c, err := lru.New[int64, []byte](blocks)
+	if err != nil {
+		return nil, fmt.Errorf("cache: error creating cache: %w", err)
+	}
+
+	return &ReaderAt{
+		r:         r,
+		blockSize: int64(blockSize),
+		cache:     c,
+	}, nil
+}
+
+// fetch reads blocks first to last inclusive with a single read, adding them
+// to the cache. Fewer blocks are returned if the end of r is reached, the
+// last of which may be short.
+func (ra *ReaderAt) fetch(first, last int64) ([][]byte, error) {
+	b := make([]byte, (last-first+1)*ra.blockSize)
+
+	n, err := ra.r.ReadAt(b, first*ra.blockSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err //nolint:wrapcheck
+	}
+
+	b = b[:n]
+	blocks := make([][]byte, 0, last-first+1)
+
+	for i := first; len(b) > 0; i++ {
+		block := b[:min(ra.blockSize, int64(len(b)))]
+		b = b[len(block):]
+
+		ra.cache.Add(i, block)
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+// ReadAt implements the io.ReaderAt interface. Any blocks covering p that
+// aren't cached are read, consecutive blocks with a single read.
+func (ra *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return ra.r.ReadAt(p, off) //nolint:wrapcheck
+	}
+
+	first, last := off/ra.blockSize, (off+int64(len(p))-1)/ra.blockSize
+	blocks := make([][]byte, 0, last-first+1)
+
+	for i := first; i <= last; {
+		if b, ok := ra.cache.Get(i); ok {
+			blocks = append(blocks, b)
+
+			if int64(len(b)) < ra.blockSize {
+				break
+			}
+
+			i++
+
+			continue
+		}
+
+		j := i
+		for j < last && !ra.cache.Contains(j+1) {
+			j++
+		}
+
+		fetched, err := ra.fetch(i, j)
+		if err != nil {
+			return 0, err
+		}
+
+		blocks = append(blocks, fetched...)
+
+		if int64(len(fetched)) < j-i+1 {
+			break
+		}
+
+		i = j + 1
+	}
+
+	var n int
+
+	for i, b := range blocks {
+		if i == 0 {
+			skip := off - first*ra.blockSize
+			if skip >= int64(len(b)) {
+				break
+			}
+
+			b = b[skip:]
+		}
+
+		n += copy(p[n:], b)
+
+		if int64(len(blocks[i])) < ra.blockSize {
+			break
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
@@ -0,0 +1,73 @@
+package cache_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/bodgit/sevenzip/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderAt(t *testing.T) {
+	t.Parallel()
+
+	b := make([]byte, 10000)
+	_, _ = rand.New(rand.NewSource(1)).Read(b) //nolint:gosec
+
+	tables := []struct {
+		blockSize, blocks int
+	}{
+		{1, 1},
+		{7, 3},
+		{1000, 2},
+		{4096, 16},
+		{1 << 20, 1},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(strconv.Itoa(table.blockSize)+"x"+strconv.Itoa(table.blocks), func(t *testing.T) {
+			t.Parallel()
+
+			ra, err := cache.NewReaderAt(bytes.NewReader(b), table.blockSize, table.blocks)
+			require.NoError(t, err)
+
+			rnd := rand.New(rand.NewSource(2)) //nolint:gosec
+
+			for i := 0; i < 500; i++ {
+				off := rnd.Int63n(int64(len(b)) + 10)
+				p := make([]byte, rnd.Intn(2000))
+
+				n, err := ra.ReadAt(p, off)
+
+				expected := make([]byte, len(p))
+				en, eerr := bytes.NewReader(b).ReadAt(expected, off)
+
+				assert.Equal(t, en, n)
+				assert.Equal(t, eerr, err)
+				assert.Equal(t, expected[:en], p[:n])
+			}
+		})
+	}
+}
+
+func TestNewReaderAt(t *testing.T) {
+	t.Parallel()
+
+	_, err := cache.NewReaderAt(bytes.NewReader(nil), 0, 1)
+	assert.Error(t, err)
+
+	_, err = cache.NewReaderAt(bytes.NewReader(nil), 1, 0)
+	assert.Error(t, err)
+
+	ra, err := cache.NewReaderAt(bytes.NewReader(nil), 1, 1)
+	require.NoError(t, err)
+
+	_, err = ra.ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(t, err, io.EOF)
+}
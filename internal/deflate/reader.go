@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"sync"
 
 	"github.com/bodgit/sevenzip/internal/util"
 	"github.com/klauspost/compress/flate"
@@ -17,15 +16,12 @@ type readCloser struct {
 }
 
 var (
-	//nolint:gochecknoglobals
-	flateReaderPool sync.Pool
-
 	errAlreadyClosed = errors.New("deflate: already closed")
 	errNeedOneReader = errors.New("deflate: need exactly one reader")
 )
 
 func (rc *readCloser) Close() error {
-	if rc.c == nil || rc.fr == nil {
+	if rc.c == nil {
 		return errAlreadyClosed
 	}
 
@@ -33,14 +29,14 @@ func (rc *readCloser) Close() error {
 		return fmt.Errorf("deflate: error closing: %w", err)
 	}
 
-	flateReaderPool.Put(rc.fr)
-	rc.c, rc.fr = nil, nil
+	// Keep the decoder so Reset can reuse it
+	rc.c = nil
 
 	return nil
 }
 
 func (rc *readCloser) Read(p []byte) (int, error) {
-	if rc.c == nil || rc.fr == nil {
+	if rc.c == nil {
 		return 0, errAlreadyClosed
 	}
 
@@ -52,26 +48,32 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 	return n, err
 }
 
-// NewReader returns a new DEFLATE io.ReadCloser.
-func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+// Reset reinitialises rc to read a new stream, reusing the decoder.
+func (rc *readCloser) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
 	if len(readers) != 1 {
-		return nil, errNeedOneReader
+		return errNeedOneReader
 	}
 
-	fr, ok := flateReaderPool.Get().(io.ReadCloser)
-	if ok {
-		frf, ok := fr.(flate.Resetter)
-		if ok {
-			if err := frf.Reset(util.ByteReadCloser(readers[0]), nil); err != nil {
-				return nil, fmt.Errorf("deflate: error resetting: %w", err)
-			}
+	if frf, ok := rc.fr.(flate.Resetter); ok {
+		if err := frf.Reset(util.ByteReadCloser(readers[0]), nil); err != nil {
+			return fmt.Errorf("deflate: error resetting: %w", err)
 		}
 	} else {
-		fr = flate.NewReader(util.ByteReadCloser(readers[0]))
+		rc.fr = flate.NewReader(util.ByteReadCloser(readers[0]))
+	}
+
+	rc.c = readers[0]
+
+	return nil
+}
+
+// NewReader returns a new DEFLATE io.ReadCloser.
+func NewReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	rc := new(readCloser)
+
+	if err := rc.Reset(p, s, readers); err != nil {
+		return nil, err
 	}
 
-	return &readCloser{
-		c:  readers[0],
-		fr: fr,
-	}, nil
+	return rc, nil
 }
@@ -2,6 +2,7 @@
 package delta
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -47,29 +48,75 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 		return n, fmt.Errorf("delta: error reading: %w", err)
 	}
 
-	var (
-		buffer [stateSize]byte
-		j      int
-	)
+	b := p[:n]
 
-	copy(buffer[:], rc.state[:rc.delta])
+	// The state holds the previous delta bytes, oldest first.
+	for i := 0; i < min(n, rc.delta); i++ {
+		b[i] += rc.state[i]
+	}
 
-	for i := 0; i < n; {
-		for j = 0; j < rc.delta && i < n; i++ {
-			p[i] = buffer[j] + p[i]
-			buffer[j] = p[i]
-			j++
-		}
+	if n > rc.delta {
+		decode(b, rc.delta)
+	}
+
+	if n >= rc.delta {
+		copy(rc.state[:], b[n-rc.delta:])
+	} else {
+		copy(rc.state[:], rc.state[n:rc.delta])
+		copy(rc.state[rc.delta-n:], b)
 	}
 
-	if j == rc.delta {
-		j = 0
+	return n, err //nolint:wrapcheck
+}
+
+// decode adds to each byte of b from delta onwards the byte delta bytes
+// before it. The common small distances get their own loops that keep the
+// previous bytes in registers rather than reloading them from b.
+func decode(b []byte, delta int) {
+	switch delta {
+	case 1:
+		prev := b[0]
+		for i := 1; i < len(b); i++ {
+			prev += b[i]
+			b[i] = prev
+		}
+	case 2:
+		x, y := b[0], b[1]
+
+		i := 2
+		for ; i+1 < len(b); i += 2 {
+			x += b[i]
+			y += b[i+1]
+			b[i], b[i+1] = x, y
+		}
+
+		if i < len(b) {
+			b[i] += x
+		}
+	case 4:
+		prev := binary.LittleEndian.Uint32(b)
+
+		i := 4
+		for ; i+4 <= len(b); i += 4 {
+			prev = add32(prev, binary.LittleEndian.Uint32(b[i:]))
+			binary.LittleEndian.PutUint32(b[i:], prev)
+		}
+
+		for ; i < len(b); i++ {
+			b[i] += b[i-4]
+		}
+	default:
+		for i := delta; i < len(b); i++ {
+			b[i] += b[i-delta]
+		}
 	}
+}
 
-	copy(rc.state[:], buffer[j:rc.delta])
-	copy(rc.state[rc.delta-j:], buffer[:j])
+// add32 adds each byte of x and y without carrying between them.
+func add32(x, y uint32) uint32 {
+	const msb = 0x80808080
 
-	return n, nil
+	return ((x &^ msb) + (y &^ msb)) ^ ((x ^ y) & msb)
 }
 
 // NewReader returns a new Delta io.ReadCloser.
@@ -84,6 +131,6 @@ func NewReader(p []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, erro
 
 	return &readCloser{
 		rc:    readers[0],
-		delta: int(p[0] + 1),
+		delta: int(p[0]) + 1,
 	}, nil
 }
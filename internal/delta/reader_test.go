@@ -0,0 +1,87 @@
+package delta_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strconv"
+	"testing"
+	"testing/iotest"
+
+	"github.com/bodgit/sevenzip/internal/delta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reference decodes b a byte at a time.
+func reference(b []byte, d int) []byte {
+	out := bytes.Clone(b)
+	for i := d; i < len(out); i++ {
+		out[i] += out[i-d]
+	}
+
+	return out
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	b := make([]byte, 1<<16+3)
+	_, _ = rand.New(rand.NewSource(1)).Read(b) //nolint:gosec
+
+	readers := []struct {
+		name string
+		fn   func(io.Reader) io.Reader
+	}{
+		{"full", func(r io.Reader) io.Reader { return r }},
+		{"half", iotest.HalfReader},
+		{"onebyte", iotest.OneByteReader},
+	}
+
+	for _, d := range []int{1, 2, 3, 4, 7, 8, 256} {
+		for _, r := range readers {
+			d, r := d, r
+
+			t.Run(strconv.Itoa(d)+"/"+r.name, func(t *testing.T) {
+				t.Parallel()
+
+				rc, err := delta.NewReader([]byte{byte(d - 1)}, 0, []io.ReadCloser{io.NopCloser(r.fn(bytes.NewReader(b)))})
+				require.NoError(t, err)
+
+				got, err := io.ReadAll(rc)
+				require.NoError(t, err)
+				require.NoError(t, rc.Close())
+
+				assert.Equal(t, reference(b, d), got)
+			})
+		}
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	buf := make([]byte, 1<<20)
+	_, _ = rand.New(rand.NewSource(1)).Read(buf) //nolint:gosec
+
+	for _, d := range []int{1, 2, 4, 8} {
+		d := d
+
+		b.Run(strconv.Itoa(d), func(b *testing.B) {
+			p := make([]byte, 1<<16)
+
+			b.SetBytes(int64(len(buf)))
+
+			for i := 0; i < b.N; i++ {
+				rc, err := delta.NewReader([]byte{byte(d - 1)}, 0, []io.ReadCloser{io.NopCloser(bytes.NewReader(buf))})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				for {
+					if _, err := rc.Read(p); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
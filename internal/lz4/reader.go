@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"sync"
 
 	lz4 "github.com/pierrec/lz4/v4"
 )
@@ -16,15 +15,12 @@ type readCloser struct {
 }
 
 var (
-	//nolint:gochecknoglobals
-	lz4ReaderPool sync.Pool
-
 	errAlreadyClosed = errors.New("lz4: already closed")
 	errNeedOneReader = errors.New("lz4: need exactly one reader")
 )
 
 func (rc *readCloser) Close() error {
-	if rc.c == nil || rc.r == nil {
+	if rc.c == nil {
 		return errAlreadyClosed
 	}
 
@@ -32,14 +28,14 @@ func (rc *readCloser) Close() error {
 		return fmt.Errorf("lz4: error closing: %w", err)
 	}
 
-	lz4ReaderPool.Put(rc.r)
-	rc.c, rc.r = nil, nil
+	// Keep the decoder so Reset can reuse it
+	rc.c = nil
 
 	return nil
 }
 
 func (rc *readCloser) Read(p []byte) (int, error) {
-	if rc.r == nil {
+	if rc.c == nil {
 		return 0, errAlreadyClosed
 	}
 
@@ -51,21 +47,30 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 	return n, err
 }
 
-// NewReader returns a new LZ4 io.ReadCloser.
-func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+// Reset reinitialises rc to read a new stream, reusing the decoder.
+func (rc *readCloser) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
 	if len(readers) != 1 {
-		return nil, errNeedOneReader
+		return errNeedOneReader
 	}
 
-	r, ok := lz4ReaderPool.Get().(*lz4.Reader)
-	if ok {
-		r.Reset(readers[0])
+	if rc.r == nil {
+		rc.r = lz4.NewReader(readers[0])
 	} else {
-		r = lz4.NewReader(readers[0])
+		rc.r.Reset(readers[0])
+	}
+
+	rc.c = readers[0]
+
+	return nil
+}
+
+// NewReader returns a new LZ4 io.ReadCloser.
+func NewReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	rc := new(readCloser)
+
+	if err := rc.Reset(p, s, readers); err != nil {
+		return nil, err
 	}
 
-	return &readCloser{
-		c: readers[0],
-		r: r,
-	}, nil
+	return rc, nil
 }
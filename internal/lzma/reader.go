@@ -50,6 +50,24 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 
 // NewReader returns a new LZMA io.ReadCloser.
 func NewReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return newReader(p, s, readers, lzma.ReaderConfig{})
+}
+
+// NewReaderWithMaxDictionary returns a function that creates LZMA
+// io.ReadCloser's with a dictionary no larger than size bytes.
+func NewReaderWithMaxDictionary(size uint64) func([]byte, uint64, []io.ReadCloser) (io.ReadCloser, error) {
+	return func(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		// The decompressor uses the larger of the configured and
+		// declared dictionary sizes so cap the latter as well
+		if len(p) == 5 && uint64(binary.LittleEndian.Uint32(p[1:])) > size {
+			p = binary.LittleEndian.AppendUint32([]byte{p[0]}, uint32(size)) //nolint:gosec
+		}
+
+		return newReader(p, s, readers, lzma.ReaderConfig{DictCap: int(size)}) //nolint:gosec
+	}
+}
+
+func newReader(p []byte, s uint64, readers []io.ReadCloser, config lzma.ReaderConfig) (io.ReadCloser, error) {
 	if len(readers) != 1 {
 		return nil, errNeedOneReader
 	}
@@ -57,7 +75,7 @@ func NewReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, erro
 	h := bytes.NewBuffer(p)
 	_ = binary.Write(h, binary.LittleEndian, s)
 
-	lr, err := lzma.NewReader(multiReader(h, readers[0]))
+	lr, err := config.NewReader(multiReader(h, readers[0]))
 	if err != nil {
 		return nil, fmt.Errorf("lzma: error creating reader: %w", err)
 	}
@@ -18,8 +18,13 @@ var (
 	errAlreadyClosed          = errors.New("lzma2: already closed")
 	errNeedOneReader          = errors.New("lzma2: need exactly one reader")
 	errInsufficientProperties = errors.New("lzma2: not enough properties")
+	errInvalidDictionary      = errors.New("lzma2: invalid dictionary size")
 )
 
+// maxDictionaryProperty is the largest valid dictionary size property,
+// meaning 4 GiB.
+const maxDictionaryProperty = 40
+
 func (rc *readCloser) Close() error {
 	if rc.c == nil || rc.r == nil {
 		return errAlreadyClosed
@@ -49,6 +54,18 @@ func (rc *readCloser) Read(p []byte) (int, error) {
 
 // NewReader returns a new LZMA2 io.ReadCloser.
 func NewReader(p []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	return newReader(p, readers, 0)
+}
+
+// NewReaderWithMaxDictionary returns a function that creates LZMA2
+// io.ReadCloser's with a dictionary no larger than size bytes.
+func NewReaderWithMaxDictionary(size uint64) func([]byte, uint64, []io.ReadCloser) (io.ReadCloser, error) {
+	return func(p []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		return newReader(p, readers, size)
+	}
+}
+
+func newReader(p []byte, readers []io.ReadCloser, maxDictionary uint64) (io.ReadCloser, error) {
 	if len(readers) != 1 {
 		return nil, errNeedOneReader
 	}
@@ -57,10 +74,19 @@ func NewReader(p []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, erro
 		return nil, errInsufficientProperties
 	}
 
+	// Anything larger would shift beyond any sensible dictionary size
+	if p[0] > maxDictionaryProperty {
+		return nil, errInvalidDictionary
+	}
+
 	config := lzma.Reader2Config{
 		DictCap: (2 | (int(p[0]) & 1)) << (p[0]/2 + 11), // This gem came from Lzma2Dec.c
 	}
 
+	if maxDictionary > 0 && uint64(config.DictCap) > maxDictionary {
+		config.DictCap = int(maxDictionary) //nolint:gosec
+	}
+
 	if err := config.Verify(); err != nil {
 		return nil, fmt.Errorf("lzma2: error verifying config: %w", err)
 	}
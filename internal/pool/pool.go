@@ -3,6 +3,7 @@ package pool
 
 import (
 	"container/list"
+	"errors"
 	"runtime"
 	"sort"
 	"sync"
@@ -10,28 +11,66 @@ import (
 	"github.com/bodgit/sevenzip/internal/util"
 )
 
-// Pooler is the interface implemented by a pool.
+// Pooler is the interface implemented by a pool. Put reports whether a
+// reader was evicted, any error closing it is returned by Close, which also
+// closes any readers remaining in the pool.
 type Pooler interface {
 	Get(offset int64) (util.SizeReadSeekCloser, bool)
-	Put(offset int64, rc util.SizeReadSeekCloser) (bool, error)
+	Put(offset int64, rc util.SizeReadSeekCloser) bool
+	Close() error
 }
 
 // Constructor is the function prototype used to instantiate a pool.
 type Constructor func() (Pooler, error)
 
-type noopPool struct{}
+// errorList collects errors from closing readers.
+type errorList struct {
+	mutex sync.Mutex
+	errs  []error
+}
+
+func (e *errorList) add(err error) {
+	if err == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.errs = append(e.errs, err)
+}
+
+func (e *errorList) err() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	err := errors.Join(e.errs...)
+	e.errs = nil
+
+	return err
+}
+
+type noopPool struct {
+	errs errorList
+}
 
 // NewNoopPool returns a Pooler that doesn't actually pool anything.
 func NewNoopPool() (Pooler, error) {
 	return new(noopPool), nil
 }
 
-func (noopPool) Get(_ int64) (util.SizeReadSeekCloser, bool) {
+func (*noopPool) Get(_ int64) (util.SizeReadSeekCloser, bool) {
 	return nil, false
 }
 
-func (noopPool) Put(_ int64, rc util.SizeReadSeekCloser) (bool, error) {
-	return false, rc.Close() //nolint:wrapcheck
+func (p *noopPool) Put(_ int64, rc util.SizeReadSeekCloser) bool {
+	p.errs.add(rc.Close())
+
+	return false
+}
+
+func (p *noopPool) Close() error {
+	return p.errs.err()
 }
 
 type pool struct {
@@ -39,6 +78,7 @@ type pool struct {
 	size      int
 	evictList *list.List
 	items     map[int64]*list.Element
+	errs      errorList
 }
 
 type entry struct {
@@ -47,10 +87,16 @@ type entry struct {
 }
 
 // NewPool returns a Pooler that uses a LRU strategy to maintain a fixed pool
-// of util.SizeReadSeekCloser's keyed by their stream offset.
+// of util.SizeReadSeekCloser's keyed by their stream offset. The size of the
+// pool is the number of CPUs.
 func NewPool() (Pooler, error) {
+	return NewPoolSize(runtime.NumCPU())
+}
+
+// NewPoolSize is like NewPool but with a pool of the given size.
+func NewPoolSize(size int) (Pooler, error) {
 	return &pool{
-		size:      runtime.NumCPU(),
+		size:      size,
 		evictList: list.New(),
 		items:     make(map[int64]*list.Element),
 	}, nil
@@ -61,7 +107,7 @@ func (p *pool) Get(offset int64) (util.SizeReadSeekCloser, bool) {
 	defer p.mutex.Unlock()
 
 	if ent, ok := p.items[offset]; ok {
-		_ = p.removeElement(ent, false)
+		p.removeElement(ent, false)
 
 		return ent.Value.(*entry).value, true //nolint:forcetypeassert
 	}
@@ -74,7 +120,7 @@ func (p *pool) Get(offset int64) (util.SizeReadSeekCloser, bool) {
 		// First key less than offset is the closest
 		if k < offset {
 			ent := p.items[k]
-			_ = p.removeElement(ent, false)
+			p.removeElement(ent, false)
 
 			return ent.Value.(*entry).value, true //nolint:forcetypeassert
 		}
@@ -83,26 +129,38 @@ func (p *pool) Get(offset int64) (util.SizeReadSeekCloser, bool) {
 	return nil, false
 }
 
-func (p *pool) Put(offset int64, rc util.SizeReadSeekCloser) (bool, error) {
+func (p *pool) Put(offset int64, rc util.SizeReadSeekCloser) bool {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
 	if _, ok := p.items[offset]; ok {
-		return false, nil
+		// Already have a reader at this offset so close this one
+		p.errs.add(rc.Close())
+
+		return false
 	}
 
 	ent := &entry{offset, rc}
 	entry := p.evictList.PushFront(ent)
 	p.items[offset] = entry
 
-	var err error
-
 	evict := p.evictList.Len() > p.size
 	if evict {
-		err = p.removeOldest()
+		p.removeOldest()
+	}
+
+	return evict
+}
+
+func (p *pool) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for p.evictList.Len() > 0 {
+		p.removeOldest()
 	}
 
-	return evict, err
+	return p.errs.err()
 }
 
 func (p *pool) keys() []int64 {
@@ -117,22 +175,18 @@ func (p *pool) keys() []int64 {
 	return keys
 }
 
-func (p *pool) removeOldest() error {
+func (p *pool) removeOldest() {
 	if ent := p.evictList.Back(); ent != nil {
-		return p.removeElement(ent, true)
+		p.removeElement(ent, true)
 	}
-
-	return nil
 }
 
-func (p *pool) removeElement(e *list.Element, cb bool) error {
+func (p *pool) removeElement(e *list.Element, cb bool) {
 	p.evictList.Remove(e)
 	kv := e.Value.(*entry) //nolint:forcetypeassert
 	delete(p.items, kv.key)
 
 	if cb {
-		return kv.value.Close() //nolint:wrapcheck
+		p.errs.add(kv.value.Close())
 	}
-
-	return nil
 }
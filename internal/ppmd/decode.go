@@ -0,0 +1,171 @@
+package ppmd
+
+const (
+	symbolEnd   = -1
+	symbolError = -2
+)
+
+// decodeSymbol decodes the next symbol, or returns symbolEnd if an end
+// marker was found, or symbolError if the data is corrupt.
+//
+//nolint:cyclop,funlen,gocognit
+func (p *model) decodeSymbol(rd *rangeDecoder) int {
+	var charMask [256]bool // true means the symbol is masked
+
+	if p.corrupt || !p.validContext(p.minContext) {
+		return symbolError
+	}
+
+	if p.numStats(p.minContext) != 1 {
+		s := p.stats(p.minContext)
+
+		count := rd.threshold(p.summFreq(p.minContext))
+		hiCnt := uint32(p.freq(s))
+
+		if count < hiCnt {
+			rd.decode(0, hiCnt)
+			p.foundState = s
+			symbol := p.symbol(s)
+			p.update1First()
+
+			return int(symbol)
+		}
+
+		p.prevSuccess = 0
+
+		for i := p.numStats(p.minContext) - 1; i > 0; i-- {
+			s += stateSize
+
+			if hiCnt += uint32(p.freq(s)); hiCnt > count {
+				rd.decode(hiCnt-uint32(p.freq(s)), uint32(p.freq(s)))
+				p.foundState = s
+				symbol := p.symbol(s)
+				p.update1()
+
+				return int(symbol)
+			}
+		}
+
+		if count >= p.summFreq(p.minContext) {
+			return symbolError
+		}
+
+		p.hiBitsFlag = uint32(p.hb2Flag[p.symbol(p.foundState)])
+		rd.decode(hiCnt, p.summFreq(p.minContext)-hiCnt)
+
+		charMask[p.symbol(s)] = true
+
+		for i := p.numStats(p.minContext) - 1; i > 0; i-- {
+			s -= stateSize
+			charMask[p.symbol(s)] = true
+		}
+	} else {
+		// The suffix and frequency are used to index the probabilities
+		if f := p.freq(oneState(p.minContext)); f == 0 || f > 128 || !p.validContext(p.suffix(p.minContext)) {
+			p.corrupt = true
+
+			return symbolError
+		}
+
+		prob := p.binSummFor()
+
+		if rd.decodeBit(uint32(*prob), binScale) == 0 {
+			*prob = *prob + 1<<intBits - getMean(*prob)
+			p.foundState = oneState(p.minContext)
+			symbol := p.symbol(p.foundState)
+			p.updateBin()
+
+			return int(symbol)
+		}
+
+		*prob -= getMean(*prob)
+		p.initEsc = uint32(expEscape[*prob>>10])
+
+		charMask[p.symbol(oneState(p.minContext))] = true
+		p.prevSuccess = 0
+	}
+
+	var ps [256]uint32
+
+	for {
+		numMasked := p.numStats(p.minContext)
+
+		for {
+			p.orderFall++
+
+			if p.suffix(p.minContext) == 0 {
+				return symbolEnd
+			}
+
+			if p.minContext = p.suffix(p.minContext); !p.validContext(p.minContext) {
+				return symbolError
+			}
+
+			if p.numStats(p.minContext) != numMasked {
+				break
+			}
+		}
+
+		hiCnt := uint32(0)
+		s := p.stats(p.minContext)
+		num := p.numStats(p.minContext) - numMasked
+
+		if num > p.numStats(p.minContext) {
+			p.corrupt = true
+
+			return symbolError
+		}
+
+		for i, n := uint32(0), p.numStats(p.minContext); i != num; s, n = s+stateSize, n-1 {
+			if n == 0 {
+				p.corrupt = true
+
+				return symbolError
+			}
+
+			if !charMask[p.symbol(s)] {
+				hiCnt += uint32(p.freq(s))
+				ps[i] = s
+				i++
+			}
+		}
+
+		see, freqSum := p.makeEscFreq(numMasked)
+		freqSum += hiCnt
+
+		count := rd.threshold(freqSum)
+
+		if count < hiCnt {
+			i := 0
+			for hiCnt = 0; ; i++ {
+				if hiCnt += uint32(p.freq(ps[i])); hiCnt > count {
+					break
+				}
+			}
+
+			s = ps[i]
+			rd.decode(hiCnt-uint32(p.freq(s)), uint32(p.freq(s)))
+			see.update()
+			p.foundState = s
+			symbol := p.symbol(s)
+			p.update2()
+
+			return int(symbol)
+		}
+
+		if count >= freqSum {
+			return symbolError
+		}
+
+		rd.decode(hiCnt, freqSum-hiCnt)
+		see.summ += uint16(freqSum) //nolint:gosec
+
+		for _, s := range ps[:num] {
+			charMask[p.symbol(s)] = true
+		}
+	}
+}
+
+func getMean(prob uint16) uint16 {
+	return (prob + 1<<(periodBits-2)) >> periodBits
+}
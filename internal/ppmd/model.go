@@ -0,0 +1,984 @@
+package ppmd
+
+import "encoding/binary"
+
+const (
+	maxOrder   = 64
+	minOrder   = 2
+	minMemSize = 1 << 11
+	maxMemSize = 0xffffffff - 12*3
+
+	intBits    = 7
+	periodBits = 7
+	binScale   = 1 << (intBits + periodBits)
+	maxFreq    = 124
+	unitSize   = 12
+	stateSize  = 6
+	numIndexes = 4 + 4 + 4 + 26
+)
+
+//nolint:gochecknoglobals
+var (
+	expEscape  = [16]byte{25, 14, 9, 7, 5, 5, 4, 4, 4, 3, 3, 3, 2, 2, 2, 2}
+	initBinEsc = [8]uint16{0x3cdd, 0x1f3f, 0x59bf, 0x48f3, 0x64a1, 0x5abc, 0x6632, 0x6051}
+)
+
+type see struct {
+	summ  uint16
+	shift byte
+	count byte
+}
+
+func (s *see) update() {
+	if s.shift < periodBits {
+		if s.count--; s.count == 0 {
+			s.summ <<= 1
+			s.count = byte(3 << s.shift)
+			s.shift++
+		}
+	}
+}
+
+// model is the PPMd variant H model as used by 7-Zip. All of the contexts
+// and states live in a single block of memory and reference each other by
+// their offset within it, which means the behaviour when the memory is
+// exhausted matches the reference implementation exactly.
+//
+// A context is 12 bytes: the number of states (uint16), the sum of their
+// frequencies (uint16), the offset of the states (uint32) and the offset of
+// the suffix context (uint32). A context with only one state stores it in
+// place of the sum of frequencies and offset of the states.
+//
+// A state is 6 bytes: the symbol, its frequency and the offset of the
+// successor context (uint32).
+type model struct {
+	minContext, maxContext uint32
+	foundState             uint32
+	orderFall              uint32
+	initEsc                uint32
+	prevSuccess            uint32
+	maxOrder               uint32
+	hiBitsFlag             uint32
+	runLength, initRL      int32
+
+	size        uint32
+	glueCount   uint32
+	corrupt     bool
+	mem         []byte
+	alignOffset uint32
+	text        uint32
+	loUnit      uint32
+	hiUnit      uint32
+	unitsStart  uint32
+
+	indx2Units [numIndexes]byte
+	units2Indx [128]byte
+	freeList   [numIndexes]uint32
+	ns2Indx    [256]byte
+	ns2BSIndx  [256]byte
+	hb2Flag    [256]byte
+	dummySee   see
+	see        [25][16]see
+	binSumm    [128][64]uint16
+}
+
+func newModel(order int, size uint32) *model {
+	p := new(model)
+	p.reset(order, size)
+
+	return p
+}
+
+// reset initialises the model, reusing any memory already allocated if it's
+// large enough.
+func (p *model) reset(order int, size uint32) {
+	for i, k := 0, 0; i < numIndexes; i++ {
+		step := 4
+		if i < 12 {
+			step = i>>2 + 1
+		}
+
+		for ; step > 0; step-- {
+			p.units2Indx[k] = byte(i)
+			k++
+		}
+
+		p.indx2Units[i] = byte(k)
+	}
+
+	p.ns2BSIndx[0] = 0 << 1
+	p.ns2BSIndx[1] = 1 << 1
+
+	for i := 2; i < 11; i++ {
+		p.ns2BSIndx[i] = 2 << 1
+	}
+
+	for i := 11; i < 256; i++ {
+		p.ns2BSIndx[i] = 3 << 1
+	}
+
+	for i := 0; i < 3; i++ {
+		p.ns2Indx[i] = byte(i)
+	}
+
+	for i, m, k := 3, 3, 1; i < 256; i++ {
+		p.ns2Indx[i] = byte(m)
+
+		if k--; k == 0 {
+			m++
+			k = m - 2
+		}
+	}
+
+	for i := 0x40; i < 0x100; i++ {
+		p.hb2Flag[i] = 8
+	}
+
+	p.alignOffset = 4 - (size & 3)
+	p.size = size
+
+	if n := int(p.alignOffset) + int(size) + unitSize; cap(p.mem) >= n {
+		p.mem = p.mem[:n]
+		clear(p.mem)
+	} else {
+		p.mem = make([]byte, n)
+	}
+
+	p.maxOrder = uint32(order) //nolint:gosec
+	p.corrupt = false
+	p.restartModel()
+
+	p.dummySee.shift = periodBits
+	p.dummySee.summ = 0
+	p.dummySee.count = 64
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func (p *model) u16(off uint32) uint32 {
+	return uint32(binary.LittleEndian.Uint16(p.mem[off:]))
+}
+
+func (p *model) setU16(off, v uint32) {
+	binary.LittleEndian.PutUint16(p.mem[off:], uint16(v)) //nolint:gosec
+}
+
+func (p *model) u32(off uint32) uint32 {
+	return binary.LittleEndian.Uint32(p.mem[off:])
+}
+
+func (p *model) setU32(off, v uint32) {
+	binary.LittleEndian.PutUint32(p.mem[off:], v)
+}
+
+// Whatever the input, the model only ever sees valid sequences of symbols
+// so it should always be consistent. However as every structure within it
+// is an offset into mem, each one is checked before it's followed so that
+// any inconsistency marks the model as corrupt rather than causing a panic.
+
+// inUnits reports whether the n bytes at off lie within the units area,
+// otherwise the model is marked as corrupt.
+func (p *model) inUnits(off, n uint32) bool {
+	end := p.alignOffset + p.size
+	if off < p.unitsStart || off > end || n > end-off {
+		p.corrupt = true
+
+		return false
+	}
+
+	return true
+}
+
+// validContext reports whether c is a context within the units area along
+// with its states and suffix, otherwise the model is marked as corrupt.
+func (p *model) validContext(c uint32) bool {
+	if !p.inUnits(c, unitSize) {
+		return false
+	}
+
+	if ns := p.numStats(c); ns != 1 {
+		if ns == 0 || ns > 256 || p.summFreq(c) == 0 || !p.inUnits(p.stats(c), ns*stateSize) {
+			p.corrupt = true
+
+			return false
+		}
+	}
+
+	if suffix := p.suffix(c); suffix != 0 && !p.inUnits(suffix, unitSize) {
+		return false
+	}
+
+	return true
+}
+
+// findState returns the state for symbol in c, which has more than one
+// state, otherwise the model is marked as corrupt and 0 is returned.
+func (p *model) findState(c uint32, symbol byte) uint32 {
+	s := p.stats(c)
+
+	for i := p.numStats(c); i > 0; i, s = i-1, s+stateSize {
+		if p.symbol(s) == symbol {
+			return s
+		}
+	}
+
+	p.corrupt = true
+
+	return 0
+}
+
+// Context accessors.
+
+func (p *model) numStats(c uint32) uint32     { return p.u16(c) }
+func (p *model) setNumStats(c, v uint32)      { p.setU16(c, v) }
+func (p *model) summFreq(c uint32) uint32     { return p.u16(c + 2) }
+func (p *model) setSummFreq(c, v uint32)      { p.setU16(c+2, v) }
+func (p *model) stats(c uint32) uint32        { return p.u32(c + 4) }
+func (p *model) setStats(c, v uint32)         { p.setU32(c+4, v) }
+func (p *model) suffix(c uint32) uint32       { return p.u32(c + 8) }
+func (p *model) setSuffix(c, v uint32)        { p.setU32(c+8, v) }
+func oneState(c uint32) uint32                { return c + 2 }
+func (p *model) symbol(s uint32) byte         { return p.mem[s] }
+func (p *model) freq(s uint32) byte           { return p.mem[s+1] }
+func (p *model) setFreq(s uint32, v byte)     { p.mem[s+1] = v }
+func (p *model) successor(s uint32) uint32    { return p.u32(s + 2) }
+func (p *model) setSuccessor(s, v uint32)     { p.setU32(s+2, v) }
+func (p *model) copyState(dst, src uint32)    { copy(p.mem[dst:dst+stateSize], p.mem[src:src+stateSize]) }
+func (p *model) getState(s uint32) [6]byte    { return [6]byte(p.mem[s : s+stateSize]) }
+func (p *model) putState(s uint32, v [6]byte) { copy(p.mem[s:s+stateSize], v[:]) }
+
+func (p *model) swapStates(s1, s2 uint32) {
+	t := p.getState(s1)
+	p.copyState(s1, s2)
+	p.putState(s2, t)
+}
+
+// Memory allocator, free blocks are tracked in lists by their size in
+// units.
+
+func (p *model) i2u(indx uint32) uint32 { return uint32(p.indx2Units[indx]) }
+func (p *model) u2i(nu uint32) uint32   { return uint32(p.units2Indx[nu-1]) }
+func u2b(nu uint32) uint32              { return nu * unitSize }
+
+func (p *model) insertNode(node, indx uint32) {
+	p.setU32(node, p.freeList[indx])
+	p.freeList[indx] = node
+}
+
+func (p *model) removeNode(indx uint32) uint32 {
+	node := p.freeList[indx]
+
+	next := p.u32(node)
+	if next != 0 && !p.inUnits(next, unitSize) {
+		next = 0
+	}
+
+	p.freeList[indx] = next
+
+	return node
+}
+
+func (p *model) splitBlock(ptr, oldIndx, newIndx uint32) {
+	nu := p.i2u(oldIndx) - p.i2u(newIndx)
+	ptr += u2b(p.i2u(newIndx))
+
+	i := p.u2i(nu)
+	if p.i2u(i) != nu {
+		i--
+		k := p.i2u(i)
+		p.insertNode(ptr+u2b(k), nu-k-1)
+	}
+
+	p.insertNode(ptr, i)
+}
+
+// Free block nodes used while gluing: a stamp (uint16), the number of units
+// (uint16) and the offsets of the next and previous nodes (uint32).
+
+func (p *model) stamp(n uint32) uint32    { return p.u16(n) }
+func (p *model) setStamp(n, v uint32)     { p.setU16(n, v) }
+func (p *model) nu(n uint32) uint32       { return p.u16(n + 2) }
+func (p *model) setNU(n, v uint32)        { p.setU16(n+2, v) }
+func (p *model) nodeNext(n uint32) uint32 { return p.u32(n + 4) }
+func (p *model) setNodeNext(n, v uint32)  { p.setU32(n+4, v) }
+func (p *model) nodePrev(n uint32) uint32 { return p.u32(n + 8) }
+func (p *model) setNodePrev(n, v uint32)  { p.setU32(n+8, v) }
+
+//nolint:cyclop
+func (p *model) glueFreeBlocks() {
+	head := p.alignOffset + p.size
+	n := head
+
+	p.glueCount = 255
+
+	// Create a doubly-linked list of all of the free blocks
+	for i := uint32(0); i < numIndexes; i++ {
+		nu := p.i2u(i)
+		next := p.freeList[i]
+		p.freeList[i] = 0
+
+		for next != 0 {
+			node := next
+			p.setNodeNext(node, n)
+			p.setNodePrev(n, next)
+			n = next
+
+			if next = p.u32(node); next != 0 && !p.inUnits(next, unitSize) {
+				next = 0
+			}
+
+			p.setStamp(node, 0)
+			p.setNU(node, nu)
+		}
+	}
+
+	p.setStamp(head, 1)
+	p.setNodeNext(head, n)
+	p.setNodePrev(n, head)
+
+	if p.loUnit != p.hiUnit {
+		p.setStamp(p.loUnit, 1)
+	}
+
+	// Glue adjacent free blocks together
+	for n != head {
+		node := n
+		nu := p.nu(node)
+
+		for {
+			node2 := node + nu*unitSize
+			if node2 > head {
+				p.corrupt = true
+
+				break
+			}
+
+			nu += p.nu(node2)
+
+			if p.stamp(node2) != 0 || nu >= 0x10000 {
+				break
+			}
+
+			if p.nodePrev(node2) > head || p.nodeNext(node2) > head {
+				p.corrupt = true
+
+				break
+			}
+
+			p.setNodeNext(p.nodePrev(node2), p.nodeNext(node2))
+			p.setNodePrev(p.nodeNext(node2), p.nodePrev(node2))
+			p.setNU(node, nu)
+		}
+
+		n = p.nodeNext(node)
+	}
+
+	// Fill the lists of free blocks
+	for n = p.nodeNext(head); n != head; {
+		node := n
+		next := p.nodeNext(node)
+		nu := p.nu(node)
+
+		for ; nu > 128; nu, node = nu-128, node+128*unitSize {
+			p.insertNode(node, numIndexes-1)
+		}
+
+		i := p.u2i(nu)
+		if p.i2u(i) != nu {
+			i--
+			k := p.i2u(i)
+			p.insertNode(node+k*unitSize, nu-k-1)
+		}
+
+		p.insertNode(node, i)
+
+		n = next
+	}
+}
+
+func (p *model) allocUnitsRare(indx uint32) uint32 {
+	if p.glueCount == 0 {
+		p.glueFreeBlocks()
+
+		if p.freeList[indx] != 0 {
+			return p.removeNode(indx)
+		}
+	}
+
+	i := indx
+
+	for {
+		if i++; i == numIndexes {
+			numBytes := u2b(p.i2u(indx))
+			p.glueCount--
+
+			if p.unitsStart-p.text > numBytes {
+				p.unitsStart -= numBytes
+
+				return p.unitsStart
+			}
+
+			return 0
+		}
+
+		if p.freeList[i] != 0 {
+			break
+		}
+	}
+
+	retVal := p.removeNode(i)
+	p.splitBlock(retVal, i, indx)
+
+	return retVal
+}
+
+func (p *model) allocUnits(indx uint32) uint32 {
+	if p.freeList[indx] != 0 {
+		return p.removeNode(indx)
+	}
+
+	numBytes := u2b(p.i2u(indx))
+	if numBytes <= p.hiUnit-p.loUnit {
+		retVal := p.loUnit
+		p.loUnit += numBytes
+
+		return retVal
+	}
+
+	return p.allocUnitsRare(indx)
+}
+
+func (p *model) shrinkUnits(oldPtr, oldNU, newNU uint32) uint32 {
+	i0 := p.u2i(oldNU)
+	i1 := p.u2i(newNU)
+
+	if i0 == i1 {
+		return oldPtr
+	}
+
+	if p.freeList[i1] != 0 {
+		ptr := p.removeNode(i1)
+		copy(p.mem[ptr:ptr+u2b(newNU)], p.mem[oldPtr:oldPtr+u2b(newNU)])
+		p.insertNode(oldPtr, i0)
+
+		return ptr
+	}
+
+	p.splitBlock(oldPtr, i0, i1)
+
+	return oldPtr
+}
+
+func (p *model) restartModel() {
+	p.freeList = [numIndexes]uint32{}
+	p.text = p.alignOffset
+	p.hiUnit = p.text + p.size
+	p.loUnit = p.hiUnit - p.size/8/unitSize*7*unitSize
+	p.unitsStart = p.loUnit
+	p.glueCount = 0
+
+	p.orderFall = p.maxOrder
+	p.initRL = -int32(min(p.maxOrder, 12)) - 1 //nolint:gosec
+	p.runLength = p.initRL
+	p.prevSuccess = 0
+
+	p.hiUnit -= unitSize
+	p.minContext = p.hiUnit
+	p.maxContext = p.hiUnit
+	p.setSuffix(p.minContext, 0)
+	p.setNumStats(p.minContext, 256)
+	p.setSummFreq(p.minContext, 256+1)
+
+	p.foundState = p.loUnit
+	p.setStats(p.minContext, p.loUnit)
+
+	for i := uint32(0); i < 256; i++ {
+		s := p.loUnit + i*stateSize
+		p.mem[s] = byte(i)
+		p.setFreq(s, 1)
+		p.setSuccessor(s, 0)
+	}
+
+	p.loUnit += u2b(256 / 2)
+
+	for i := range p.binSumm {
+		for k := 0; k < 8; k++ {
+			val := uint16(binScale - uint32(initBinEsc[k])/uint32(i+2)) //nolint:gosec
+
+			for m := 0; m < 64; m += 8 {
+				p.binSumm[i][k+m] = val
+			}
+		}
+	}
+
+	for i := range p.see {
+		for k := range p.see[i] {
+			p.see[i][k] = see{
+				summ:  uint16((5*i + 10) << (periodBits - 4)), //nolint:gosec
+				shift: periodBits - 4,
+				count: 4,
+			}
+		}
+	}
+}
+
+//nolint:cyclop,funlen
+func (p *model) createSuccessors(skip bool) uint32 {
+	var (
+		ps    [maxOrder]uint32
+		numPs int
+	)
+
+	c := p.minContext
+	upBranch := p.successor(p.foundState)
+	fSymbol := p.symbol(p.foundState)
+
+	if !skip {
+		ps[numPs] = p.foundState
+		numPs++
+	}
+
+	for p.suffix(c) != 0 {
+		var s uint32
+
+		if c = p.suffix(c); !p.validContext(c) {
+			return 0
+		}
+
+		if p.numStats(c) != 1 {
+			if s = p.findState(c, fSymbol); s == 0 {
+				return 0
+			}
+		} else {
+			s = oneState(c)
+		}
+
+		if successor := p.successor(s); successor != upBranch {
+			if c = successor; !p.validContext(c) {
+				return 0
+			}
+
+			if numPs == 0 {
+				return c
+			}
+
+			break
+		}
+
+		if numPs == maxOrder {
+			p.corrupt = true
+
+			return 0
+		}
+
+		ps[numPs] = s
+		numPs++
+	}
+
+	// upBranch is the position in the text of the symbol that followed
+	if upBranch < p.alignOffset || upBranch >= p.unitsStart {
+		p.corrupt = true
+
+		return 0
+	}
+
+	upSymbol := p.mem[upBranch]
+	upSuccessor := upBranch + 1
+
+	var upFreq byte
+
+	if p.numStats(c) == 1 {
+		upFreq = p.freq(oneState(c))
+	} else {
+		s := p.findState(c, upSymbol)
+		if s == 0 {
+			return 0
+		}
+
+		cf := uint32(p.freq(s)) - 1
+		s0 := p.summFreq(c) - p.numStats(c) - cf
+
+		switch {
+		case 2*cf <= s0:
+			upFreq = byte(1 + b2u(5*cf > s0))
+		case s0 == 0:
+			p.corrupt = true
+
+			return 0
+		default:
+			upFreq = byte(1 + (2*cf+3*s0-1)/(2*s0)) //nolint:gosec
+		}
+	}
+
+	for numPs != 0 {
+		var c1 uint32
+
+		switch {
+		case p.hiUnit != p.loUnit:
+			p.hiUnit -= unitSize
+			c1 = p.hiUnit
+		case p.freeList[0] != 0:
+			c1 = p.removeNode(0)
+		default:
+			if c1 = p.allocUnitsRare(0); c1 == 0 {
+				return 0
+			}
+		}
+
+		p.setNumStats(c1, 1)
+		p.mem[oneState(c1)] = upSymbol
+		p.setFreq(oneState(c1), upFreq)
+		p.setSuccessor(oneState(c1), upSuccessor)
+		p.setSuffix(c1, c)
+
+		numPs--
+		p.setSuccessor(ps[numPs], c1)
+
+		c = c1
+	}
+
+	return c
+}
+
+//nolint:cyclop,funlen,gocognit
+func (p *model) updateModel() {
+	fSymbol := p.symbol(p.foundState)
+	fSuccessor := p.successor(p.foundState)
+
+	if p.freq(p.foundState) < maxFreq/4 && p.suffix(p.minContext) != 0 {
+		c := p.suffix(p.minContext)
+		if !p.validContext(c) {
+			return
+		}
+
+		if p.numStats(c) == 1 {
+			s := oneState(c)
+			if p.freq(s) < 32 {
+				p.setFreq(s, p.freq(s)+1)
+			}
+		} else {
+			s := p.findState(c, fSymbol)
+			if s == 0 {
+				return
+			}
+
+			if s != p.stats(c) {
+				if p.freq(s) >= p.freq(s-stateSize) {
+					p.swapStates(s, s-stateSize)
+					s -= stateSize
+				}
+			}
+
+			if p.freq(s) < maxFreq-9 {
+				p.setFreq(s, p.freq(s)+2)
+				p.setSummFreq(c, p.summFreq(c)+2)
+			}
+		}
+	}
+
+	if p.orderFall == 0 {
+		p.minContext = p.createSuccessors(true)
+		p.maxContext = p.minContext
+
+		if p.minContext == 0 {
+			p.restartModel()
+
+			return
+		}
+
+		p.setSuccessor(p.foundState, p.minContext)
+
+		return
+	}
+
+	p.mem[p.text] = fSymbol
+	p.text++
+	successor := p.text
+
+	if p.text >= p.unitsStart {
+		p.restartModel()
+
+		return
+	}
+
+	if fSuccessor != 0 {
+		if fSuccessor <= successor {
+			cs := p.createSuccessors(false)
+			if cs == 0 {
+				p.restartModel()
+
+				return
+			}
+
+			fSuccessor = cs
+		}
+
+		if p.orderFall--; p.orderFall == 0 {
+			successor = fSuccessor
+
+			if p.maxContext != p.minContext {
+				p.text--
+			}
+		}
+	} else {
+		p.setSuccessor(p.foundState, successor)
+		fSuccessor = p.minContext
+	}
+
+	ns := p.numStats(p.minContext)
+	s0 := p.summFreq(p.minContext) - ns - (uint32(p.freq(p.foundState)) - 1)
+
+	for c := p.maxContext; c != p.minContext; c = p.suffix(c) {
+		if !p.validContext(c) {
+			return
+		}
+
+		ns1 := p.numStats(c)
+
+		if ns1 != 1 {
+			if ns1&1 == 0 {
+				// Expand for one unit
+				oldNU := ns1 >> 1
+
+				i := p.u2i(oldNU)
+				if i != p.u2i(oldNU+1) {
+					ptr := p.allocUnits(i + 1)
+					if ptr == 0 {
+						p.restartModel()
+
+						return
+					}
+
+					oldPtr := p.stats(c)
+					copy(p.mem[ptr:ptr+u2b(oldNU)], p.mem[oldPtr:oldPtr+u2b(oldNU)])
+					p.insertNode(oldPtr, i)
+					p.setStats(c, ptr)
+				}
+			}
+
+			sf := p.summFreq(c)
+			p.setSummFreq(c, sf+b2u(2*ns1 < ns)+2*(b2u(4*ns1 <= ns)&b2u(sf <= 8*ns1)))
+		} else {
+			s := p.allocUnits(0)
+			if s == 0 {
+				p.restartModel()
+
+				return
+			}
+
+			p.copyState(s, oneState(c))
+			p.setStats(c, s)
+
+			if p.freq(s) < maxFreq/4-1 {
+				p.setFreq(s, p.freq(s)<<1)
+			} else {
+				p.setFreq(s, maxFreq-4)
+			}
+
+			p.setSummFreq(c, uint32(p.freq(s))+p.initEsc+b2u(ns > 3))
+		}
+
+		cf := 2 * uint32(p.freq(p.foundState)) * (p.summFreq(c) + 6)
+		sf := s0 + p.summFreq(c)
+
+		if cf < 6*sf {
+			cf = 1 + b2u(cf > sf) + b2u(cf >= 4*sf)
+			p.setSummFreq(c, p.summFreq(c)+3)
+		} else {
+			cf = 4 + b2u(cf >= 9*sf) + b2u(cf >= 12*sf) + b2u(cf >= 15*sf)
+			p.setSummFreq(c, p.summFreq(c)+cf)
+		}
+
+		s := p.stats(c) + ns1*stateSize
+		p.setSuccessor(s, successor)
+		p.mem[s] = fSymbol
+		p.setFreq(s, byte(cf))
+		p.setNumStats(c, ns1+1)
+	}
+
+	p.maxContext = fSuccessor
+	p.minContext = fSuccessor
+}
+
+//nolint:cyclop,funlen
+func (p *model) rescale() {
+	stats := p.stats(p.minContext)
+	s := p.foundState
+
+	// Move the found state to the front
+	tmp := p.getState(s)
+	for ; s != stats; s -= stateSize {
+		p.copyState(s, s-stateSize)
+	}
+
+	p.putState(s, tmp)
+
+	escFreq := p.summFreq(p.minContext) - uint32(p.freq(s))
+	p.setFreq(s, p.freq(s)+4)
+	adder := b2u(p.orderFall != 0)
+	p.setFreq(s, byte((uint32(p.freq(s))+adder)>>1))
+	sumFreq := uint32(p.freq(s))
+
+	for i := p.numStats(p.minContext) - 1; i > 0; i-- {
+		s += stateSize
+		escFreq -= uint32(p.freq(s))
+		p.setFreq(s, byte((uint32(p.freq(s))+adder)>>1))
+		sumFreq += uint32(p.freq(s))
+
+		if p.freq(s) > p.freq(s-stateSize) {
+			s1 := s
+			tmp := p.getState(s1)
+
+			for {
+				p.copyState(s1, s1-stateSize)
+
+				if s1 -= stateSize; s1 == stats || tmp[1] <= p.freq(s1-stateSize) {
+					break
+				}
+			}
+
+			p.putState(s1, tmp)
+		}
+	}
+
+	if p.freq(s) == 0 {
+		numStats := p.numStats(p.minContext)
+
+		i := uint32(0)
+		for {
+			i++
+
+			if s -= stateSize; p.freq(s) != 0 {
+				break
+			}
+		}
+
+		escFreq += i
+		p.setNumStats(p.minContext, numStats-i)
+
+		if p.numStats(p.minContext) == 1 {
+			tmp := p.getState(stats)
+
+			for {
+				tmp[1] -= tmp[1] >> 1
+
+				if escFreq >>= 1; escFreq <= 1 {
+					break
+				}
+			}
+
+			p.insertNode(stats, p.u2i((numStats+1)>>1))
+			p.foundState = oneState(p.minContext)
+			p.putState(p.foundState, tmp)
+
+			return
+		}
+
+		n0 := (numStats + 1) >> 1
+		n1 := (p.numStats(p.minContext) + 1) >> 1
+
+		if n0 != n1 {
+			p.setStats(p.minContext, p.shrinkUnits(stats, n0, n1))
+		}
+	}
+
+	p.setSummFreq(p.minContext, sumFreq+escFreq-(escFreq>>1))
+	p.foundState = p.stats(p.minContext)
+}
+
+func (p *model) makeEscFreq(numMasked uint32) (*see, uint32) {
+	ns := p.numStats(p.minContext)
+	nonMasked := ns - numMasked
+
+	if ns == 256 {
+		return &p.dummySee, 1
+	}
+
+	s := &p.see[p.ns2Indx[nonMasked-1]][b2u(nonMasked < p.numStats(p.suffix(p.minContext))-ns)+
+		2*b2u(p.summFreq(p.minContext) < 11*ns)+
+		4*b2u(numMasked > nonMasked)+
+		p.hiBitsFlag]
+
+	r := uint32(s.summ >> s.shift)
+	s.summ -= uint16(r) //nolint:gosec
+
+	return s, r + b2u(r == 0)
+}
+
+func (p *model) nextContext() {
+	if c := p.successor(p.foundState); p.orderFall == 0 && c > p.text {
+		p.minContext = c
+		p.maxContext = c
+	} else {
+		p.updateModel()
+	}
+}
+
+func (p *model) update1() {
+	s := p.foundState
+	p.setFreq(s, p.freq(s)+4)
+	p.setSummFreq(p.minContext, p.summFreq(p.minContext)+4)
+
+	if p.freq(s) > p.freq(s-stateSize) {
+		p.swapStates(s, s-stateSize)
+		s -= stateSize
+		p.foundState = s
+
+		if p.freq(s) > maxFreq {
+			p.rescale()
+		}
+	}
+
+	p.nextContext()
+}
+
+func (p *model) update1First() {
+	p.prevSuccess = b2u(2*uint32(p.freq(p.foundState)) > p.summFreq(p.minContext))
+	p.runLength += int32(p.prevSuccess) //nolint:gosec
+	p.setSummFreq(p.minContext, p.summFreq(p.minContext)+4)
+
+	if p.setFreq(p.foundState, p.freq(p.foundState)+4); p.freq(p.foundState) > maxFreq {
+		p.rescale()
+	}
+
+	p.nextContext()
+}
+
+func (p *model) updateBin() {
+	if p.freq(p.foundState) < 128 {
+		p.setFreq(p.foundState, p.freq(p.foundState)+1)
+	}
+
+	p.prevSuccess = 1
+	p.runLength++
+	p.nextContext()
+}
+
+func (p *model) update2() {
+	p.setFreq(p.foundState, p.freq(p.foundState)+4)
+	p.setSummFreq(p.minContext, p.summFreq(p.minContext)+4)
+
+	if p.freq(p.foundState) > maxFreq {
+		p.rescale()
+	}
+
+	p.runLength = p.initRL
+	p.updateModel()
+}
+
+func (p *model) binSummFor() *uint16 {
+	s := oneState(p.minContext)
+	p.hiBitsFlag = uint32(p.hb2Flag[p.symbol(p.foundState)])
+
+	return &p.binSumm[p.freq(s)-1][p.prevSuccess+
+		uint32(p.ns2BSIndx[p.numStats(p.suffix(p.minContext))-1])+
+		p.hiBitsFlag+
+		2*uint32(p.hb2Flag[p.symbol(s)])+
+		uint32((p.runLength>>26)&0x20)]
+}
@@ -0,0 +1,103 @@
+package ppmd
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+const topValue = 1 << 24
+
+var errInvalidRangeDecoder = errors.New("ppmd: invalid range decoder")
+
+// rangeDecoder is the 7-Zip variant of the range decoder used with PPMd,
+// which differs from the one described by Dmitry Shkarin.
+type rangeDecoder struct {
+	br    io.ByteReader
+	rng   uint32
+	code  uint32
+	extra bool
+	err   error
+}
+
+func (rd *rangeDecoder) readByte() uint32 {
+	b, err := rd.br.ReadByte()
+	if err != nil {
+		// Keep decoding with zero bytes as 7-Zip does, the error is
+		// only reported if it's actually needed
+		if !rd.extra {
+			rd.extra, rd.err = true, err
+		}
+
+		return 0
+	}
+
+	return uint32(b)
+}
+
+func (rd *rangeDecoder) init() error {
+	rd.code = 0
+	rd.rng = 0xffffffff
+
+	if rd.readByte() != 0 {
+		return errInvalidRangeDecoder
+	}
+
+	for i := 0; i < 4; i++ {
+		rd.code = rd.code<<8 | rd.readByte()
+	}
+
+	if rd.extra || rd.code == 0xffffffff {
+		return errInvalidRangeDecoder
+	}
+
+	return nil
+}
+
+func (rd *rangeDecoder) normalize() {
+	if rd.rng < topValue {
+		rd.code = rd.code<<8 | rd.readByte()
+		rd.rng <<= 8
+
+		if rd.rng < topValue {
+			rd.code = rd.code<<8 | rd.readByte()
+			rd.rng <<= 8
+		}
+	}
+}
+
+func (rd *rangeDecoder) threshold(total uint32) uint32 {
+	// This is only possible if the model is corrupt, returning a count
+	// beyond any total makes the caller report it
+	if total == 0 || rd.rng < total {
+		return math.MaxUint32
+	}
+
+	rd.rng /= total
+
+	return rd.code / rd.rng
+}
+
+func (rd *rangeDecoder) decode(start, size uint32) {
+	rd.code -= start * rd.rng
+	rd.rng *= size
+	rd.normalize()
+}
+
+func (rd *rangeDecoder) decodeBit(size0, total uint32) uint32 {
+	var symbol uint32
+
+	newBound := (rd.rng / total) * size0
+
+	if rd.code < newBound {
+		rd.rng = newBound
+	} else {
+		symbol = 1
+		rd.code -= newBound
+		rd.rng -= newBound
+	}
+
+	rd.normalize()
+
+	return symbol
+}
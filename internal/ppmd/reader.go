@@ -0,0 +1,143 @@
+// Package ppmd implements the PPMd variant H decompressor as used by 7-Zip.
+package ppmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+type readCloser struct {
+	c         io.Closer
+	rd        rangeDecoder
+	m         *model
+	remaining uint64
+	err       error
+}
+
+var (
+	errAlreadyClosed          = errors.New("ppmd: already closed")
+	errNeedOneReader          = errors.New("ppmd: need exactly one reader")
+	errInsufficientProperties = errors.New("ppmd: not enough properties")
+	errUnsupportedOrder       = errors.New("ppmd: unsupported model order")
+	errUnsupportedMemory      = errors.New("ppmd: unsupported memory size")
+	errCorrupt                = errors.New("ppmd: corrupt data")
+	errUnexpectedEnd          = errors.New("ppmd: unexpected end of stream marker")
+)
+
+func (rc *readCloser) Close() error {
+	if rc.c == nil {
+		return errAlreadyClosed
+	}
+
+	if err := rc.c.Close(); err != nil {
+		return fmt.Errorf("ppmd: error closing: %w", err)
+	}
+
+	// Keep the model so Reset can reuse its memory
+	rc.c = nil
+
+	return nil
+}
+
+func (rc *readCloser) Read(p []byte) (int, error) {
+	if rc.c == nil {
+		return 0, errAlreadyClosed
+	}
+
+	if rc.err != nil {
+		return 0, rc.err
+	}
+
+	if rc.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	n := 0
+
+	for ; n < len(p) && rc.remaining > 0; n++ {
+		symbol := rc.m.decodeSymbol(&rc.rd)
+
+		// The model is left part way through an update by any error so
+		// decoding can't carry on afterwards
+		switch {
+		case rc.rd.extra:
+			if errors.Is(rc.rd.err, io.EOF) {
+				rc.err = fmt.Errorf("ppmd: error reading: %w", io.ErrUnexpectedEOF)
+			} else {
+				rc.err = fmt.Errorf("ppmd: error reading: %w", rc.rd.err)
+			}
+		case symbol == symbolEnd:
+			rc.err = errUnexpectedEnd
+		case symbol < 0, rc.m.corrupt:
+			rc.err = errCorrupt
+		}
+
+		if rc.err != nil {
+			return n, rc.err
+		}
+
+		p[n] = byte(symbol)
+		rc.remaining--
+	}
+
+	return n, nil
+}
+
+// Reset reinitialises rc to read a new stream, reusing the memory allocated
+// for the model where possible.
+func (rc *readCloser) Reset(p []byte, s uint64, readers []io.ReadCloser) error {
+	if len(readers) != 1 {
+		return errNeedOneReader
+	}
+
+	if len(p) != 5 {
+		return errInsufficientProperties
+	}
+
+	order := int(p[0])
+	if order < minOrder || order > maxOrder {
+		return errUnsupportedOrder
+	}
+
+	size := binary.LittleEndian.Uint32(p[1:])
+	if size < minMemSize || size > maxMemSize || uint64(size)+2*unitSize > math.MaxInt {
+		return errUnsupportedMemory
+	}
+
+	br, ok := readers[0].(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(readers[0])
+	}
+
+	rc.rd = rangeDecoder{br: br}
+	rc.err = nil
+
+	if err := rc.rd.init(); err != nil {
+		return err
+	}
+
+	if rc.m == nil {
+		rc.m = newModel(order, size)
+	} else {
+		rc.m.reset(order, size)
+	}
+
+	rc.c, rc.remaining = readers[0], s
+
+	return nil
+}
+
+// NewReader returns a new PPMd io.ReadCloser.
+func NewReader(p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	rc := new(readCloser)
+
+	if err := rc.Reset(p, s, readers); err != nil {
+		return nil, err
+	}
+
+	return rc, nil
+}
@@ -0,0 +1,306 @@
+package ppmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeEncoder is the counterpart of rangeDecoder, used to create streams
+// to test against.
+type rangeEncoder struct {
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize uint64
+	out       []byte
+}
+
+func (re *rangeEncoder) shiftLow() {
+	if uint32(re.low) < 0xff000000 || re.low>>32 != 0 {
+		temp := re.cache
+
+		for {
+			re.out = append(re.out, temp+byte(re.low>>32))
+			temp = 0xff
+
+			if re.cacheSize--; re.cacheSize == 0 {
+				break
+			}
+		}
+
+		re.cache = byte(uint32(re.low) >> 24)
+	}
+
+	re.cacheSize++
+	re.low = uint64(uint32(re.low) << 8)
+}
+
+func (re *rangeEncoder) normalize() {
+	for re.rng < topValue {
+		re.rng <<= 8
+		re.shiftLow()
+	}
+}
+
+func (re *rangeEncoder) encode(start, size, total uint32) {
+	re.rng /= total
+	re.low += uint64(start) * uint64(re.rng)
+	re.rng *= size
+	re.normalize()
+}
+
+func (re *rangeEncoder) encodeBit(size0, total, bit uint32) {
+	newBound := (re.rng / total) * size0
+
+	if bit == 0 {
+		re.rng = newBound
+	} else {
+		re.low += uint64(newBound)
+		re.rng -= newBound
+	}
+
+	re.normalize()
+}
+
+func (re *rangeEncoder) flush() []byte {
+	for i := 0; i < 5; i++ {
+		re.shiftLow()
+	}
+
+	return re.out
+}
+
+// encodeSymbol mirrors decodeSymbol.
+//
+//nolint:cyclop,funlen
+func (p *model) encodeSymbol(re *rangeEncoder, symbol byte) {
+	var charMask [256]bool
+
+	if p.numStats(p.minContext) != 1 {
+		s := p.stats(p.minContext)
+
+		if p.symbol(s) == symbol {
+			re.encode(0, uint32(p.freq(s)), p.summFreq(p.minContext))
+			p.foundState = s
+			p.update1First()
+
+			return
+		}
+
+		p.prevSuccess = 0
+		hiCnt := uint32(p.freq(s))
+
+		for i := p.numStats(p.minContext) - 1; i > 0; i-- {
+			s += stateSize
+
+			if p.symbol(s) == symbol {
+				re.encode(hiCnt, uint32(p.freq(s)), p.summFreq(p.minContext))
+				p.foundState = s
+				p.update1()
+
+				return
+			}
+
+			hiCnt += uint32(p.freq(s))
+		}
+
+		p.hiBitsFlag = uint32(p.hb2Flag[p.symbol(p.foundState)])
+		re.encode(hiCnt, p.summFreq(p.minContext)-hiCnt, p.summFreq(p.minContext))
+
+		charMask[p.symbol(s)] = true
+
+		for i := p.numStats(p.minContext) - 1; i > 0; i-- {
+			s -= stateSize
+			charMask[p.symbol(s)] = true
+		}
+	} else {
+		prob := p.binSummFor()
+		s := oneState(p.minContext)
+
+		if p.symbol(s) == symbol {
+			re.encodeBit(uint32(*prob), binScale, 0)
+			*prob = *prob + 1<<intBits - getMean(*prob)
+			p.foundState = s
+			p.updateBin()
+
+			return
+		}
+
+		re.encodeBit(uint32(*prob), binScale, 1)
+		*prob -= getMean(*prob)
+		p.initEsc = uint32(expEscape[*prob>>10])
+
+		charMask[p.symbol(s)] = true
+		p.prevSuccess = 0
+	}
+
+	var ps [256]uint32
+
+	for {
+		numMasked := p.numStats(p.minContext)
+
+		for {
+			p.orderFall++
+
+			p.minContext = p.suffix(p.minContext)
+
+			if p.numStats(p.minContext) != numMasked {
+				break
+			}
+		}
+
+		hiCnt := uint32(0)
+		s := p.stats(p.minContext)
+		num := p.numStats(p.minContext) - numMasked
+
+		for i := uint32(0); i != num; s += stateSize {
+			if !charMask[p.symbol(s)] {
+				hiCnt += uint32(p.freq(s))
+				ps[i] = s
+				i++
+			}
+		}
+
+		see, freqSum := p.makeEscFreq(numMasked)
+		freqSum += hiCnt
+
+		low := uint32(0)
+
+		for _, s := range ps[:num] {
+			if p.symbol(s) == symbol {
+				re.encode(low, uint32(p.freq(s)), freqSum)
+				see.update()
+				p.foundState = s
+				p.update2()
+
+				return
+			}
+
+			low += uint32(p.freq(s))
+		}
+
+		re.encode(hiCnt, freqSum-hiCnt, freqSum)
+		see.summ += uint16(freqSum) //nolint:gosec
+
+		for _, s := range ps[:num] {
+			charMask[p.symbol(s)] = true
+		}
+	}
+}
+
+// encode compresses b using a model of the given order and memory size.
+func encode(b []byte, order int, size uint32) []byte {
+	p := newModel(order, size)
+	re := &rangeEncoder{rng: 0xffffffff, cacheSize: 1}
+
+	for _, c := range b {
+		p.encodeSymbol(re, c)
+	}
+
+	return re.flush()
+}
+
+// sample returns n bytes of compressible data with a large alphabet so the
+// model keeps growing.
+func sample(n int) []byte {
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	b := make([]byte, n)
+
+	for i := range b {
+		if i > 16 && rnd.Intn(4) != 0 {
+			b[i] = b[i-1-rnd.Intn(16)]
+		} else {
+			b[i] = byte(rnd.Intn(256))
+		}
+	}
+
+	return b
+}
+
+func props(order int, size uint32) []byte {
+	p := []byte{byte(order), 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(p[1:], size)
+
+	return p
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	b := sample(1 << 17)
+
+	tables := []struct {
+		name  string
+		order int
+		size  uint32
+	}{
+		{
+			name:  "default",
+			order: 6,
+			size:  16 << 20,
+		},
+		{
+			// The model runs out of memory and restarts many times
+			name:  "exhausted",
+			order: minOrder,
+			size:  minMemSize,
+		},
+		{
+			name:  "exhausted max order",
+			order: maxOrder,
+			size:  minMemSize << 2,
+		},
+		{
+			name:  "exhausted text",
+			order: maxOrder,
+			size:  1 << 16,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			rc, err := NewReader(props(table.order, table.size), uint64(len(b)), []io.ReadCloser{io.NopCloser(bytes.NewReader(encode(b, table.order, table.size)))})
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			assert.Equal(t, b, got)
+		})
+	}
+}
+
+func FuzzPPMd(f *testing.F) {
+	b := sample(1 << 12)
+
+	f.Add(byte(6), uint32(minMemSize), encode(b, 6, minMemSize))
+	f.Add(byte(maxOrder), uint32(minMemSize), encode(b, maxOrder, minMemSize))
+	f.Add(byte(minOrder), uint32(1<<16), encode(b, minOrder, 1<<16))
+
+	f.Fuzz(func(_ *testing.T, order byte, size uint32, b []byte) {
+		// Keep the memory, and the time spent restarting the model, sane
+		size = minMemSize + size%(1<<16)
+
+		rc, err := NewReader(props(int(order), size), 1<<12, []io.ReadCloser{io.NopCloser(bytes.NewReader(b))})
+		if err != nil {
+			return
+		}
+
+		// Anything is allowed except panicking, including reading after
+		// an error
+		for i := 0; i < 2; i++ {
+			_, _ = io.Copy(io.Discard, rc)
+		}
+	})
+}
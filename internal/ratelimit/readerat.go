@@ -0,0 +1,57 @@
+// Package ratelimit implements an io.ReaderAt that limits the rate at which
+// an underlying io.ReaderAt is read.
+package ratelimit
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ReaderAt is an io.ReaderAt that limits reads of an underlying io.ReaderAt
+// to an average number of bytes per second. Each read completes before the
+// caller is delayed for the time the bytes read should have taken, so the
+// rate is only enforced over several reads. It is safe for concurrent use,
+// with the limit shared between all callers.
+type ReaderAt struct {
+	r    io.ReaderAt
+	rate float64
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// NewReaderAt returns a ReaderAt reading r at no more than bytesPerSec bytes
+// per second.
+func NewReaderAt(r io.ReaderAt, bytesPerSec int64) *ReaderAt {
+	return &ReaderAt{
+		r:    r,
+		rate: float64(bytesPerSec),
+	}
+}
+
+// wait blocks until n more bytes can be read without exceeding the rate.
+func (ra *ReaderAt) wait(n int) {
+	ra.mutex.Lock()
+
+	// Time spent idle doesn't allow a later burst
+	now := time.Now()
+	if ra.next.Before(now) {
+		ra.next = now
+	}
+
+	ra.next = ra.next.Add(time.Duration(float64(n) / ra.rate * float64(time.Second)))
+	d := ra.next.Sub(now)
+
+	ra.mutex.Unlock()
+
+	time.Sleep(d)
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (ra *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := ra.r.ReadAt(p, off)
+	ra.wait(n)
+
+	return n, err //nolint:wrapcheck
+}
@@ -0,0 +1,66 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bodgit/sevenzip/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderAt(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		readers int
+	}{
+		{
+			name:    "serial",
+			readers: 1,
+		},
+		{
+			name:    "concurrent",
+			readers: 4,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := bytes.Repeat([]byte{0xaa}, 1000)
+			ra := ratelimit.NewReaderAt(bytes.NewReader(b), 4000)
+
+			start := time.Now()
+
+			var wg sync.WaitGroup
+
+			// 4 reads of 250 bytes at 4000 bytes per second
+			// should take 250ms regardless of concurrency
+			for i := 0; i < table.readers; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					p := make([]byte, 250)
+
+					for off := i * len(p); off < len(b); off += table.readers * len(p) {
+						n, err := ra.ReadAt(p, int64(off))
+						assert.NoError(t, err)
+						assert.Equal(t, len(p), n)
+					}
+				}(i)
+			}
+
+			wg.Wait()
+
+			require.GreaterOrEqual(t, time.Since(start), 240*time.Millisecond)
+		})
+	}
+}
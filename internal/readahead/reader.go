@@ -0,0 +1,138 @@
+// Package readahead implements an io.ReadCloser that reads ahead of its
+// consumer from an underlying io.Reader in a separate goroutine.
+package readahead
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+type block struct {
+	b   []byte
+	err error
+}
+
+// Reader reads blocks from an underlying io.Reader in the background while
+// the blocks already read are consumed, so that slow I/O overlaps with
+// whatever the consumer is doing. The goroutine is started by the first
+// read and is stopped by Close, which must be called.
+type Reader struct {
+	r      io.Reader
+	size   int
+	blocks int
+
+	start  sync.Once
+	full   chan block
+	free   chan []byte
+	done   chan struct{}
+	exited chan struct{}
+	close  sync.Once
+
+	buf []byte // block being consumed
+	cur []byte // what's left of it
+	err error
+}
+
+// NewReader returns a Reader reading r in blocks of size bytes, keeping up to
+// blocks of them ready to be consumed.
+func NewReader(r io.Reader, size, blocks int) *Reader {
+	return &Reader{
+		r:      r,
+		size:   size,
+		blocks: blocks,
+		full:   make(chan block, blocks),
+		free:   make(chan []byte, blocks),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (ra *Reader) run() {
+	defer close(ra.exited)
+
+	for i := 0; i < ra.blocks; i++ {
+		ra.free <- make([]byte, ra.size)
+	}
+
+	for {
+		var b []byte
+
+		select {
+		case b = <-ra.free:
+		case <-ra.done:
+			return
+		}
+
+		n, err := io.ReadFull(ra.r, b)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+
+		select {
+		case ra.full <- block{b[:n], err}:
+		case <-ra.done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read implements the io.Reader interface.
+func (ra *Reader) Read(p []byte) (int, error) {
+	select {
+	case <-ra.done:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	ra.start.Do(func() {
+		go ra.run()
+	})
+
+	for len(ra.cur) == 0 {
+		if ra.err != nil {
+			return 0, ra.err
+		}
+
+		if ra.buf != nil {
+			ra.free <- ra.buf[:cap(ra.buf)]
+			ra.buf = nil
+		}
+
+		select {
+		case b := <-ra.full:
+			ra.buf, ra.cur, ra.err = b.b, b.b, b.err
+		case <-ra.done:
+			return 0, io.ErrClosedPipe
+		}
+	}
+
+	n := copy(p, ra.cur)
+	ra.cur = ra.cur[n:]
+
+	return n, nil
+}
+
+// Close stops reading ahead, waiting for any read of the underlying
+// io.Reader in progress to finish. It doesn't close the underlying
+// io.Reader.
+func (ra *Reader) Close() error {
+	ra.close.Do(func() {
+		close(ra.done)
+
+		started := true
+		ra.start.Do(func() {
+			started = false
+		})
+
+		if started {
+			<-ra.exited
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package readahead_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/bodgit/sevenzip/internal/readahead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	b := make([]byte, 10000)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	tables := []struct {
+		name         string
+		size, blocks int
+		reader       func(io.Reader) io.Reader
+	}{
+		{
+			name:   "double buffered",
+			size:   1024,
+			blocks: 2,
+			reader: func(r io.Reader) io.Reader { return r },
+		},
+		{
+			name:   "one byte reads",
+			size:   100,
+			blocks: 4,
+			reader: iotest.OneByteReader,
+		},
+		{
+			name:   "block bigger than input",
+			size:   1 << 16,
+			blocks: 1,
+			reader: iotest.HalfReader,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			ra := readahead.NewReader(iotest.HalfReader(bytes.NewReader(b)), table.size, table.blocks)
+
+			out, err := io.ReadAll(table.reader(ra))
+			require.NoError(t, err)
+			assert.Equal(t, b, out)
+
+			require.NoError(t, ra.Close())
+			require.NoError(t, ra.Close())
+		})
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	t.Parallel()
+
+	ra := readahead.NewReader(iotest.TimeoutReader(bytes.NewReader(make([]byte, 100))), 50, 2)
+
+	n, err := io.ReadFull(ra, make([]byte, 100))
+	assert.Equal(t, 50, n)
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+
+	require.NoError(t, ra.Close())
+}
+
+func TestReaderClose(t *testing.T) {
+	t.Parallel()
+
+	// Closing stops the goroutine even though nothing is consuming
+	ra := readahead.NewReader(bytes.NewReader(make([]byte, 1000)), 10, 2)
+
+	_, err := ra.Read(make([]byte, 1))
+	require.NoError(t, err)
+	require.NoError(t, ra.Close())
+
+	_, err = ra.Read(make([]byte, 100))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	// Closing without ever reading
+	require.NoError(t, readahead.NewReader(bytes.NewReader(nil), 10, 2).Close())
+}
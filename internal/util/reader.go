@@ -1,7 +1,14 @@
 // Package util implements various utility types and interfaces.
 package util
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// ErrMemoryLimit is returned when decompressing would need more memory than
+// permitted.
+var ErrMemoryLimit = errors.New("sevenzip: memory limit exceeded")
 
 // SizeReadSeekCloser is an io.Reader, io.Seeker, and io.Closer with a Size
 // method.
@@ -0,0 +1,64 @@
+// Package xz implements the XZ decompressor.
+package xz
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+type readCloser struct {
+	c io.Closer
+	r io.Reader
+}
+
+var (
+	errAlreadyClosed = errors.New("xz: already closed")
+	errNeedOneReader = errors.New("xz: need exactly one reader")
+)
+
+func (rc *readCloser) Close() error {
+	if rc.c == nil || rc.r == nil {
+		return errAlreadyClosed
+	}
+
+	if err := rc.c.Close(); err != nil {
+		return fmt.Errorf("xz: error closing: %w", err)
+	}
+
+	rc.c, rc.r = nil, nil
+
+	return nil
+}
+
+func (rc *readCloser) Read(p []byte) (int, error) {
+	if rc.r == nil {
+		return 0, errAlreadyClosed
+	}
+
+	n, err := rc.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("xz: error reading: %w", err)
+	}
+
+	return n, err
+}
+
+// NewReader returns a new XZ io.ReadCloser.
+func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	if len(readers) != 1 {
+		return nil, errNeedOneReader
+	}
+
+	r, err := xz.NewReader(readers[0])
+	if err != nil {
+		return nil, fmt.Errorf("xz: error creating reader: %w", err)
+	}
+
+	return &readCloser{
+		c: readers[0],
+		r: r,
+	}, nil
+}
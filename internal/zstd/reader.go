@@ -6,20 +6,18 @@ import (
 	"fmt"
 	"io"
 	"runtime"
-	"sync"
 
+	"github.com/bodgit/sevenzip/internal/util"
 	"github.com/klauspost/compress/zstd"
 )
 
 type readCloser struct {
-	c io.Closer
-	r *zstd.Decoder
+	c      io.Closer
+	r      *zstd.Decoder
+	pooled bool
 }
 
 var (
-	//nolint:gochecknoglobals
-	zstdReaderPool sync.Pool
-
 	errAlreadyClosed = errors.New("zstd: already closed")
 	errNeedOneReader = errors.New("zstd: need exactly one reader")
 )
@@ -33,48 +31,126 @@ func (rc *readCloser) Close() error {
 		return fmt.Errorf("zstd: error closing: %w", err)
 	}
 
-	zstdReaderPool.Put(rc.r)
-	rc.c, rc.r = nil, nil
+	if rc.pooled {
+		// Stop any decoding in the background so nothing reads from
+		// the underlying reader once it's closed, but keep the
+		// decoder so Reset can reuse it
+		_ = rc.r.Reset(nil)
+	} else {
+		rc.r.Close()
+		rc.r = nil
+	}
+
+	rc.c = nil
 
 	return nil
 }
 
 func (rc *readCloser) Read(p []byte) (int, error) {
-	if rc.r == nil {
+	if rc.c == nil {
 		return 0, errAlreadyClosed
 	}
 
 	n, err := rc.r.Read(p)
 	if err != nil && !errors.Is(err, io.EOF) {
+		if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			err = fmt.Errorf("%w: %w", util.ErrMemoryLimit, err)
+		}
+
 		err = fmt.Errorf("zstd: error reading: %w", err)
 	}
 
 	return n, err
 }
 
+// Reset reinitialises rc to read a new stream, reusing the decoder.
+func (rc *readCloser) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
+	if len(readers) != 1 {
+		return errNeedOneReader
+	}
+
+	if rc.r == nil {
+		return errAlreadyClosed
+	}
+
+	if err := rc.r.Reset(readers[0]); err != nil {
+		return fmt.Errorf("zstd: error resetting: %w", err)
+	}
+
+	rc.c = readers[0]
+
+	return nil
+}
+
 // NewReader returns a new Zstandard io.ReadCloser.
 func NewReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
 	if len(readers) != 1 {
 		return nil, errNeedOneReader
 	}
 
-	var err error
+	r, err := zstd.NewReader(readers[0])
+	if err != nil {
+		return nil, fmt.Errorf("zstd: error creating reader: %w", err)
+	}
 
-	r, ok := zstdReaderPool.Get().(*zstd.Decoder)
-	if ok {
-		if err = r.Reset(readers[0]); err != nil {
-			return nil, fmt.Errorf("zstd: error resetting: %w", err)
+	// The decoder is kept when closed so it can be reset and reused,
+	// make sure its goroutines are stopped once it's discarded
+	runtime.SetFinalizer(r, (*zstd.Decoder).Close)
+
+	return &readCloser{
+		c:      readers[0],
+		r:      r,
+		pooled: true,
+	}, nil
+}
+
+// Options tunes the Zstandard decoder, a zero field uses the default.
+type Options struct {
+	// MaxWindow rejects any frame with a larger window.
+	MaxWindow uint64
+	// MaxMemory rejects any frame needing more memory to decode.
+	MaxMemory uint64
+	// Concurrency is how many goroutines decode blocks.
+	Concurrency int
+	// LowMem trades some speed for lower memory use.
+	LowMem bool
+}
+
+func (o Options) decoderOptions() []zstd.DOption {
+	var opts []zstd.DOption
+
+	if o.MaxWindow > 0 {
+		opts = append(opts, zstd.WithDecoderMaxWindow(o.MaxWindow))
+	}
+
+	if o.MaxMemory > 0 {
+		opts = append(opts, zstd.WithDecoderMaxMemory(o.MaxMemory))
+	}
+
+	if o.Concurrency > 0 {
+		opts = append(opts, zstd.WithDecoderConcurrency(o.Concurrency))
+	}
+
+	return append(opts, zstd.WithDecoderLowmem(o.LowMem))
+}
+
+// NewReaderWithOptions returns a function that creates Zstandard
+// io.ReadCloser's using decoders tuned with o. Unlike [NewReader], the
+// decoders are closed rather than kept for reuse.
+func NewReaderWithOptions(o Options) func([]byte, uint64, []io.ReadCloser) (io.ReadCloser, error) {
+	return func(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		if len(readers) != 1 {
+			return nil, errNeedOneReader
 		}
-	} else {
-		if r, err = zstd.NewReader(readers[0]); err != nil {
+
+		r, err := zstd.NewReader(readers[0], o.decoderOptions()...)
+		if err != nil {
 			return nil, fmt.Errorf("zstd: error creating reader: %w", err)
 		}
 
-		runtime.SetFinalizer(r, (*zstd.Decoder).Close)
+		return &readCloser{
+			c: readers[0],
+			r: r,
+		}, nil
 	}
-
-	return &readCloser{
-		c: readers[0],
-		r: r,
-	}, nil
 }
@@ -0,0 +1,30 @@
+//go:build go1.23
+
+package sevenzip
+
+import "iter"
+
+// Files returns an iterator over the files in the archive in the order they
+// are stored, which is also the most efficient order to read them in. Each
+// file is yielded as it's reached without building any intermediate slice.
+// If the archive was opened with [WithChangeDetection] and has since
+// changed, a single [ErrArchiveChanged] error is yielded instead, as the
+// files no longer describe the archive. Iteration should stop at the first
+// non-nil error.
+func (z *Reader) Files() iter.Seq2[*File, error] {
+	return func(yield func(*File, error) bool) {
+		if z.changed != nil {
+			if err := z.changed(); err != nil {
+				yield(nil, err)
+
+				return
+			}
+		}
+
+		for _, f := range z.File {
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
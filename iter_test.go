@@ -0,0 +1,65 @@
+//go:build go1.23
+
+package sevenzip_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	i := 0
+
+	for f, err := range r.Files() {
+		require.NoError(t, err)
+		assert.Same(t, r.File[i], f)
+
+		i++
+	}
+
+	assert.Len(t, r.File, i)
+
+	// Check stopping early works
+	for range r.Files() {
+		break
+	}
+}
+
+func TestFilesChanged(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "lzma.7z"))
+	require.NoError(t, err)
+
+	r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)), sevenzip.WithChangeDetection())
+	require.NoError(t, err)
+
+	// Change the offset of the header
+	b[12]++
+
+	i := 0
+
+	for f, err := range r.Files() {
+		assert.Nil(t, f)
+		assert.ErrorIs(t, err, sevenzip.ErrArchiveChanged)
+
+		i++
+	}
+
+	assert.Equal(t, 1, i)
+}
@@ -0,0 +1,112 @@
+package sevenzip
+
+import (
+	"encoding/json"
+	iofs "io/fs"
+	"time"
+)
+
+// ArchiveListing is a machine-readable summary of the contents of an
+// archive, suitable for encoding as JSON.
+type ArchiveListing struct {
+	// Size is the total uncompressed size of the entries.
+	Size uint64 `json:"size"`
+
+	// PackedSize is the total compressed size of the entries.
+	PackedSize uint64 `json:"packed_size"`
+
+	// Entries lists each entry in the order that the archive stores them.
+	Entries []ListingEntry `json:"entries"`
+}
+
+// ListingEntry describes a single entry in an [ArchiveListing]. It's also
+// how a [FileHeader] is encoded as JSON.
+type ListingEntry struct {
+	Name string        `json:"name"`
+	Mode iofs.FileMode `json:"mode"`
+
+	// Attributes are the raw attributes stored in the archive.
+	Attributes uint32 `json:"attributes"`
+
+	Size       uint64 `json:"size"`
+	PackedSize uint64 `json:"packed_size"`
+
+	// Methods lists the names of the methods used to decompress the entry,
+	// as returned by [MethodName], in the order that the archive stores
+	// them. It's empty for directories and empty files.
+	Methods []string `json:"methods,omitempty"`
+
+	// CRC32 is the checksum of the entry, or zero if the archive doesn't
+	// store one.
+	CRC32 uint32 `json:"crc32"`
+
+	// Created, Accessed and Modified are zero if the archive doesn't store
+	// them, they are then omitted from the JSON encoding.
+	Created  time.Time `json:"created"`
+	Accessed time.Time `json:"accessed"`
+	Modified time.Time `json:"modified"`
+
+	// Anti is true if the entry is an anti item.
+	Anti bool `json:"anti,omitempty"`
+}
+
+// MarshalJSON implements the [json.Marshaler] interface, omitting any
+// timestamps that aren't stored.
+func (e ListingEntry) MarshalJSON() ([]byte, error) {
+	type entry ListingEntry
+
+	optional := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+
+		return &t
+	}
+
+	return json.Marshal(struct { //nolint:wrapcheck
+		entry
+		Created  *time.Time `json:"created,omitempty"`
+		Accessed *time.Time `json:"accessed,omitempty"`
+		Modified *time.Time `json:"modified,omitempty"`
+	}{
+		entry:    entry(e),
+		Created:  optional(e.Created),
+		Accessed: optional(e.Accessed),
+		Modified: optional(e.Modified),
+	})
+}
+
+func (h *FileHeader) listingEntry() ListingEntry {
+	return ListingEntry{
+		Name:       h.Name,
+		Mode:       h.Mode(),
+		Attributes: h.Attributes,
+		Size:       h.UncompressedSize,
+		PackedSize: h.PackedSize,
+		Methods:    h.methodNames(),
+		CRC32:      h.CRC32,
+		Created:    h.Created,
+		Accessed:   h.Accessed,
+		Modified:   h.Modified,
+		Anti:       h.IsAnti,
+	}
+}
+
+// Listing returns an [ArchiveListing] of the archive. It also works with
+// archives opened with [ListOnly], although the CRC32 of each entry is then
+// zero.
+func (z *Reader) Listing() ArchiveListing {
+	l := ArchiveListing{
+		Entries: make([]ListingEntry, 0, len(z.File)),
+	}
+
+	for _, f := range z.File {
+		e := f.listingEntry()
+
+		l.Size += e.Size
+		l.PackedSize += e.PackedSize
+		l.Entries = append(l.Entries, e)
+	}
+
+	return l
+}
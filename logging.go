@@ -0,0 +1,64 @@
+package sevenzip
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// debugEnabled reports whether debug logs are wanted, so that building them
+// can be skipped.
+func (z *Reader) debugEnabled() bool {
+	return z.logger != nil && z.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+func (z *Reader) debug(msg string, attrs ...slog.Attr) {
+	if z.debugEnabled() {
+		z.logger.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+	}
+}
+
+// folderAttrs describes how folder f in si is decoded: where its packed
+// streams are, the chain of coders and how their inputs and outputs are
+// bound together.
+func folderAttrs(si *streamsInfo, f int) []slog.Attr {
+	folder := si.unpackInfo.folder[f]
+
+	coders := make([]string, 0, len(folder.coder))
+	for _, c := range folder.coder {
+		coders = append(coders, fmt.Sprintf("%s(in=%d,out=%d,props=%x)", MethodName(c.id), c.in, c.out, c.properties))
+	}
+
+	bindPairs := make([]string, 0, len(folder.bindPair))
+	for _, bp := range folder.bindPair {
+		bindPairs = append(bindPairs, fmt.Sprintf("out%d->in%d", bp.out, bp.in))
+	}
+
+	k := si.packedStream[f]
+
+	packedSizes := make([]uint64, 0, folder.packedStreams)
+	for j := k; j < k+folder.packedStreams && j < uint64(len(si.packInfo.size)); j++ {
+		packedSizes = append(packedSizes, si.packInfo.size[j])
+	}
+
+	return []slog.Attr{
+		slog.Int("folder", f),
+		slog.Int64("offset", si.folderOffset(f)),
+		slog.Any("coders", coders),
+		slog.Any("bind_pairs", bindPairs),
+		slog.Any("packed_inputs", folder.packed),
+		slog.Any("packed_sizes", packedSizes),
+		slog.Any("unpack_sizes", folder.size),
+	}
+}
+
+// debugStreamsInfo logs each folder in si.
+func (z *Reader) debugStreamsInfo(msg string, si *streamsInfo) {
+	if !z.debugEnabled() {
+		return
+	}
+
+	for i := 0; i < si.Folders(); i++ {
+		z.debug(msg, folderAttrs(si, i)...)
+	}
+}
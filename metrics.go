@@ -0,0 +1,101 @@
+package sevenzip
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// A Collector receives events from a [Reader] as it reads the archive, so
+// that long-running services can export metrics about archive processing.
+// Its methods are called as the events happen, possibly from several
+// goroutines at once, so they must be safe for concurrent use and should
+// return quickly. Embed [NopCollector] to implement only some of them.
+type Collector interface {
+	// PackedRead is called with the number of bytes read from the
+	// archive, which includes the header.
+	PackedRead(n int)
+	// Decoded is called with the number of bytes produced by decoding a
+	// stream or the header.
+	Decoded(n int)
+	// StreamDecoded is called each time a stream is decoded from its
+	// beginning.
+	StreamDecoded(stream int)
+	// PoolHit is called each time a file is opened by reusing a
+	// partially-read stream from the pool.
+	PoolHit()
+	// PoolMiss is called each time a file is opened by decoding its
+	// stream from the beginning.
+	PoolMiss()
+	// CodecTime is called with the time spent decoding by a method,
+	// named as returned by [MethodName]. This excludes the time spent
+	// reading its input, either from the archive or from another method
+	// in the same stream.
+	CodecTime(method string, d time.Duration)
+}
+
+// NopCollector is a [Collector] that ignores every event.
+type NopCollector struct{}
+
+// PackedRead implements the [Collector] interface.
+func (NopCollector) PackedRead(int) {}
+
+// Decoded implements the [Collector] interface.
+func (NopCollector) Decoded(int) {}
+
+// StreamDecoded implements the [Collector] interface.
+func (NopCollector) StreamDecoded(int) {}
+
+// PoolHit implements the [Collector] interface.
+func (NopCollector) PoolHit() {}
+
+// PoolMiss implements the [Collector] interface.
+func (NopCollector) PoolMiss() {}
+
+// CodecTime implements the [Collector] interface.
+func (NopCollector) CodecTime(string, time.Duration) {}
+
+var _ Collector = NopCollector{}
+
+// metricsReaderAt reports every read of the archive to a Collector.
+type metricsReaderAt struct {
+	r       io.ReaderAt
+	metrics Collector
+}
+
+func (ra *metricsReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := ra.r.ReadAt(p, off)
+	ra.metrics.PackedRead(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// codecTimer times each read of a coder. The time spent by its inputs is
+// collected by their own codecTimer, which is then subtracted so only the
+// time spent in the coder itself is reported. A codecTimer without a
+// method only times its reads, which is used for the packed streams.
+type codecTimer struct {
+	io.ReadCloser
+	method   string
+	metrics  Collector
+	inputs   atomic.Int64
+	consumer *codecTimer
+}
+
+func (t *codecTimer) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.ReadCloser.Read(p)
+	d := time.Since(start)
+
+	if t.consumer != nil {
+		t.consumer.inputs.Add(int64(d))
+	}
+
+	if t.metrics != nil {
+		// Decoders that read their input from another goroutine can
+		// make this negative
+		t.metrics.CodecTime(t.method, max(d-time.Duration(t.inputs.Swap(0)), 0))
+	}
+
+	return n, err //nolint:wrapcheck
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package sevenzip
+
+import "github.com/spf13/afero"
+
+// mapFile returns f unchanged as memory mapping isn't supported.
+func mapFile(f afero.File, _ int64) (afero.File, error) {
+	return f, nil
+}
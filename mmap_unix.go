@@ -0,0 +1,74 @@
+//go:build unix
+
+package sevenzip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// mmapFile is an afero.File that reads from a memory mapping of the file.
+type mmapFile struct {
+	afero.File
+	b []byte
+}
+
+// mapFile returns f reading from a memory mapping of its size bytes. Files
+// that can't be mapped, such as empty ones, are returned unchanged. If
+// mapping fails f is closed.
+func mapFile(f afero.File, size int64) (afero.File, error) {
+	fd, ok := f.(interface{ Fd() uintptr })
+	if !ok || size <= 0 || int64(int(size)) != size {
+		return f, nil
+	}
+
+	b, err := syscall.Mmap(int(fd.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("sevenzip: error mapping: %w", errors.Join(err, f.Close()))
+	}
+
+	return &mmapFile{f, b}, nil
+}
+
+func (f *mmapFile) Close() error {
+	err := syscall.Munmap(f.b)
+	f.b = nil
+
+	return errors.Join(err, f.File.Close()) //nolint:wrapcheck
+}
+
+func (f *mmapFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off >= int64(len(f.b)) {
+		return 0, io.EOF
+	}
+
+	// Reading a page of the mapping that's beyond the end of the file,
+	// because it's been truncated since it was mapped, raises SIGBUS.
+	// Return an error for that rather than crash
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+
+			n, err = 0, fmt.Errorf("%w: volume truncated while mapped", ErrArchiveChanged)
+		}
+	}()
+
+	n = copy(p, f.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package sevenzip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMmapTruncated(t *testing.T) {
+	t.Parallel()
+
+	size := 2 * os.Getpagesize()
+	name := filepath.Join(t.TempDir(), "volume")
+	require.NoError(t, os.WriteFile(name, make([]byte, size), 0o600))
+
+	f, err := os.Open(name)
+	require.NoError(t, err)
+
+	mf, err := mapFile(f, int64(size))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, mf.Close())
+	}()
+
+	p := make([]byte, 16)
+
+	_, err = mf.ReadAt(p, int64(size/2))
+	require.NoError(t, err)
+
+	// Reading the truncated mapping fails rather than crashing
+	require.NoError(t, os.Truncate(name, 0))
+
+	_, err = mf.ReadAt(p, int64(size/2))
+	assert.ErrorIs(t, err, ErrArchiveChanged)
+}
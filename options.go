@@ -0,0 +1,621 @@
+package sevenzip
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/bodgit/sevenzip/internal/cache"
+	"github.com/bodgit/sevenzip/internal/pool"
+	"github.com/bodgit/sevenzip/internal/util"
+)
+
+var (
+	errInvalidDuplicatePolicy = errors.New("sevenzip: invalid duplicate policy")
+	errNilNameMapping         = errors.New("sevenzip: name mapping cannot be nil")
+	errInvalidNamePolicy      = errors.New("sevenzip: invalid name policy")
+	errInvalidSearchLimit     = errors.New("sevenzip: search limit must be positive")
+	errInvalidArchiveOffset   = errors.New("sevenzip: archive offset cannot be negative")
+	errInvalidMaxEntries      = errors.New("sevenzip: header entry limit must be positive")
+	errInvalidMaxMemory       = errors.New("sevenzip: memory limit must be positive")
+	errInvalidMaxDictionary   = errors.New("sevenzip: dictionary size must be at least 4 KiB")
+	errInvalidZstdWindow      = errors.New("sevenzip: zstd window must be at least 1 KiB")
+	errInvalidZstdConcurrency = errors.New("sevenzip: zstd concurrency must be positive")
+	errInvalidCacheSize       = errors.New("sevenzip: cache size must be positive")
+	errInvalidReadCache       = errors.New("sevenzip: read cache block size and count must be positive")
+	errInvalidBufferSize      = errors.New("sevenzip: read buffer size must be positive")
+	errInvalidReadAhead       = errors.New("sevenzip: read ahead block size and count must be positive")
+	errInvalidMaxVolumes      = errors.New("sevenzip: open volume limit must be positive")
+	errInvalidRateLimit       = errors.New("sevenzip: rate limit must be positive")
+	errNilCollector           = errors.New("sevenzip: collector cannot be nil")
+	errNilLogger              = errors.New("sevenzip: logger cannot be nil")
+	errNilPasswordCallback    = errors.New("sevenzip: password callback cannot be nil")
+	errInvalidPoolSize        = errors.New("sevenzip: pool size cannot be negative")
+	errInvalidSeekDistance    = errors.New("sevenzip: seek distance must be positive")
+	errNilPoolConstructor     = errors.New("sevenzip: pool constructor cannot be nil")
+	errNilSharedPool          = errors.New("sevenzip: shared pool cannot be nil")
+	errInvalidOpenWait        = errors.New("sevenzip: open wait must be positive")
+)
+
+// A ReaderOption configures a [Reader] as it is opened.
+type ReaderOption func(*Reader) error
+
+func (z *Reader) applyOptions(opts []ReaderOption) error {
+	for _, opt := range opts {
+		if err := opt(z); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithCaseInsensitiveFS makes the [fs.FS] methods of the [Reader] match names
+// case-insensitively if there is no exact match. If more than one entry in
+// the archive matches, the entry that sorts first is used. [Reader.Glob] is
+// unaffected.
+func WithCaseInsensitiveFS() ReaderOption {
+	return func(z *Reader) error {
+		z.caseInsensitive = true
+
+		return nil
+	}
+}
+
+// DuplicatePolicy controls how the [fs.FS] methods of a [Reader] handle more
+// than one entry in the archive with the same name.
+type DuplicatePolicy int
+
+const (
+	// DuplicateError causes any attempt to open or stat a duplicated
+	// name to fail. This is the default.
+	DuplicateError DuplicatePolicy = iota
+	// DuplicateFirstWins uses the first entry in the archive with the
+	// name.
+	DuplicateFirstWins
+	// DuplicateLastWins uses the last entry in the archive with the
+	// name.
+	DuplicateLastWins
+)
+
+// WithDuplicatePolicy sets how the [fs.FS] methods of the [Reader] handle
+// duplicated names. With either [DuplicateFirstWins] or [DuplicateLastWins],
+// if a file has the same name as a directory implied by other entries, the
+// directory is used.
+func WithDuplicatePolicy(policy DuplicatePolicy) ReaderOption {
+	return func(z *Reader) error {
+		switch policy {
+		case DuplicateError, DuplicateFirstWins, DuplicateLastWins:
+		default:
+			return errInvalidDuplicatePolicy
+		}
+
+		z.duplicates = policy
+
+		return nil
+	}
+}
+
+// WithNameMapping sets the function used to map the name of each entry in
+// the archive to its name in the [fs.FS] methods of the [Reader], replacing
+// the default of [FileHeader.CleanName]. Entries are left out if fn returns
+// a name that isn't valid according to [fs.ValidPath], or ".". The
+// Name field of each [File] is unaffected.
+func WithNameMapping(fn func(name string) string) ReaderOption {
+	return func(z *Reader) error {
+		if fn == nil {
+			return errNilNameMapping
+		}
+
+		z.nameMapping = fn
+
+		return nil
+	}
+}
+
+// NamePolicy controls how a [Reader] handles names that aren't valid UTF-16,
+// such as those containing unpaired surrogates.
+type NamePolicy int
+
+const (
+	// NameReplace replaces any invalid sequences in a name with U+FFFD.
+	// This is the default.
+	NameReplace NamePolicy = iota
+	// NameError causes opening the archive to fail if any name is
+	// invalid.
+	NameError
+	// NameRaw behaves like NameReplace but also populates
+	// [FileHeader.RawName] for every file with the bytes stored in the
+	// archive.
+	NameRaw
+)
+
+// WithNamePolicy sets how names that aren't valid UTF-16 are handled.
+func WithNamePolicy(policy NamePolicy) ReaderOption {
+	return func(z *Reader) error {
+		switch policy {
+		case NameReplace, NameError, NameRaw:
+		default:
+			return errInvalidNamePolicy
+		}
+
+		z.namePolicy = policy
+
+		return nil
+	}
+}
+
+// WithPasswordCallback sets a function that is called to obtain the password
+// when an encrypted stream is first read, rather than using a fixed
+// password. It is passed the [File] being opened, or the first file in the
+// stream when using [Reader.OpenStream]. It is passed nil if the archive
+// header itself is encrypted, or if [Reader.OpenStream] opens a stream that
+// contains no files. As files in the same stream share the same encryption,
+// the function is not necessarily called for every file.
+func WithPasswordCallback(fn func(f *File) (string, error)) ReaderOption {
+	return func(z *Reader) error {
+		if fn == nil {
+			return errNilPasswordCallback
+		}
+
+		z.passwordCallback = fn
+
+		return nil
+	}
+}
+
+// WithPasswordBytes sets the password used as the basis of the decryption key,
+// in place of any password passed as a string. The [Reader] keeps its own
+// copy of password, which is zeroed by [Reader.Wipe] or [ReadCloser.Close],
+// along with any cached keys derived from it.
+func WithPasswordBytes(password []byte) ReaderOption {
+	return func(z *Reader) error {
+		z.pb = bytes.Clone(password)
+
+		return nil
+	}
+}
+
+// WithSignatureSearchLimit sets how many bytes from the start of the file are
+// searched for the 7-zip signature, which allows finding archives appended to
+// large self-extracting executables. The default is 1 MiB.
+func WithSignatureSearchLimit(n int64) ReaderOption {
+	return func(z *Reader) error {
+		if n <= 0 {
+			return errInvalidSearchLimit
+		}
+
+		z.searchLimit = n
+
+		return nil
+	}
+}
+
+// WithArchiveOffset sets the offset of the 7-zip signature within the file
+// when it is already known, skipping the search for it entirely.
+func WithArchiveOffset(off int64) ReaderOption {
+	return func(z *Reader) error {
+		if off < 0 {
+			return errInvalidArchiveOffset
+		}
+
+		z.archiveOffset = off
+		z.archiveOffsetSet = true
+
+		return nil
+	}
+}
+
+// WithMaxHeaderEntries sets the most files, streams or packed streams the
+// archive header may declare, which by default is 4,194,304. Space for each
+// is allocated as soon as the header declares how many there are, so when
+// opening untrusted archives a lower limit stops a small crafted header
+// from allocating large amounts of memory. An archive with more fails to
+// open with an error.
+func WithMaxHeaderEntries(n int) ReaderOption {
+	return func(z *Reader) error {
+		if n <= 0 {
+			return errInvalidMaxEntries
+		}
+
+		z.maxEntries = uint64(n)
+
+		return nil
+	}
+}
+
+// WithMaxMemory limits how much memory the decompressors reading each stream
+// may allocate for their dictionaries and windows, which guards against
+// crafted archives that declare enormous ones. Streams that would exceed the
+// limit fail with [ErrMemoryLimit]; for LZMA and LZMA2 this is known before
+// decompressing but for Zstandard it is only known once each frame is read.
+func WithMaxMemory(bytes int64) ReaderOption {
+	return func(z *Reader) error {
+		if bytes <= 0 {
+			return errInvalidMaxMemory
+		}
+
+		z.maxMemory = uint64(bytes)
+
+		return nil
+	}
+}
+
+// minDictionarySize is the smallest dictionary the LZMA decompressors use.
+const minDictionarySize = 1 << 12
+
+// WithMaxDictionarySize caps the dictionary allocated for LZMA and LZMA2
+// streams at bytes, which must be at least 4 KiB. Unlike [WithMaxMemory],
+// streams declaring a larger dictionary are still read, which works as long
+// as they don't refer back further than the capped dictionary; otherwise
+// reading them fails. A dictionary can't usefully be larger than the data
+// it decompresses, so a cap doesn't affect streams smaller than it.
+func WithMaxDictionarySize(bytes int64) ReaderOption {
+	return func(z *Reader) error {
+		if bytes < minDictionarySize {
+			return errInvalidMaxDictionary
+		}
+
+		z.maxDict = uint64(bytes)
+
+		return nil
+	}
+}
+
+// WithZstdMaxWindow rejects any Zstandard frame with a window larger than
+// bytes, which must be at least 1 KiB. Combined with [WithMaxMemory], the
+// smaller of the two limits applies.
+func WithZstdMaxWindow(bytes int64) ReaderOption {
+	return func(z *Reader) error {
+		if bytes < minZstdWindow {
+			return errInvalidZstdWindow
+		}
+
+		z.zstd.MaxWindow = uint64(bytes)
+
+		return nil
+	}
+}
+
+// WithZstdConcurrency sets how many goroutines each Zstandard decoder uses to
+// decode blocks. Higher values can improve throughput on large streams at the
+// cost of more memory, a value of 1 decodes synchronously.
+func WithZstdConcurrency(n int) ReaderOption {
+	return func(z *Reader) error {
+		if n <= 0 {
+			return errInvalidZstdConcurrency
+		}
+
+		z.zstd.Concurrency = n
+
+		return nil
+	}
+}
+
+// WithZstdLowMemory makes each Zstandard decoder trade some speed for lower
+// memory use.
+func WithZstdLowMemory() ReaderOption {
+	return func(z *Reader) error {
+		z.zstd.LowMem = true
+
+		return nil
+	}
+}
+
+// WithDecompressors sets decompressors to use for this [Reader] only, taking
+// precedence over those registered with [RegisterDecompressor]. The map is
+// keyed by method ID and a nil [Decompressor] disables that method. This
+// allows codecs to be overridden without affecting any other [Reader].
+func WithDecompressors(decompressors map[string]Decompressor) ReaderOption {
+	return func(z *Reader) error {
+		if z.decompressors == nil {
+			z.decompressors = make(map[string]Decompressor, len(decompressors))
+		}
+
+		for method, dcomp := range decompressors {
+			z.decompressors[method] = dcomp
+		}
+
+		return nil
+	}
+}
+
+// ListOnly opens the archive just for listing its contents. Only enough of
+// the header is parsed to return the names, sizes, timestamps and other
+// metadata of each [File], skipping the checksums of each file and the
+// preparation needed to read them, so [File.Open] and [Reader.OpenStream]
+// will always fail.
+func ListOnly() ReaderOption {
+	return func(z *Reader) error {
+		z.listOnly = true
+
+		return nil
+	}
+}
+
+// WithCache enables a cache of up to size bytes holding the decoded contents
+// of streams. When a file is opened, the whole of its stream is decoded and
+// cached, provided it fits, so opening other files from the same stream,
+// in any order, doesn't decode it again. Streams larger than size are read
+// as normal.
+func WithCache(size int64) ReaderOption {
+	return func(z *Reader) error {
+		if size <= 0 {
+			return errInvalidCacheSize
+		}
+
+		z.cache = cache.New(size)
+
+		return nil
+	}
+}
+
+// WithReadCache caches up to blocks blocks of blockSize bytes read from the
+// underlying [io.ReaderAt], and reads any consecutive blocks that aren't
+// cached with a single read. This dramatically reduces the number of reads
+// made when extracting many small files from slow storage such as network
+// filesystems or object stores, where each read is expensive.
+func WithReadCache(blockSize, blocks int) ReaderOption {
+	return func(z *Reader) error {
+		if blockSize <= 0 || blocks <= 0 {
+			return errInvalidReadCache
+		}
+
+		z.readBlockSize, z.readBlocks = blockSize, blocks
+
+		return nil
+	}
+}
+
+// WithReadBufferSize sets the size of the buffer used to read each packed
+// stream, and the header, from the underlying [io.ReaderAt]. The default is
+// 4 KiB, which means many small reads; high-latency storage benefits from a
+// much bigger buffer such as 1 MiB. A buffer is allocated for each packed
+// stream being read, although never bigger than the stream itself.
+func WithReadBufferSize(n int) ReaderOption {
+	return func(z *Reader) error {
+		if n <= 0 {
+			return errInvalidBufferSize
+		}
+
+		z.bufferSize = n
+
+		return nil
+	}
+}
+
+// WithReadAhead reads each packed stream ahead of its decompressor in a
+// separate goroutine, keeping up to blocks blocks of blockSize bytes ready,
+// so that reading the archive overlaps with decompressing it. This helps
+// when the archive is on high-latency storage such as a network filesystem;
+// two blocks of 1 MiB give double buffering. The blocks are allocated for
+// each stream being read, although they're never bigger than the packed
+// stream itself.
+func WithReadAhead(blockSize, blocks int) ReaderOption {
+	return func(z *Reader) error {
+		if blockSize <= 0 || blocks <= 0 {
+			return errInvalidReadAhead
+		}
+
+		z.readAheadSize, z.readAheads = blockSize, blocks
+
+		return nil
+	}
+}
+
+// WithMmap makes [OpenReader] and [OpenReaderWithPassword] map each volume of
+// the archive into memory rather than reading it with system calls, which is
+// faster for archives with many small files. Data is still copied out of the
+// mapping as it's read, the mapping itself is never handed out as it would
+// be invalid once the archive is closed. It has no effect on platforms
+// without memory mapping or on the other ways of opening an archive.
+//
+// Reading part of a mapping beyond the end of a volume that has been
+// truncated since it was opened raises SIGBUS, which would normally kill the
+// process. This package recovers from that while it's reading the mapping
+// and fails the read with [ErrArchiveChanged] instead, whether or not
+// [WithChangeDetection] is used, but the archive still must not be truncated
+// while it's open.
+func WithMmap() ReaderOption {
+	return func(z *Reader) error {
+		z.mmap = true
+
+		return nil
+	}
+}
+
+// WithMaxOpenVolumes limits [OpenReader] and [OpenReaderWithPassword] to
+// keeping at most n volumes of a multi-volume archive open at once. Volumes
+// are always opened as they're first read, without a limit they then stay
+// open until the archive is closed. With a limit the least recently read
+// volume is closed to make room for another, and reads wait if all n are in
+// use. It has no effect on the other ways of opening an archive.
+func WithMaxOpenVolumes(n int) ReaderOption {
+	return func(z *Reader) error {
+		if n <= 0 {
+			return errInvalidMaxVolumes
+		}
+
+		z.maxVolumes = n
+
+		return nil
+	}
+}
+
+// WithChangeDetection records the archive's start header when it's opened,
+// along with the size and modification time of each volume if opened with
+// [OpenReader], and checks them each time a file or stream is opened and once
+// a stream, or a file that has to be decompressed, has been read. If they've
+// changed, the read fails with [ErrArchiveChanged] rather than return data
+// that could be corrupt. Modifying just the streams of an archive opened
+// with [NewReader] isn't detected, although any files affected are still
+// likely to fail their checksum.
+func WithChangeDetection() ReaderOption {
+	return func(z *Reader) error {
+		z.detectChanges = true
+
+		return nil
+	}
+}
+
+// WithRateLimit limits reading the archive to an average of bytesPerSec
+// bytes per second, shared between every file being read, so that
+// extracting in the background doesn't saturate shared storage or network
+// bandwidth. It applies to reads of the underlying [io.ReaderAt], or every
+// volume of a multi-volume archive, so bytes served by [WithReadCache] or
+// [WithCache] aren't counted.
+func WithRateLimit(bytesPerSec int64) ReaderOption {
+	return func(z *Reader) error {
+		if bytesPerSec <= 0 {
+			return errInvalidRateLimit
+		}
+
+		z.rateLimit = bytesPerSec
+
+		return nil
+	}
+}
+
+// WithMetrics sends events describing how the archive is read to c, such as
+// the number of bytes read and decoded, use of the pool of partially-read
+// streams and the time spent by each method. Timing each method adds a
+// small overhead to every read.
+func WithMetrics(c Collector) ReaderOption {
+	return func(z *Reader) error {
+		if c == nil {
+			return errNilCollector
+		}
+
+		z.metrics = c
+
+		return nil
+	}
+}
+
+// WithLogger sets a logger that receives debug logs describing the structure
+// of the archive as it's opened, such as where the header is, the chain of
+// coders used by each stream and how they're bound together, and where each
+// file is within its stream, as well as each time a stream is decoded.
+// Nothing is logged unless the logger has debug level enabled.
+func WithLogger(logger *slog.Logger) ReaderOption {
+	return func(z *Reader) error {
+		if logger == nil {
+			return errNilLogger
+		}
+
+		z.logger = logger
+
+		return nil
+	}
+}
+
+// SizeReadSeekCloser is a partially-read stream held in a [Pooler].
+type SizeReadSeekCloser = util.SizeReadSeekCloser
+
+// Pooler is implemented by a pool of partially-read streams, keyed by how far
+// each has been read. Get returns the stream at the offset, or the closest one
+// before it, removing it from the pool. Put adds a stream to the pool and
+// reports whether a stream was evicted and closed to make room. Close closes
+// any streams left in the pool and returns any errors from closing streams,
+// including those evicted earlier.
+type Pooler = pool.Pooler
+
+// PoolConstructor is called to create the [Pooler] for each stream in the
+// archive that contains more than one file.
+type PoolConstructor = pool.Constructor
+
+// WithPoolSize sets how many partially-read readers are retained for each
+// stream, which by default is the number of CPUs. Higher values suit callers
+// reading many files from the same stream in parallel, lower values reduce
+// memory use. A size of 0 disables pooling entirely, the same as
+// [WithoutPooling].
+func WithPoolSize(n int) ReaderOption {
+	return func(z *Reader) error {
+		if n < 0 {
+			return errInvalidPoolSize
+		}
+
+		z.poolSize, z.noPooling = n, n == 0
+
+		return nil
+	}
+}
+
+// WithMaxSeekDistance sets the furthest a partially-read stream from the pool
+// will be read forward, discarding the decoded bytes, to reach the start of
+// a file. If the closest stream in the pool is further behind than n bytes,
+// it is left in the pool and the stream is decoded from the beginning
+// instead. By default there is no limit.
+func WithMaxSeekDistance(n int64) ReaderOption {
+	return func(z *Reader) error {
+		if n <= 0 {
+			return errInvalidSeekDistance
+		}
+
+		z.maxSeek = n
+
+		return nil
+	}
+}
+
+// WithPoolConstructor sets the function used to create the [Pooler] for each
+// stream, for when [WithPoolSize] isn't sufficient. It takes precedence over
+// [WithPoolSize].
+func WithPoolConstructor(fn PoolConstructor) ReaderOption {
+	return func(z *Reader) error {
+		if fn == nil {
+			return errNilPoolConstructor
+		}
+
+		z.newPool = fn
+
+		return nil
+	}
+}
+
+// WithSharedPool makes the [Reader] use sp to hold partially-read streams,
+// sharing them with any other [Reader] using sp with the same id. The id must
+// uniquely identify the contents of the archive, for example its path.
+// Encrypted streams aren't shared. It takes precedence over [WithPoolSize] and
+// [WithPoolConstructor] for the other streams.
+func WithSharedPool(sp *SharedPool, id string) ReaderOption {
+	return func(z *Reader) error {
+		if sp == nil {
+			return errNilSharedPool
+		}
+
+		z.sharedPool, z.sharedPoolID = sp, id
+
+		return nil
+	}
+}
+
+// WithoutPooling disables the pool of partially-read streams for every
+// stream, however many files it contains, which saves the locking and memory
+// it needs when each file is only read once in order. It's shorthand for
+// [WithPoolSize] with a size of 0, and takes precedence over
+// [WithPoolConstructor], [WithSharedPool] and [WithOpenWait].
+func WithoutPooling() ReaderOption {
+	return WithPoolSize(0)
+}
+
+// WithOpenWait lets opening a file wait for up to d for another file in the
+// same stream, but before it, to be closed so that the partially-read
+// stream can be taken from the pool, rather than decoding the stream from
+// the beginning. This helps several goroutines opening files from the same
+// solid block at once share one decoding pass. A file opened while no
+// earlier file in its stream is open doesn't wait, but one opened while the
+// same goroutine still has an earlier file open waits for all of d. By
+// default files never wait.
+func WithOpenWait(d time.Duration) ReaderOption {
+	return func(z *Reader) error {
+		if d <= 0 {
+			return errInvalidOpenWait
+		}
+
+		z.openWait = d
+
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package sevenzip
+
+import (
+	iofs "io/fs"
+	"sort"
+)
+
+// ExtractionPlan describes the work needed to extract a subset of the files
+// in an archive, as returned by [Reader.Plan].
+type ExtractionPlan struct {
+	// Streams lists each stream containing at least one of the files,
+	// in the order that they're stored. Each stream can be extracted
+	// independently of the others.
+	Streams []StreamPlan
+
+	// Empty lists the directories and empty files, which need nothing
+	// to be decompressed.
+	Empty []*File
+
+	// Size is the total uncompressed size of the files.
+	Size uint64
+
+	// DecodedSize is the total number of bytes that must be
+	// decompressed to extract the files, which is more than Size if
+	// other files precede them in the same stream.
+	DecodedSize uint64
+}
+
+// StreamPlan describes the files to extract from a single stream in an
+// [ExtractionPlan].
+type StreamPlan struct {
+	// Stream is the identifier of the stream, matching the value of
+	// [FileHeader.Stream] for each file.
+	Stream int
+
+	// Files lists the files in the order that they should be opened,
+	// which is the order that they are decompressed.
+	Files []*File
+
+	// Size is the total uncompressed size of the files.
+	Size uint64
+
+	// DecodedSize is the number of bytes that must be decompressed to
+	// reach the end of the last file, as the stream can only be
+	// decompressed from the start.
+	DecodedSize uint64
+}
+
+// Plan returns an [ExtractionPlan] for extracting the named files, which use
+// the same names as the [fs.FS] methods of the [Reader]. Symbolic links are
+// not followed, and naming a directory doesn't include its contents. Each
+// file is included once however many times it is named.
+func (z *Reader) Plan(names []string) (*ExtractionPlan, error) {
+	z.initFileList()
+
+	plan := new(ExtractionPlan)
+	streams := make(map[int]*StreamPlan)
+	seen := make(map[*File]struct{}, len(names))
+
+	for _, name := range names {
+		if !iofs.ValidPath(name) {
+			return nil, &iofs.PathError{Op: "plan", Path: name, Err: iofs.ErrInvalid}
+		}
+
+		e := z.openLookup(name)
+		if e == nil {
+			return nil, &iofs.PathError{Op: "plan", Path: name, Err: iofs.ErrNotExist}
+		}
+
+		if _, err := e.stat(); err != nil {
+			return nil, err
+		}
+
+		f := e.file
+		if f == nil {
+			// A directory implied by the files it contains
+			continue
+		}
+
+		if _, ok := seen[f]; ok {
+			continue
+		}
+
+		seen[f] = struct{}{}
+
+		if f.isEmptyStream || f.isEmptyFile {
+			plan.Empty = append(plan.Empty, f)
+
+			continue
+		}
+
+		sp, ok := streams[f.folder]
+		if !ok {
+			sp = &StreamPlan{Stream: f.folder}
+			streams[f.folder] = sp
+		}
+
+		sp.Files = append(sp.Files, f)
+		sp.Size += f.UncompressedSize
+		sp.DecodedSize = max(sp.DecodedSize, uint64(f.offset)+f.UncompressedSize) //nolint:gosec
+	}
+
+	plan.Streams = make([]StreamPlan, 0, len(streams))
+
+	for _, sp := range streams {
+		sort.Slice(sp.Files, func(i, j int) bool { return sp.Files[i].offset < sp.Files[j].offset })
+
+		plan.Streams = append(plan.Streams, *sp)
+		plan.Size += sp.Size
+		plan.DecodedSize += sp.DecodedSize
+	}
+
+	sort.Slice(plan.Streams, func(i, j int) bool { return plan.Streams[i].Stream < plan.Streams[j].Stream })
+
+	return plan, nil
+}
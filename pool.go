@@ -0,0 +1,81 @@
+package sevenzip
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/bodgit/sevenzip/internal/pool"
+)
+
+type sharedPoolKey struct {
+	id     string
+	stream int
+}
+
+// A SharedPool holds partially-read streams so they can be reused by several
+// [Reader]s of the same archive, such as when each worker goroutine opens its
+// own [Reader]. Streams are identified by an ID for the archive, supplied
+// with [WithSharedPool], and the stream index. Encrypted streams are never
+// shared, each [Reader] pools those itself so that a partially-read stream
+// can't be read by a [Reader] using a different password.
+//
+// As a stream read by one [Reader] can be handed to another, the archive must
+// remain open until all of the [Reader]s sharing the pool are finished with
+// and the pool is closed.
+type SharedPool struct {
+	mutex sync.Mutex
+	size  int
+	pools map[sharedPoolKey]pool.Pooler
+}
+
+// NewSharedPool returns a [SharedPool] that retains up to size partially-read
+// readers for each stream. If size is less than 1, the number of CPUs is
+// used.
+func NewSharedPool(size int) *SharedPool {
+	if size < 1 {
+		size = runtime.NumCPU()
+	}
+
+	return &SharedPool{
+		size:  size,
+		pools: make(map[sharedPoolKey]pool.Pooler),
+	}
+}
+
+func (sp *SharedPool) pool(id string, stream int) (pool.Pooler, error) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	key := sharedPoolKey{id, stream}
+
+	if p, ok := sp.pools[key]; ok {
+		return p, nil
+	}
+
+	p, err := pool.NewPoolSize(sp.size)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	sp.pools[key] = p
+
+	return p, nil
+}
+
+// Close closes all of the partially-read streams held in the pool, returning
+// any errors from closing them, including those evicted from the pool
+// earlier.
+func (sp *SharedPool) Close() error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	errs := make([]error, 0, len(sp.pools))
+
+	for key, p := range sp.pools {
+		errs = append(errs, p.Close())
+		delete(sp.pools, key)
+	}
+
+	return errors.Join(errs...)
+}
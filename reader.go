@@ -7,63 +7,238 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
 	iofs "io/fs"
+	"log/slog"
+	"math"
+	"math/bits"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/bodgit/plumbing"
+	"github.com/bodgit/sevenzip/internal/aes7z"
+	"github.com/bodgit/sevenzip/internal/cache"
 	"github.com/bodgit/sevenzip/internal/pool"
+	"github.com/bodgit/sevenzip/internal/ratelimit"
 	"github.com/bodgit/sevenzip/internal/util"
+	"github.com/bodgit/sevenzip/internal/zstd"
 	"github.com/spf13/afero"
-	"go4.org/readerutil"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	errFormat          = errors.New("sevenzip: not a valid 7-zip file")
-	errChecksum        = errors.New("sevenzip: checksum error")
-	errTooMuch         = errors.New("sevenzip: too much data")
-	errNegativeSize    = errors.New("sevenzip: size cannot be negative")
-	errOneHeaderStream = errors.New("sevenzip: expected only one folder in header stream")
+	errFormat         = errors.New("sevenzip: not a valid 7-zip file")
+	errChecksum       = errors.New("sevenzip: checksum error")
+	errTooMuch        = errors.New("sevenzip: too much data")
+	errNegativeSize   = errors.New("sevenzip: size cannot be negative")
+	errNoHeaderStream = errors.New("sevenzip: no folder in header stream")
+	errLinkTooLong    = errors.New("sevenzip: symbolic link target too long")
+	errListOnly       = errors.New("sevenzip: archive opened for listing only")
+	errTruncated      = errors.New("sevenzip: archive is truncated")
+	errNegativeOffset = errors.New("sevenzip: negative offset")
+
+	// ErrPasswordRequired is returned when opening an archive with an
+	// encrypted header without supplying a password.
+	ErrPasswordRequired = errors.New("sevenzip: password required")
+
+	// ErrWrongPassword is returned when decrypting fails to produce valid
+	// data, either because the decompressor rejects it or it doesn't match
+	// the stored checksum. It is most likely the wrong password was used
+	// but corruption of the archive can't be ruled out.
+	ErrWrongPassword = errors.New("sevenzip: wrong password")
+
+	// ErrMemoryLimit is returned when decompressing a stream would need
+	// more memory than permitted by [WithMaxMemory].
+	ErrMemoryLimit = util.ErrMemoryLimit
+
+	// ErrArchiveChanged is returned when reading a file from an archive
+	// that has been modified since it was opened, if enabled with
+	// [WithChangeDetection].
+	ErrArchiveChanged = errors.New("sevenzip: archive has changed")
 )
 
 // ReadError is used to wrap read I/O errors.
 type ReadError struct {
 	// Encrypted is a hint that there is encryption involved.
 	Encrypted bool
-	Err       error
+	// Name is the name of the file being read, if any.
+	Name string
+	// Stream is the index of the stream being read, or -1 if the error
+	// occurred reading the archive header.
+	Stream int
+	// Method is the name of the coder method that failed, if known.
+	Method string
+	Err    error
 }
 
 func (e ReadError) Error() string {
-	return fmt.Sprintf("sevenzip: read error: %v", e.Err)
+	var sb strings.Builder
+
+	sb.WriteString("sevenzip: ")
+
+	if e.Method != "" {
+		sb.WriteString(e.Method + " ")
+	}
+
+	sb.WriteString("read error")
+
+	if e.Stream >= 0 {
+		fmt.Fprintf(&sb, " in stream %d", e.Stream)
+	}
+
+	if e.Name != "" {
+		fmt.Fprintf(&sb, " while reading %s", e.Name)
+	}
+
+	fmt.Fprintf(&sb, ": %v", e.Err)
+
+	return sb.String()
 }
 
 func (e ReadError) Unwrap() error {
 	return e.Err
 }
 
+// MissingVolumeError is returned when opening a multi-volume archive if not
+// all of the volumes are present, rather than failing later when reading
+// from the missing volume.
+type MissingVolumeError struct {
+	// Name is the name of the first missing volume.
+	Name string
+}
+
+func (e *MissingVolumeError) Error() string {
+	return "sevenzip: missing volume " + e.Name
+}
+
+func (e *MissingVolumeError) Unwrap() error {
+	return errTruncated
+}
+
+// UnsupportedMethodError is returned when a stream uses a method with no
+// registered decompressor. A decompressor for the method can be added with
+// [RegisterDecompressor] or [WithDecompressors].
+type UnsupportedMethodError struct {
+	// ID is the method ID, [MethodName] returns its name.
+	ID []byte
+}
+
+func (e *UnsupportedMethodError) Error() string {
+	return "sevenzip: unsupported compression method " + MethodName(e.ID)
+}
+
+func newReadError(f *File, stream int, encrypted bool, err error) *ReadError {
+	e := &ReadError{
+		Encrypted: encrypted,
+		Stream:    stream,
+		Err:       err,
+	}
+
+	if f != nil {
+		e.Name = f.Name
+	}
+
+	var me *methodError
+	if errors.As(err, &me) {
+		e.Method = me.method
+	}
+
+	return e
+}
+
+// wrongPassword marks err as likely being caused by the wrong password if
+// encryption is involved.
+func wrongPassword(err error, encrypted bool) error {
+	if !encrypted || errors.Is(err, ErrWrongPassword) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrWrongPassword, err)
+}
+
 // A Reader serves content from a 7-Zip archive.
 type Reader struct {
 	r     io.ReaderAt
 	start int64
 	end   int64
+	major int
+	minor int
 	si    *streamsInfo
 	p     string
+	pb    []byte
 	File  []*File
 	pool  []pool.Pooler
 
+	// shared records which of pool came from sharedPool, those are left
+	// open by Close.
+	shared []bool
+
+	passwordCallback func(*File) (string, error)
+	headerEncrypted  bool
+	headerCompressed bool
+	headerSize       int64
+
+	caseInsensitive bool
+	duplicates      DuplicatePolicy
+	nameMapping     func(string) string
+	namePolicy      NamePolicy
+
+	searchLimit      int64
+	archiveOffset    int64
+	archiveOffsetSet bool
+	maxEntries       uint64
+
+	maxMemory uint64
+	maxDict   uint64
+	zstd      zstd.Options
+	listOnly  bool
+
+	decompressors map[string]Decompressor
+
+	cache      *cache.Cache
+	cacheGroup singleflight.Group
+
+	readBlockSize int
+	readBlocks    int
+	bufferSize    int
+	readAheadSize int
+	readAheads    int
+	detectChanges bool
+	changed       func() error
+	mmap          bool
+	maxVolumes    int
+	rateLimit     int64
+	metrics       Collector
+	logger        *slog.Logger
+
+	poolSize     int
+	maxSeek      int64
+	newPool      pool.Constructor
+	sharedPool   *SharedPool
+	sharedPoolID string
+	noPooling    bool
+	openWait     time.Duration
+	flights      []*flights
+	packed       []sync.Pool
+
+	stats readerStats
+
 	fileListOnce sync.Once
 	fileList     []fileListEntry
+	lookup       map[string]*fileListEntry
+	foldList     map[string]int
 }
 
 // A ReadCloser is a [Reader] that must be closed when no longer needed.
 type ReadCloser struct {
-	f []afero.File
+	v *volumes
 	Reader
 }
 
@@ -81,6 +256,8 @@ type fileReader struct {
 	rc util.SizeReadSeekCloser
 	f  *File
 	n  int64
+	h  hash.Hash32
+	fl *flight
 }
 
 func (fr *fileReader) Stat() (iofs.FileInfo, error) {
@@ -103,16 +280,29 @@ func (fr *fileReader) Read(p []byte) (int, error) {
 	n, err := fr.rc.Read(p)
 	fr.n -= int64(n)
 
-	if err != nil && !errors.Is(err, io.EOF) {
-		e := &ReadError{
-			Err: err,
+	// A file that's finished, or failed, might have been read from an
+	// archive that's been modified since the file was opened
+	if changed := fr.f.zip.changed; changed != nil && (fr.n == 0 || (err != nil && !errors.Is(err, io.EOF))) {
+		if cerr := changed(); cerr != nil {
+			return n, newReadError(fr.f, fr.f.folder, false, cerr)
 		}
+	}
 
+	if err != nil && !errors.Is(err, io.EOF) {
+		var encrypted bool
 		if frc, ok := fr.rc.(*folderReadCloser); ok {
-			e.Encrypted = frc.hasEncryption
+			encrypted = frc.hasEncryption
 		}
 
-		return n, e
+		return n, newReadError(fr.f, fr.f.folder, encrypted, wrongPassword(err, encrypted))
+	}
+
+	if fr.h != nil {
+		_, _ = fr.h.Write(p[:n])
+
+		if fr.n == 0 && fr.h.Sum32() != fr.f.CRC32 {
+			return n, newReadError(fr.f, fr.f.folder, true, ErrWrongPassword)
+		}
 	}
 
 	return n, err //nolint:wrapcheck
@@ -123,6 +313,10 @@ func (fr *fileReader) Close() error {
 		return nil
 	}
 
+	if fr.fl != nil {
+		defer fr.f.zip.flights[fr.f.folder].finish(fr.fl)
+	}
+
 	offset, err := fr.rc.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return fmt.Errorf("sevenzip: error seeking current position: %w", err)
@@ -134,9 +328,7 @@ func (fr *fileReader) Close() error {
 		}
 	} else {
 		f := fr.f
-		if _, err := f.zip.pool[f.folder].Put(offset, fr.rc); err != nil {
-			return fmt.Errorf("sevenzip: error adding to pool: %w", err)
-		}
+		_ = f.zip.pool[f.folder].Put(offset, fr.rc)
 	}
 
 	fr.rc = nil
@@ -144,151 +336,302 @@ func (fr *fileReader) Close() error {
 	return nil
 }
 
+// pooledReader returns a partially-read reader from the pool for the stream
+// containing f, if there is one that doesn't need to seek too far forward.
+func (z *Reader) pooledReader(f *File) util.SizeReadSeekCloser {
+	p := z.pool[f.folder]
+
+	rc, _ := p.Get(f.offset)
+	if rc == nil || z.maxSeek == 0 {
+		return rc
+	}
+
+	current, err := rc.Seek(0, io.SeekCurrent)
+	if err == nil && f.offset-current <= z.maxSeek {
+		return rc
+	}
+
+	// Too far behind, so leave it for a file closer to it and start
+	// decoding afresh
+	_ = p.Put(current, rc)
+
+	return nil
+}
+
+// sectionReader reads a file stored uncompressed and unencrypted directly
+// from the archive.
+type sectionReader struct {
+	*io.SectionReader
+	f *File
+}
+
+func (sr *sectionReader) Stat() (iofs.FileInfo, error) {
+	return headerFileInfo{&sr.f.FileHeader}, nil
+}
+
+func (sr *sectionReader) Close() error {
+	return nil
+}
+
+func (f *File) openSection() *sectionReader {
+	offset := f.zip.start + f.zip.si.folderOffset(f.folder) + f.offset
+
+	return &sectionReader{
+		SectionReader: io.NewSectionReader(f.zip.r, offset, int64(f.UncompressedSize)), //nolint:gosec
+		f:             f,
+	}
+}
+
+// overridden reports whether the decompressor for the method has been changed
+// for this Reader or replaced globally.
+func (z *Reader) overridden(method string) bool {
+	_, ok := z.decompressors[method]
+
+	return ok || isReplaced(method)
+}
+
 // Open returns an [io.ReadCloser] that provides access to the [File]'s
-// contents. Multiple files may be read concurrently.
+// contents. Multiple files may be read concurrently. If the file is stored
+// using only the Copy method, or its stream is held in the cache enabled
+// with [WithCache], the returned reader also implements [io.ReaderAt] and
+// [io.Seeker].
 func (f *File) Open() (io.ReadCloser, error) {
+	if f.zip.listOnly {
+		return nil, errListOnly
+	}
+
+	if f.zip.changed != nil {
+		if err := f.zip.changed(); err != nil {
+			return nil, newReadError(f, f.folder, false, err)
+		}
+	}
+
 	if f.FileHeader.isEmptyStream || f.FileHeader.isEmptyFile {
 		// Return empty reader for directory or empty file
 		return io.NopCloser(bytes.NewReader(nil)), nil
 	}
 
-	rc, _ := f.zip.pool[f.folder].Get(f.offset)
+	if !f.zip.overridden(MethodCopy) && f.zip.si.unpackInfo.folder[f.folder].isCopy() {
+		return f.openSection(), nil
+	}
+
+	if f.zip.cacheable(f) {
+		return f.openCached()
+	}
+
+	rc := f.zip.pooledReader(f)
 	if rc == nil {
+		rc = f.zip.awaitReader(f)
+	}
+
+	if rc != nil {
+		f.zip.stats.poolHits.Add(1)
+
+		if f.zip.metrics != nil {
+			f.zip.metrics.PoolHit()
+		}
+	} else {
+		f.zip.stats.poolMisses.Add(1)
+
+		if f.zip.metrics != nil {
+			f.zip.metrics.PoolMiss()
+		}
+
 		var (
 			encrypted bool
 			err       error
 		)
 
-		rc, _, encrypted, err = f.zip.folderReader(f.zip.si, f.folder)
+		rc, _, encrypted, err = f.zip.folderReader(f.zip.si, f.folder, f)
 		if err != nil {
-			return nil, &ReadError{
-				Encrypted: encrypted,
-				Err:       err,
-			}
+			return nil, newReadError(f, f.folder, encrypted, err)
 		}
 	}
 
-	if _, err := rc.Seek(f.offset, io.SeekStart); err != nil {
-		e := &ReadError{
-			Err: err,
-		}
+	if frc, ok := rc.(*folderReadCloser); ok && frc.seeker == nil && f.offset > frc.offset() {
+		f.zip.stats.skipped.Add(uint64(f.offset - frc.offset())) //nolint:gosec
+	}
 
+	if _, err := rc.Seek(f.offset, io.SeekStart); err != nil {
+		var encrypted bool
 		if fr, ok := rc.(*folderReadCloser); ok {
-			e.Encrypted = fr.hasEncryption
+			encrypted = fr.hasEncryption
 		}
 
-		return nil, e
+		return nil, newReadError(f, f.folder, encrypted, wrongPassword(err, encrypted))
 	}
 
-	return &fileReader{
+	fr := &fileReader{
 		rc: rc,
 		f:  f,
 		n:  int64(f.UncompressedSize), //nolint:gosec
-	}, nil
+	}
+
+	if f.zip.flights != nil && f.zip.flights[f.folder] != nil {
+		fr.fl = f.zip.flights[f.folder].start(f.offset + fr.n)
+	}
+
+	// With encryption, a checksum mismatch most likely means the wrong
+	// password was used
+	if frc, ok := rc.(*folderReadCloser); ok && frc.hasEncryption && f.CRC32 != 0 {
+		fr.h = crc32.NewIEEE()
+	}
+
+	return fr, nil
 }
 
-func openReader(fs afero.Fs, name string) (io.ReaderAt, int64, []afero.File, error) {
+// maxLinkname is the longest symbolic link target that will be read, which
+// matches PATH_MAX on Linux.
+const maxLinkname = 4096
+
+// Readlink returns the destination of the symbolic link described by the
+// [File]. The destination is stored as the contents of the file so it's
+// read along with the headers and kept in [FileHeader.Linkname]; if that
+// failed, the file is decompressed again to return the error. An error
+// wrapping [fs.ErrInvalid] is returned if the [File] is not a symbolic link.
+func (f *File) Readlink() (string, error) {
+	if !f.IsSymlink() {
+		return "", &iofs.PathError{Op: "readlink", Path: f.Name, Err: iofs.ErrInvalid}
+	}
+
+	if f.Linkname != "" {
+		return f.Linkname, nil
+	}
+
+	return f.readlink()
+}
+
+func (f *File) readlink() (string, error) {
+	if f.UncompressedSize > maxLinkname {
+		return "", &iofs.PathError{Op: "readlink", Path: f.Name, Err: errLinkTooLong}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := io.ReadAll(rc)
+	if err = errors.Join(err, rc.Close()); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// openReader opens the archive name, or if it has a ".001" suffix finds its
+// subsequent volumes, which are only opened as they're read.
+func openReader(fs afero.Fs, name string, mmap bool, maxOpen int) (*volumes, error) {
 	f, err := fs.Open(filepath.Clean(name))
 	if err != nil {
-		return nil, 0, nil, fmt.Errorf("sevenzip: error opening: %w", err)
+		return nil, fmt.Errorf("sevenzip: error opening: %w", err)
 	}
 
 	info, err := f.Stat()
 	if err != nil {
 		err = errors.Join(err, f.Close())
 
-		return nil, 0, nil, fmt.Errorf("sevenzip: error retrieving file info: %w", err)
+		return nil, fmt.Errorf("sevenzip: error retrieving file info: %w", err)
 	}
 
-	var reader io.ReaderAt = f
+	if mmap {
+		if f, err = mapFile(f, info.Size()); err != nil {
+			return nil, err
+		}
+	}
 
-	size := info.Size()
-	files := []afero.File{f}
+	vs := newVolumes(fs, mmap, maxOpen)
+	vs.add(f.Name(), info.Size(), f)
 
 	if ext := filepath.Ext(name); ext == ".001" {
-		sr := []readerutil.SizeReaderAt{io.NewSectionReader(f, 0, size)}
-
 		for i := 2; true; i++ {
-			f, err := fs.Open(fmt.Sprintf("%s.%03d", strings.TrimSuffix(name, ext), i))
+			name := volumeName(name, i)
+
+			info, err := fs.Stat(name)
 			if err != nil {
 				if errors.Is(err, iofs.ErrNotExist) {
 					break
 				}
 
-				errs := make([]error, 0, len(files)+1)
-				errs = append(errs, err)
-
-				for _, file := range files {
-					errs = append(errs, file.Close())
-				}
-
-				return nil, 0, nil, fmt.Errorf("sevenzip: error opening: %w", errors.Join(errs...))
-			}
-
-			files = append(files, f)
-
-			info, err = f.Stat()
-			if err != nil {
-				errs := make([]error, 0, len(files)+1)
-				errs = append(errs, err)
-
-				for _, file := range files {
-					errs = append(errs, file.Close())
-				}
+				err = errors.Join(err, vs.Close())
 
-				return nil, 0, nil, fmt.Errorf("sevenzip: error retrieving file info: %w", errors.Join(errs...))
+				return nil, fmt.Errorf("sevenzip: error retrieving file info: %w", err)
 			}
 
-			sr = append(sr, io.NewSectionReader(f, 0, info.Size()))
+			vs.add(name, info.Size(), nil)
 		}
-
-		mr := readerutil.NewMultiReaderAt(sr...)
-		reader, size = mr, mr.Size()
 	}
 
-	return reader, size, files, nil
+	return vs, nil
+}
+
+// volumeName returns the name of volume i, counting from 1, of the
+// multi-volume archive with the first volume name.
+func volumeName(name string, i int) string {
+	return fmt.Sprintf("%s.%03d", strings.TrimSuffix(name, filepath.Ext(name)), i)
 }
 
 // OpenReaderWithPassword will open the 7-zip file specified by name using
 // password as the basis of the decryption key and return a [*ReadCloser]. If
 // name has a ".001" suffix it is assumed there are multiple volumes and each
-// sequential volume will be opened.
-func OpenReaderWithPassword(name, password string) (*ReadCloser, error) {
-	reader, size, files, err := openReader(afero.NewOsFs(), name)
+// sequential volume will be used, opening them as they're read. See
+// [WithMaxOpenVolumes] to limit how many are open at once.
+func OpenReaderWithPassword(name, password string, opts ...ReaderOption) (*ReadCloser, error) {
+	r := new(ReadCloser)
+	r.p = password
+
+	if err := r.applyOptions(opts); err != nil {
+		return nil, err
+	}
+
+	vs, err := openReader(afero.NewOsFs(), name, r.mmap, r.maxVolumes)
 	if err != nil {
 		return nil, err
 	}
 
-	r := new(ReadCloser)
-	r.p = password
+	if err := r.init(vs, vs.Size()); err != nil {
+		// Volumes are found until one is missing so the archive is
+		// truncated if that wasn't the last one
+		if errors.Is(err, errTruncated) && filepath.Ext(name) == ".001" {
+			err = &MissingVolumeError{Name: volumeName(name, len(vs.v)+1)}
+		}
 
-	if err := r.init(reader, size); err != nil {
-		errs := make([]error, 0, len(files)+1)
-		errs = append(errs, err)
+		return nil, fmt.Errorf("sevenzip: error initialising: %w", errors.Join(err, vs.Close()))
+	}
 
-		for _, file := range files {
-			errs = append(errs, file.Close())
+	if r.detectChanges {
+		if err := vs.stamp(); err != nil {
+			return nil, errors.Join(err, vs.Close())
 		}
 
-		return nil, fmt.Errorf("sevenzip: error initialising: %w", errors.Join(errs...))
+		// Checking the volumes catches changes to the streams as well
+		// as the header
+		header := r.changed
+		r.changed = func() error {
+			if err := vs.changed(); err != nil {
+				return err
+			}
+
+			return header()
+		}
 	}
 
-	r.f = files
+	r.v = vs
 
 	return r, nil
 }
 
 // OpenReader will open the 7-zip file specified by name and return a
 // [*ReadCloser]. If name has a ".001" suffix it is assumed there are multiple
-// volumes and each sequential volume will be opened.
-func OpenReader(name string) (*ReadCloser, error) {
-	return OpenReaderWithPassword(name, "")
+// volumes and each sequential volume will be used.
+func OpenReader(name string, opts ...ReaderOption) (*ReadCloser, error) {
+	return OpenReaderWithPassword(name, "", opts...)
 }
 
 // NewReaderWithPassword returns a new [*Reader] reading from r using password
 // as the basis of the decryption key, which is assumed to have the given size
 // in bytes.
-func NewReaderWithPassword(r io.ReaderAt, size int64, password string) (*Reader, error) {
+func NewReaderWithPassword(r io.ReaderAt, size int64, password string, opts ...ReaderOption) (*Reader, error) {
 	if size < 0 {
 		return nil, errNegativeSize
 	}
@@ -296,6 +639,10 @@ func NewReaderWithPassword(r io.ReaderAt, size int64, password string) (*Reader,
 	zr := new(Reader)
 	zr.p = password
 
+	if err := zr.applyOptions(opts); err != nil {
+		return nil, err
+	}
+
 	if err := zr.init(r, size); err != nil {
 		return nil, err
 	}
@@ -305,25 +652,140 @@ func NewReaderWithPassword(r io.ReaderAt, size int64, password string) (*Reader,
 
 // NewReader returns a new [*Reader] reading from r, which is assumed to have
 // the given size in bytes.
-func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
-	return NewReaderWithPassword(r, size, "")
+func NewReader(r io.ReaderAt, size int64, opts ...ReaderOption) (*Reader, error) {
+	return NewReaderWithPassword(r, size, "", opts...)
+}
+
+// NewReaderAt returns a new [*Reader] reading the archive found at offset
+// within r, which is assumed to have the given size in bytes. It is
+// equivalent to calling [NewReader] with [WithArchiveOffset].
+func NewReaderAt(r io.ReaderAt, size, offset int64, opts ...ReaderOption) (*Reader, error) {
+	return NewReader(r, size, append([]ReaderOption{WithArchiveOffset(offset)}, opts...)...)
+}
+
+// password returns a passwordFunc for reading the folder containing file,
+// which is nil when reading the header.
+func (z *Reader) password(file *File) passwordFunc {
+	return func() ([]byte, error) {
+		if z.passwordCallback != nil {
+			p, err := z.passwordCallback(file)
+
+			return []byte(p), err
+		}
+
+		if z.pb != nil {
+			return bytes.Clone(z.pb), nil
+		}
+
+		return []byte(z.p), nil
+	}
+}
+
+// Wipe zeroes the copy of the password set with [WithPasswordBytes] and
+// removes any cached keys derived from it, or from the password passed as a
+// string, zeroing them too. Passwords passed as strings and those returned by
+// the callback set with [WithPasswordCallback] can't be zeroed. Encrypted
+// files can't be read once the password has been wiped, and Wipe must not be
+// called while files are being read.
+func (z *Reader) Wipe() {
+	if z.pb != nil {
+		aes7z.Forget(z.pb)
+		clear(z.pb)
+		z.pb = nil
+	}
+
+	if z.p != "" {
+		p := []byte(z.p)
+		aes7z.Forget(p)
+		clear(p)
+		z.p = ""
+	}
+}
+
+// SetPassword sets password as the basis of the decryption key, replacing any
+// password the archive was opened with, which is wiped as by [Reader.Wipe].
+// An archive with an unencrypted header can be opened without a password
+// so that one only needs to be asked for once an encrypted file is read,
+// [Reader.CheckPassword] can then be used to check it. A callback set with
+// [WithPasswordCallback] takes precedence. SetPassword must not be called
+// while files are being read.
+func (z *Reader) SetPassword(password string) {
+	z.Wipe()
+	z.p = password
+
+	// Discard any partially-read or cached encrypted streams as they were
+	// decoded with the old password
+	for i, p := range z.pool {
+		if !z.si.unpackInfo.folder[i].encrypted() {
+			continue
+		}
+
+		if z.cache != nil {
+			z.cache.Remove(i)
+		}
+
+		for rc, _ := p.Get(math.MaxInt64); rc != nil; rc, _ = p.Get(math.MaxInt64) {
+			_ = rc.Close()
+		}
+	}
 }
 
-func (z *Reader) folderReader(si *streamsInfo, f int) (*folderReadCloser, uint32, bool, error) {
+// folderReader returns a reader for folder f. file is the file that caused
+// the folder to be read, which is nil when reading the header.
+func (z *Reader) folderReader(si *streamsInfo, f int, file *File) (*folderReadCloser, uint32, bool, error) {
+	// Only the streams of the archive are decoded often enough to be
+	// worth pooling their packed readers, not the header
+	var packed []sync.Pool
+	if si == z.si {
+		packed = z.packed
+	}
+
 	// Create a SectionReader covering all of the streams data
-	return si.FolderReader(io.NewSectionReader(z.r, z.start, z.end-z.start), f, z.p)
+	fr, crc, encrypted, err := si.FolderReader(io.NewSectionReader(z.r, z.start, z.end-z.start), f, z.password(file), decodeOptions{
+		memory:        z.maxMemory,
+		dictionary:    z.maxDict,
+		zstd:          z.zstd,
+		decompressors: z.decompressors,
+		metrics:       z.metrics,
+		bufferSize:    z.bufferSize,
+		readAheadSize: z.readAheadSize,
+		readAheads:    z.readAheads,
+		packed:        packed,
+	})
+	if err != nil {
+		return nil, 0, encrypted, err
+	}
+
+	fr.decoded = &z.stats.decoded
+	fr.metrics = z.metrics
+
+	if z.debugEnabled() {
+		z.debug("decoding folder", append(folderAttrs(si, f), slog.Bool("header", si != z.si), slog.Bool("encrypted", encrypted))...)
+	}
+
+	// The header is decoded before z.si is set
+	if z.metrics != nil && si == z.si {
+		z.metrics.StreamDecoded(f)
+	}
+
+	return fr, crc, encrypted, nil
 }
 
 const (
-	chunkSize   = 4096
-	searchLimit = 1 << 20 // 1 MiB
+	signature          = "7z\xbc\xaf\x27\x1c"
+	startHeaderEnd     = 32 // Size of the signature and start headers
+	chunkSize          = 4096
+	defaultSearchLimit = 1 << 20 // 1 MiB
 )
 
-func findSignature(r io.ReaderAt, search []byte) ([]int64, error) {
+// findSignature returns the offsets of search found within the first limit
+// bytes of r. Unless all is set, the search stops if it is found at the very
+// beginning.
+func findSignature(r io.ReaderAt, search []byte, limit int64, all bool) ([]int64, error) {
 	chunk := make([]byte, chunkSize+len(search))
 	offsets := make([]int64, 0, 2)
 
-	for offset := int64(0); offset < searchLimit; offset += chunkSize {
+	for offset := int64(0); offset < limit; offset += chunkSize {
 		n, err := r.ReadAt(chunk, offset)
 
 		for i := 0; ; {
@@ -333,7 +795,7 @@ func findSignature(r io.ReaderAt, search []byte) ([]int64, error) {
 			}
 
 			offsets = append(offsets, offset+int64(i+idx))
-			if offsets[0] == 0 {
+			if offsets[0] == 0 && !all {
 				// If signature is at the beginning, return immediately, it's a regular archive
 				return offsets, nil
 			}
@@ -353,17 +815,131 @@ func findSignature(r io.ReaderAt, search []byte) ([]int64, error) {
 	return offsets, nil
 }
 
+// readStartHeader reads the signature and start headers of an archive at off
+// within r, returning errChecksum if the start header is invalid.
+func readStartHeader(r io.ReaderAt, off, size int64) (*signatureHeader, *startHeader, error) {
+	h := crc32.NewIEEE()
+	sr := io.NewSectionReader(r, off, size-off) // Will only read first 32 bytes
+
+	var sh signatureHeader
+	if err := binary.Read(sr, binary.LittleEndian, &sh); err != nil {
+		return nil, nil, fmt.Errorf("sevenzip: error reading signature header: %w", err)
+	}
+
+	start := new(startHeader)
+	if err := binary.Read(io.TeeReader(sr, h), binary.LittleEndian, start); err != nil {
+		return nil, nil, fmt.Errorf("sevenzip: error reading start header: %w", err)
+	}
+
+	// CRC of the start header should match
+	if !util.CRC32Equal(h.Sum(nil), sh.CRC) {
+		return nil, nil, errChecksum
+	}
+
+	return &sh, start, nil
+}
+
+// startHeaderChanged returns a function that reports ErrArchiveChanged if
+// the signature and start header at off in r are no longer the same. As
+// they hold the location and CRC of the header this catches most ways of
+// rewriting an archive, but not changes to just the streams.
+func startHeaderChanged(r io.ReaderAt, off int64) (func() error, error) {
+	want := make([]byte, startHeaderEnd)
+	if _, err := r.ReadAt(want, off); err != nil {
+		return nil, fmt.Errorf("sevenzip: error reading start header: %w", err)
+	}
+
+	return func() error {
+		got := make([]byte, startHeaderEnd)
+		if _, err := r.ReadAt(got, off); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("sevenzip: error reading start header: %w", err)
+		}
+
+		if !bytes.Equal(got, want) {
+			return ErrArchiveChanged
+		}
+
+		return nil
+	}, nil
+}
+
+// FindArchives searches all of r for embedded 7-zip archives and returns the
+// offset of each one found, in order. The offsets can be passed to
+// [NewReaderAt] or [WithArchiveOffset] to open each archive.
+func FindArchives(r io.ReaderAt, size int64) ([]int64, error) {
+	candidates, err := findSignature(r, []byte(signature), size, true)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]int64, 0, len(candidates))
+
+	for _, off := range candidates {
+		if _, _, err := readStartHeader(r, off, size); err != nil {
+			if errors.Is(err, errChecksum) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		offsets = append(offsets, off)
+	}
+
+	return offsets, nil
+}
+
+// signatureOffsets returns the candidate offsets of the archive within r,
+// either the offset set with [WithArchiveOffset] or those found by searching.
+func (z *Reader) signatureOffsets(r io.ReaderAt, signature []byte) ([]int64, error) {
+	if !z.archiveOffsetSet {
+		limit := z.searchLimit
+		if limit == 0 {
+			limit = defaultSearchLimit
+		}
+
+		return findSignature(r, signature, limit, false)
+	}
+
+	b := make([]byte, len(signature))
+	if _, err := r.ReadAt(b, z.archiveOffset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("sevenzip: error reading signature: %w", err)
+	}
+
+	if !bytes.Equal(b, signature) {
+		return nil, nil
+	}
+
+	return []int64{z.archiveOffset}, nil
+}
+
 //nolint:cyclop,funlen,gocognit,gocyclo,maintidx
 func (z *Reader) init(r io.ReaderAt, size int64) (err error) {
+	// Any change has to be detected without going through a cache
+	raw := r
+
+	if z.rateLimit > 0 {
+		r = ratelimit.NewReaderAt(r, z.rateLimit)
+	}
+
+	if z.metrics != nil {
+		r = &metricsReaderAt{r, z.metrics}
+	}
+
+	if z.readBlockSize > 0 {
+		if r, err = cache.NewReaderAt(r, z.readBlockSize, z.readBlocks); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
 	h := crc32.NewIEEE()
 	tra := plumbing.TeeReaderAt(r, h)
 
-	var (
-		signature = []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}
-		offsets   []int64
-	)
-
-	offsets, err = findSignature(r, signature)
+	offsets, err := z.signatureOffsets(r, []byte(signature))
 	if err != nil {
 		return err
 	}
@@ -373,56 +949,73 @@ func (z *Reader) init(r io.ReaderAt, size int64) (err error) {
 	}
 
 	var (
-		sr    *io.SectionReader
-		off   int64
-		start startHeader
+		sig   *signatureHeader
+		start *startHeader
 	)
 
-	for _, off = range offsets {
-		sr = io.NewSectionReader(tra, off, size-off) // Will only read first 32 bytes
+	for _, off := range offsets {
+		if sig, start, err = readStartHeader(r, off, size); err == nil {
+			// Both are used as signed offsets within r
+			if start.Offset > math.MaxInt64 || start.Size > math.MaxInt64 {
+				return errFormat
+			}
 
-		var sh signatureHeader
-		if err = binary.Read(sr, binary.LittleEndian, &sh); err != nil {
-			return fmt.Errorf("sevenzip: error reading signature header: %w", err)
-		}
+			z.major, z.minor = int(sig.Major), int(sig.Minor)
 
-		z.r = r
+			// Work out where we are in the file, the start header is
+			// immediately followed by the streams
+			z.start = off + startHeaderEnd
 
-		h.Reset()
+			// The header has to lie within r, compared without
+			// adding so crafted values can't overflow
+			if start.Offset > uint64(size-z.start) || start.Size > uint64(size-z.start)-start.Offset { //nolint:gosec
+				return errTruncated
+			}
 
-		if err = binary.Read(sr, binary.LittleEndian, &start); err != nil {
-			return fmt.Errorf("sevenzip: error reading start header: %w", err)
-		}
+			z.end = z.start + int64(start.Offset) //nolint:gosec
+			z.headerSize = int64(start.Size)      //nolint:gosec
 
-		// CRC of the start header should match
-		if util.CRC32Equal(h.Sum(nil), sh.CRC) {
 			break
 		}
 
-		err = errChecksum
+		if !errors.Is(err, errChecksum) {
+			return err
+		}
 	}
 
 	if err != nil {
 		return err
 	}
 
-	// Work out where we are in the file (32, avoiding magic numbers)
-	if z.start, err = sr.Seek(0, io.SeekCurrent); err != nil {
-		return fmt.Errorf("sevenzip: error seeking current position: %w", err)
+	if z.detectChanges {
+		if z.changed, err = startHeaderChanged(raw, z.start-startHeaderEnd); err != nil {
+			return err
+		}
 	}
 
-	// Seek over the streams
-	if z.end, err = sr.Seek(int64(start.Offset), io.SeekCurrent); err != nil { //nolint:gosec
-		return fmt.Errorf("sevenzip: error seeking over streams: %w", err)
-	}
+	z.debug("found start header",
+		slog.Int64("offset", z.start-startHeaderEnd),
+		slog.Int("major", z.major),
+		slog.Int("minor", z.minor),
+		slog.Int64("header_offset", z.end),
+		slog.Uint64("header_size", start.Size),
+	)
+
+	z.r = r
 
-	z.start += off
-	z.end += off
+	// An archive without any entries, as created by 7-Zip, has no header
+	if start.Size == 0 {
+		if start.Offset != 0 {
+			return errFormat
+		}
+
+		return nil
+	}
 
 	h.Reset()
 
 	// Bound bufio.Reader otherwise it can read trailing garbage which screws up the CRC check
-	br := bufio.NewReader(io.NewSectionReader(tra, z.end, int64(start.Size))) //nolint:gosec
+	br := newBufferedReader(io.NewSectionReader(tra, z.end, int64(start.Size)), z.bufferSize, int64(start.Size)) //nolint:gosec
 
 	var (
 		id          byte
@@ -436,11 +1029,11 @@ func (z *Reader) init(r io.ReaderAt, size int64) (err error) {
 
 	switch id {
 	case idHeader:
-		if header, err = readHeader(br); err != nil {
+		if header, err = readHeader(br, z.headerOptions(z.listOnly)); err != nil {
 			return err
 		}
 	case idEncodedHeader:
-		if streamsInfo, err = readStreamsInfo(br); err != nil {
+		if streamsInfo, err = readStreamsInfo(br, z.headerOptions(false)); err != nil {
 			return err
 		}
 	default:
@@ -461,64 +1054,66 @@ func (z *Reader) init(r io.ReaderAt, size int64) (err error) {
 	// If the header was encoded we should have sufficient information now
 	// to decode it
 	if streamsInfo != nil {
-		if streamsInfo.Folders() != 1 {
-			return errOneHeaderStream
-		}
-
-		var (
-			fr        *folderReadCloser
-			crc       uint32
-			encrypted bool
-		)
+		z.debugStreamsInfo("found encoded header", streamsInfo)
 
-		fr, crc, encrypted, err = z.folderReader(streamsInfo, 0)
-		if err != nil {
-			return &ReadError{
-				Encrypted: encrypted,
-				Err:       err,
-			}
+		if header, err = z.decodeHeader(streamsInfo); err != nil {
+			return err
 		}
+	}
 
-		defer func() {
-			err = errors.Join(err, fr.Close())
-		}()
-
-		if header, err = readEncodedHeader(util.ByteReadCloser(fr)); err != nil {
-			return &ReadError{
-				Encrypted: fr.hasEncryption,
-				Err:       err,
-			}
-		}
+	z.si = header.streamsInfo
 
-		if crc != 0 && !util.CRC32Equal(fr.Checksum(), crc) {
-			return errChecksum
-		}
+	if header.filesInfo != nil {
+		z.debug("parsed header", slog.Int("streams", z.si.Folders()), slog.Int("files", len(header.filesInfo.file)))
 	}
 
-	z.si = header.streamsInfo
+	z.debugStreamsInfo("found stream", z.si)
 
-	// spew.Dump(header)
 	filesPerStream := make(map[int]int, z.si.Folders())
 
+	methods := make([]string, z.si.Folders())
+
+	for i := range methods {
+		names := make([]string, 0, len(z.si.unpackInfo.folder[i].coder))
+		for _, c := range z.si.unpackInfo.folder[i].coder {
+			names = append(names, MethodName(c.id))
+		}
+
+		methods[i] = strings.Join(names, methodSeparator)
+	}
+
 	if header.filesInfo != nil {
 		folder, offset := 0, int64(0)
 		z.File = make([]*File, 0, len(header.filesInfo.file))
-		j := 0
 
-		for _, fh := range header.filesInfo.file {
+		for i, fh := range header.filesInfo.file {
 			f := new(File)
 			f.zip = z
 			f.FileHeader = fh
+			f.Index = i
+
+			var raw []byte
+			if i < len(header.filesInfo.rawName) {
+				raw = header.filesInfo.rawName[i]
+			}
+
+			if z.namePolicy == NameError {
+				if _, err := decodeName(raw); err != nil {
+					return fmt.Errorf("%w: %q", err, fh.Name)
+				}
+			}
+
+			if z.namePolicy == NameRaw {
+				f.RawName = string(raw)
+			}
 
 			if f.FileHeader.FileInfo().IsDir() && !strings.HasSuffix(f.FileHeader.Name, "/") {
 				f.FileHeader.Name += "/"
 			}
 
 			if !fh.isEmptyStream && !fh.isEmptyFile {
-				f.folder, _ = header.streamsInfo.FileFolderAndSize(j)
-
-				// Make an exported copy of the folder index
-				f.Stream = f.folder
+				f.folder = f.Stream
+				f.methods = methods[f.folder]
 
 				filesPerStream[f.folder]++
 
@@ -529,51 +1124,415 @@ func (z *Reader) init(r io.ReaderAt, size int64) (err error) {
 				f.offset = offset
 				offset += int64(f.UncompressedSize) //nolint:gosec
 				folder = f.folder
-				j++
+
+				z.debug("found file",
+					slog.String("name", f.Name),
+					slog.Int("stream", f.folder),
+					slog.Int64("offset", f.offset),
+					slog.Uint64("size", f.UncompressedSize),
+				)
 			}
 
 			z.File = append(z.File, f)
 		}
+
+		z.setPackedSizes()
+	}
+
+	if z.listOnly {
+		return nil
+	}
+
+	if err = z.initPools(filesPerStream); err != nil {
+		return err
+	}
+
+	z.initLinknames()
+
+	return nil
+}
+
+// initLinknames populates [FileHeader.Linkname] for every symbolic link so
+// the headers aren't modified once the [Reader] is returned. Any error is
+// ignored here and instead returned by [File.Readlink].
+func (z *Reader) initLinknames() {
+	for _, f := range z.File {
+		if !f.IsSymlink() || f.UncompressedSize > maxLinkname {
+			continue
+		}
+
+		if target, err := f.readlink(); err == nil {
+			f.Linkname = target
+		}
 	}
+}
 
-	// spew.Dump(filesPerStream)
+// initPools creates the pool for each stream, only streams with more than
+// one file in them benefit from pooling.
+func (z *Reader) initPools(filesPerStream map[int]int) (err error) {
+	if z.si != nil && z.si.packInfo != nil {
+		z.packed = make([]sync.Pool, len(z.si.packInfo.size))
+	}
 
 	z.pool = make([]pool.Pooler, z.si.Folders())
+	z.shared = make([]bool, z.si.Folders())
+
 	for i := range z.pool {
 		var newPool pool.Constructor = pool.NewNoopPool
 
-		if filesPerStream[i] > 1 {
-			newPool = pool.NewPool
+		if filesPerStream[i] > 1 && !z.noPooling {
+			newPool = z.poolConstructor()
+
+			// Encrypted streams are never shared as another Reader could
+			// otherwise read them without the right password
+			if z.sharedPool != nil && !z.si.unpackInfo.folder[i].encrypted() {
+				stream := i
+				newPool = func() (pool.Pooler, error) {
+					return z.sharedPool.pool(z.sharedPoolID, stream)
+				}
+				z.shared[i] = true
+			}
 		}
 
 		if z.pool[i], err = newPool(); err != nil {
 			return err
 		}
+
+		if z.openWait > 0 && filesPerStream[i] > 1 && !z.noPooling {
+			if z.flights == nil {
+				z.flights = make([]*flights, z.si.Folders())
+			}
+
+			z.flights[i] = newFlights()
+		}
 	}
 
 	return nil
 }
 
-// Volumes returns the list of volumes that have been opened as part of the
-// current archive.
+// newBufferedReader returns a bufio.Reader reading r, which has n bytes
+// left, using a buffer of size bytes or the bufio default if size is zero.
+// The buffer is never bigger than it needs to be to hold all of r.
+func newBufferedReader(r io.Reader, size int, n int64) *bufio.Reader {
+	if size <= 0 {
+		return bufio.NewReader(r)
+	}
+
+	return bufio.NewReaderSize(r, int(min(int64(size), n)))
+}
+
+// fullReader fills each read as far as possible, which the header parser
+// relies on but io.MultiReader doesn't do at the boundary between readers.
+type fullReader struct {
+	r io.Reader
+}
+
+func (fr fullReader) Read(p []byte) (int, error) {
+	n, err := io.ReadFull(fr.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// headerOptions returns the options for reading the header.
+func (z *Reader) headerOptions(skipDigests bool) headerOptions {
+	return headerOptions{
+		skipDigests: skipDigests,
+		maxEntries:  z.maxEntries,
+	}
+}
+
+// decodeHeader decodes the header described by streamsInfo. A large header
+// can be split across more than one folder, which are read in order.
+func (z *Reader) decodeHeader(streamsInfo *streamsInfo) (h *header, err error) {
+	if streamsInfo.Folders() == 0 {
+		return nil, errNoHeaderStream
+	}
+
+	z.headerEncrypted = streamsInfo.encrypted()
+
+	for _, f := range streamsInfo.unpackInfo.folder {
+		for _, c := range f.coder {
+			if m := string(c.id); m != MethodCopy && m != MethodAES256SHA256 {
+				z.headerCompressed = true
+			}
+		}
+	}
+
+	if z.headerEncrypted && z.p == "" && z.pb == nil && z.passwordCallback == nil {
+		return nil, ErrPasswordRequired
+	}
+
+	var (
+		frs           = make([]*folderReadCloser, 0, streamsInfo.Folders())
+		crcs          = make([]uint32, 0, streamsInfo.Folders())
+		readers       = make([]io.Reader, 0, streamsInfo.Folders())
+		hasEncryption bool
+	)
+
+	defer func() {
+		for _, fr := range frs {
+			err = errors.Join(err, fr.Close())
+		}
+	}()
+
+	for i := 0; i < streamsInfo.Folders(); i++ {
+		fr, crc, encrypted, err := z.folderReader(streamsInfo, i, nil)
+		if err != nil {
+			return nil, newReadError(nil, -1, encrypted, err)
+		}
+
+		frs, crcs, readers = append(frs, fr), append(crcs, crc), append(readers, fr)
+		hasEncryption = hasEncryption || fr.hasEncryption
+	}
+
+	var rc io.ReadCloser = frs[0]
+	if len(frs) > 1 {
+		rc = io.NopCloser(fullReader{io.MultiReader(readers...)})
+	}
+
+	if h, err = readEncodedHeader(util.ByteReadCloser(rc), z.headerOptions(z.listOnly)); err != nil {
+		return nil, newReadError(nil, -1, hasEncryption, wrongPassword(err, hasEncryption))
+	}
+
+	for i, fr := range frs {
+		if crcs[i] != 0 && !util.CRC32Equal(fr.Checksum(), crcs[i]) {
+			if fr.hasEncryption {
+				return nil, newReadError(nil, -1, true, ErrWrongPassword)
+			}
+
+			return nil, errChecksum
+		}
+	}
+
+	return h, nil
+}
+
+func (z *Reader) setPackedSizes() {
+	last := make(map[int]*File, z.si.Folders())
+	total := make(map[int]uint64, z.si.Folders())
+	covered := make(map[int]uint64, z.si.Folders())
+
+	for _, f := range z.File {
+		if f.isEmptyStream || f.isEmptyFile {
+			continue
+		}
+
+		packed := z.si.folderPackedSize(f.folder)
+
+		unpacked := z.si.unpackInfo.folder[f.folder].unpackSize()
+		if unpacked == 0 || f.UncompressedSize > unpacked {
+			continue
+		}
+
+		// As the uncompressed size of the file doesn't exceed the size
+		// of its folder, the quotient always fits in 64 bits
+		hi, lo := bits.Mul64(packed, f.UncompressedSize)
+		f.PackedSize, _ = bits.Div64(hi, lo, unpacked)
+
+		last[f.folder] = f
+		total[f.folder] += f.PackedSize
+
+		// Crafted headers can claim files larger than their folder
+		covered[f.folder] += min(f.UncompressedSize, unpacked-covered[f.folder])
+	}
+
+	// Give any rounding remainder to the last file in each folder so the
+	// sizes of the files add up to their share of the stream, which is
+	// all of it unless some of the folder isn't covered by files
+	for folder, f := range last {
+		hi, lo := bits.Mul64(z.si.folderPackedSize(folder), covered[folder])
+		share, _ := bits.Div64(hi, lo, z.si.unpackInfo.folder[folder].unpackSize())
+
+		if share > total[folder] {
+			f.PackedSize += share - total[folder]
+		}
+	}
+}
+
+// poolConstructor returns the constructor used for the pool of each stream
+// containing more than one file.
+func (z *Reader) poolConstructor() pool.Constructor {
+	switch {
+	case z.newPool != nil:
+		return z.newPool
+	case z.poolSize > 0:
+		return func() (pool.Pooler, error) {
+			return pool.NewPoolSize(z.poolSize)
+		}
+	default:
+		return pool.NewPool
+	}
+}
+
+// StreamPackedSize returns the number of compressed bytes used to store the
+// stream identified by [FileHeader.Stream]. It returns 0 for an unknown
+// stream.
+func (z *Reader) StreamPackedSize(stream int) uint64 {
+	return z.si.folderPackedSize(stream)
+}
+
+// Version returns the version of the 7-zip format recorded in the archive's
+// signature header, such as 0.4.
+func (z *Reader) Version() (major, minor int) {
+	return z.major, z.minor
+}
+
+// ArchiveOffset returns the offset of the archive within the file, which is
+// non-zero for archives embedded in another file such as a self-extracting
+// executable.
+func (z *Reader) ArchiveOffset() int64 {
+	return z.start - startHeaderEnd
+}
+
+// Encrypted reports whether any part of the archive, either the header or
+// the contents of any file, is encrypted and so requires a password.
+func (z *Reader) Encrypted() bool {
+	return z.headerEncrypted || z.si.encrypted()
+}
+
+// passwordCheckLimit is the most CheckPassword will decode to reach the end
+// of a file, beyond which it only decodes this much of the start of a stream.
+const passwordCheckLimit = 1 << 20
+
+// CheckPassword checks the password against the contents of the archive
+// without extracting all of it, returning an error matching
+// [ErrWrongPassword] if it's wrong. An encrypted header is checked when the
+// archive is opened so this checks an encrypted stream, if any. If a file
+// ends close enough to the start of one, it is decoded so its CRC can be
+// checked, otherwise the start of a stream is decoded which only catches a
+// wrong password where the decompressor rejects the garbage it's given.
+func (z *Reader) CheckPassword() error {
+	if z.listOnly {
+		return errListOnly
+	}
+
+	var first, nearest *File
+
+	for _, f := range z.File {
+		if f.isEmptyStream || f.isEmptyFile || !z.si.unpackInfo.folder[f.folder].encrypted() {
+			continue
+		}
+
+		if first == nil && f.offset == 0 {
+			first = f
+		}
+
+		if nearest == nil || f.offset+int64(f.UncompressedSize) < nearest.offset+int64(nearest.UncompressedSize) { //nolint:gosec
+			nearest = f
+		}
+	}
+
+	if nearest == nil {
+		return nil
+	}
+
+	f, n := nearest, int64(nearest.UncompressedSize) //nolint:gosec
+	if f.offset+n > passwordCheckLimit && first != nil {
+		f, n = first, min(int64(first.UncompressedSize), passwordCheckLimit) //nolint:gosec
+	}
+
+	// Bypass any pool or cache so only what's needed is decoded
+	rc, _, encrypted, err := z.folderReader(z.si, f.folder, f)
+	if err != nil {
+		return newReadError(f, f.folder, encrypted, wrongPassword(err, encrypted))
+	}
+
+	defer rc.Close()
+
+	if _, err := rc.Seek(f.offset, io.SeekStart); err != nil {
+		return newReadError(f, f.folder, true, wrongPassword(err, true))
+	}
+
+	fr := &fileReader{
+		rc: rc,
+		f:  f,
+		n:  int64(f.UncompressedSize), //nolint:gosec
+	}
+
+	if f.CRC32 != 0 {
+		fr.h = crc32.NewIEEE()
+	}
+
+	// Unlike io.CopyN, this doesn't drop a checksum error on the last read
+	_, err = io.Copy(io.Discard, io.LimitReader(fr, n))
+
+	return err
+}
+
+// Volumes returns the list of volumes that make up the current archive,
+// whether or not they are currently open.
 func (rc *ReadCloser) Volumes() []string {
-	volumes := make([]string, len(rc.f))
-	for idx, f := range rc.f {
-		volumes[idx] = f.Name()
+	return rc.v.Names()
+}
+
+// VolumeSizes returns the size of each volume in the current archive, in the
+// same order as [ReadCloser.Volumes].
+func (rc *ReadCloser) VolumeSizes() []int64 {
+	return rc.v.Sizes()
+}
+
+// StreamVolumes returns the index of each volume, as returned by
+// [ReadCloser.Volumes], holding the compressed data of the stream identified
+// by stream. Only these volumes need to be present to decompress it, along
+// with the ones holding the header.
+func (rc *ReadCloser) StreamVolumes(stream int) ([]int, error) {
+	if stream < 0 || stream >= rc.si.Folders() {
+		return nil, errNoSuchStream
+	}
+
+	return rc.v.span(rc.start+rc.si.folderOffset(stream), int64(rc.si.folderPackedSize(stream))), nil //nolint:gosec
+}
+
+// FileVolumes returns the index of each volume, as returned by
+// [ReadCloser.Volumes], needed to extract f. As its stream has to be
+// decompressed from the beginning these are the volumes holding the whole
+// stream, see [ReadCloser.StreamVolumes]. It returns nil for files without
+// any data, such as directories and empty files.
+func (rc *ReadCloser) FileVolumes(f *File) []int {
+	if f.isEmptyStream || f.isEmptyFile {
+		return nil
 	}
 
+	volumes, _ := rc.StreamVolumes(f.folder)
+
 	return volumes
 }
 
-// Close closes the 7-zip file or volumes, rendering them unusable for I/O.
-func (rc *ReadCloser) Close() error {
-	errs := make([]error, 0, len(rc.f))
+// Close closes any partially read streams kept for reuse, returning any
+// error from closing them or from streams closed earlier as they were
+// evicted. A Reader returned by [NewReader] should be closed once it's no
+// longer needed as these errors are otherwise lost. The Reader remains
+// usable afterwards and streams shared using [WithSharedPool] are left open.
+func (z *Reader) Close() error {
+	if err := z.closePools(); err != nil {
+		return fmt.Errorf("sevenzip: error closing: %w", err)
+	}
 
-	for _, f := range rc.f {
-		errs = append(errs, f.Close())
+	return nil
+}
+
+func (z *Reader) closePools() error {
+	errs := make([]error, 0, len(z.pool))
+
+	for i, p := range z.pool {
+		if z.shared[i] {
+			continue
+		}
+
+		errs = append(errs, p.Close())
 	}
 
-	err := errors.Join(errs...)
+	return errors.Join(errs...)
+}
+
+// Close closes the 7-zip file or volumes, rendering them unusable for I/O.
+func (rc *ReadCloser) Close() error {
+	rc.Wipe()
+
+	err := errors.Join(rc.closePools(), rc.v.Close())
 	if err != nil {
 		err = fmt.Errorf("sevenzip: error closing: %w", err)
 	}
@@ -595,7 +1554,7 @@ type fileInfoDirEntry interface {
 
 func (e *fileListEntry) stat() (fileInfoDirEntry, error) {
 	if e.isDup {
-		return nil, errors.New(e.name + ": duplicate entries in 7-zip file") //nolint:err113
+		return nil, &iofs.PathError{Op: "stat", Path: e.name, Err: errDuplicate}
 	}
 
 	if !e.isDir {
@@ -639,6 +1598,21 @@ func toValidName(name string) string {
 	return p
 }
 
+// fsName returns the name used by the fs.FS methods for an entry, or "" if
+// the entry should be left out.
+func (z *Reader) fsName(name string) string {
+	if z.nameMapping == nil {
+		return toValidName(name)
+	}
+
+	name = z.nameMapping(name)
+	if name == "." || !iofs.ValidPath(name) {
+		return ""
+	}
+
+	return name
+}
+
 //nolint:cyclop,funlen
 func (z *Reader) initFileList() {
 	z.fileListOnce.Do(func() {
@@ -650,19 +1624,19 @@ func (z *Reader) initFileList() {
 		for _, file := range z.File {
 			isDir := len(file.Name) > 0 && file.Name[len(file.Name)-1] == '/'
 
-			name := toValidName(file.Name)
+			name := z.fsName(file.Name)
 			if name == "" {
 				continue
 			}
 
 			if idx, ok := files[name]; ok {
-				z.fileList[idx].isDup = true
+				z.duplicateEntry(idx, file, isDir, files, knownDirs)
 
 				continue
 			}
 
 			if idx, ok := knownDirs[name]; ok {
-				z.fileList[idx].isDup = true
+				z.duplicateEntry(idx, file, isDir, files, knownDirs)
 
 				continue
 			}
@@ -689,7 +1663,13 @@ func (z *Reader) initFileList() {
 		for dir := range dirs {
 			if _, ok := knownDirs[dir]; !ok {
 				if idx, ok := files[dir]; ok {
-					z.fileList[idx].isDup = true
+					// Unless duplicates are an error, the directory
+					// wins so the entries within it are reachable
+					if z.duplicates == DuplicateError {
+						z.fileList[idx].isDup = true
+					} else {
+						z.fileList[idx].file, z.fileList[idx].isDir = nil, true
+					}
 				} else {
 					entry := fileListEntry{
 						name:  dir,
@@ -702,9 +1682,59 @@ func (z *Reader) initFileList() {
 		}
 
 		sort.Slice(z.fileList, func(i, j int) bool { return fileEntryLess(z.fileList[i].name, z.fileList[j].name) })
+
+		z.lookup = make(map[string]*fileListEntry, len(z.fileList))
+		for i := range z.fileList {
+			z.lookup[z.fileList[i].name] = &z.fileList[i]
+		}
+
+		if z.caseInsensitive {
+			// If more than one entry folds to the same name, the first
+			// in sorted order wins
+			z.foldList = make(map[string]int, len(z.fileList))
+
+			for i := range z.fileList {
+				name := foldName(z.fileList[i].name)
+				if _, ok := z.foldList[name]; !ok {
+					z.foldList[name] = i
+				}
+			}
+		}
 	})
 }
 
+// duplicateEntry handles file being found with the same name as the existing
+// entry at idx, according to the duplicate policy.
+func (z *Reader) duplicateEntry(idx int, file *File, isDir bool, files, knownDirs map[string]int) {
+	e := &z.fileList[idx]
+
+	switch z.duplicates {
+	case DuplicateFirstWins:
+	case DuplicateLastWins:
+		if e.isDir {
+			delete(knownDirs, e.name)
+		} else {
+			delete(files, e.name)
+		}
+
+		e.file, e.isDir = file, isDir
+
+		if isDir {
+			knownDirs[e.name] = idx
+		} else {
+			files[e.name] = idx
+		}
+	case DuplicateError:
+		fallthrough
+	default:
+		e.isDup = true
+	}
+}
+
+func foldName(name string) string {
+	return strings.ToLower(name)
+}
+
 func fileEntryLess(x, y string) bool {
 	xdir, xelem := split(x)
 	ydir, yelem := split(y)
@@ -714,21 +1744,169 @@ func fileEntryLess(x, y string) bool {
 
 // Open opens the named file in the 7-zip archive, using the semantics of
 // [fs.FS.Open]: paths are always slash separated, with no leading / or ../
-// elements.
+// elements. Symbolic links are followed provided their destination is also
+// within the archive.
 func (z *Reader) Open(name string) (iofs.File, error) {
+	e, target, err := z.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.isDir {
+		return &openDir{e, z.openReadDir(target), 0}, nil
+	}
+
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rc.(iofs.File), nil //nolint:forcetypeassert
+}
+
+// maxLinks is the maximum number of symbolic links that will be followed
+// when resolving a name, which matches MAXSYMLINKS on Linux.
+const maxLinks = 40
+
+var (
+	errTooManyLinks = errors.New("too many levels of symbolic links")
+	errDuplicate    = errors.New("duplicate entries in 7-zip file")
+)
+
+// followLinks resolves e, which was found using name, if it is a symbolic
+// link. The link destination must be a relative path that remains within
+// the archive.
+func (z *Reader) followLinks(e *fileListEntry, name string) (*fileListEntry, string, error) {
+	for i := 0; !e.isDir && e.file.IsSymlink(); i++ {
+		if i == maxLinks {
+			return nil, name, errTooManyLinks
+		}
+
+		target, err := e.file.Readlink()
+		if err != nil {
+			return nil, name, err
+		}
+
+		if path.IsAbs(target) {
+			return nil, name, iofs.ErrNotExist
+		}
+
+		name = path.Join(path.Dir(e.name), target)
+		if !iofs.ValidPath(name) {
+			return nil, name, iofs.ErrNotExist
+		}
+
+		if e = z.openLookup(name); e == nil {
+			return nil, name, iofs.ErrNotExist
+		}
+
+		if e.isDup {
+			return nil, name, errDuplicate
+		}
+	}
+
+	return e, name, nil
+}
+
+// resolve finds the entry for name, following any symbolic links, and
+// returns it along with the name of the entry that was ultimately found.
+// Any error is returned as an [*fs.PathError] using op.
+func (z *Reader) resolve(op, name string) (*fileListEntry, string, error) {
+	e, err := z.lookupEntry(op, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Use the name from the archive as it may differ in case
+	target := name
+	if e != dotFile {
+		target = e.name
+	}
+
+	e, target, err = z.followLinks(e, target)
+	if err != nil {
+		return nil, "", &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return e, target, nil
+}
+
+// lookupEntry finds the entry for name without following any symbolic
+// links. Any error, including the name being duplicated, is returned as an
+// [*fs.PathError] using op.
+func (z *Reader) lookupEntry(op, name string) (*fileListEntry, error) {
 	z.initFileList()
 
 	if !iofs.ValidPath(name) {
-		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
 	}
 
 	e := z.openLookup(name)
 	if e == nil {
-		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrNotExist}
+	}
+
+	if e.isDup {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: errDuplicate}
+	}
+
+	return e, nil
+}
+
+// FileByIndex returns the file with [FileHeader.Index] i, or nil if there's
+// no such file.
+func (z *Reader) FileByIndex(i int) *File {
+	if i < 0 || i >= len(z.File) {
+		return nil
+	}
+
+	return z.File[i]
+}
+
+var errNotDirectory = errors.New("not a directory")
+
+// ReadDir reads the named directory and returns a list of directory entries
+// sorted by filename, using the semantics of [fs.ReadDirFS].
+func (z *Reader) ReadDir(name string) ([]iofs.DirEntry, error) {
+	e, target, err := z.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.isDir {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: errNotDirectory}
+	}
+
+	files := z.openReadDir(target)
+	list := make([]iofs.DirEntry, len(files))
+
+	for i := range files {
+		s, err := files[i].stat()
+		if err != nil {
+			return nil, err
+		}
+
+		list[i] = s
+	}
+
+	return list, nil
+}
+
+// ReadFile reads the named file and returns its contents, using the
+// semantics of [fs.ReadFileFS]. As the size of the file is known in advance
+// the contents are read into a single allocation.
+func (z *Reader) ReadFile(name string) ([]byte, error) {
+	e, _, err := z.resolve("open", name)
+	if err != nil {
+		return nil, err
 	}
 
 	if e.isDir {
-		return &openDir{e, z.openReadDir(name), 0}, nil
+		return nil, &iofs.PathError{Op: "read", Path: name, Err: errIsDirectory}
+	}
+
+	if e.file.UncompressedSize > math.MaxInt {
+		return nil, &iofs.PathError{Op: "read", Path: name, Err: errTooMuch}
 	}
 
 	rc, err := e.file.Open()
@@ -736,7 +1914,97 @@ func (z *Reader) Open(name string) (iofs.File, error) {
 		return nil, err
 	}
 
-	return rc.(iofs.File), nil //nolint:forcetypeassert
+	b := make([]byte, e.file.UncompressedSize)
+
+	if _, err = io.ReadFull(rc, b); err != nil {
+		return nil, errors.Join(err, rc.Close())
+	}
+
+	if err = rc.Close(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Stat returns an [fs.FileInfo] describing the named file, using the
+// semantics of [fs.StatFS]. Unlike opening the file and calling Stat on it,
+// this doesn't require any decompression.
+func (z *Reader) Stat(name string) (iofs.FileInfo, error) {
+	e, _, err := z.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.stat()
+}
+
+// Glob returns the names of all files matching pattern, using the semantics
+// of [fs.GlobFS]. The pattern is matched against every entry in the archive
+// directly rather than walking the directory hierarchy.
+func (z *Reader) Glob(pattern string) ([]string, error) {
+	// Check the pattern is well-formed
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	z.initFileList()
+
+	if pattern == "." {
+		return []string{"."}, nil
+	}
+
+	var matches []string
+
+	for _, e := range z.fileList {
+		if ok, _ := path.Match(pattern, e.name); ok {
+			matches = append(matches, e.name)
+		}
+	}
+
+	// Match the ordering of fs.Glob which sorts each path element in turn
+	sort.Slice(matches, func(i, j int) bool {
+		return slices.Compare(strings.Split(matches[i], "/"), strings.Split(matches[j], "/")) < 0
+	})
+
+	return matches, nil
+}
+
+// ReadLink returns the destination of the named symbolic link, using the
+// semantics of [fs.ReadLinkFS].
+func (z *Reader) ReadLink(name string) (string, error) {
+	e, err := z.lookupEntry("readlink", name)
+	if err != nil {
+		return "", err
+	}
+
+	if e.isDir {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: iofs.ErrInvalid}
+	}
+
+	target, err := e.file.Readlink()
+	if err != nil {
+		var pe *iofs.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+
+	return target, nil
+}
+
+// Lstat returns an [fs.FileInfo] describing the named file, using the
+// semantics of [fs.ReadLinkFS]. If the file is a symbolic link, the returned
+// [fs.FileInfo] describes the link rather than its destination.
+func (z *Reader) Lstat(name string) (iofs.FileInfo, error) {
+	e, err := z.lookupEntry("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.stat()
 }
 
 func split(name string) (dir, elem string) {
@@ -764,19 +2032,13 @@ func (z *Reader) openLookup(name string) *fileListEntry {
 		return dotFile
 	}
 
-	dir, elem := split(name)
-
-	files := z.fileList
-	i := sort.Search(len(files), func(i int) bool {
-		idir, ielem := split(files[i].name)
-
-		return idir > dir || idir == dir && ielem >= elem
-	})
+	if e, ok := z.lookup[name]; ok {
+		return e
+	}
 
-	if i < len(files) {
-		fname := files[i].name
-		if fname == name || len(fname) == len(name)+1 && fname[len(name)] == '/' && fname[:len(name)] == name {
-			return &files[i]
+	if z.foldList != nil {
+		if i, ok := z.foldList[foldName(name)]; ok {
+			return &z.fileList[i]
 		}
 	}
 
@@ -2,12 +2,19 @@
 package sevenzip
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	iofs "io/fs"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/bodgit/sevenzip/internal/pool"
+	"github.com/bodgit/sevenzip/internal/util"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -301,16 +308,13 @@ func TestOpenReader(t *testing.T) {
 
 				one := newMockFile(tb)
 				one.On("Stat").Return(info, nil).Once()
+				one.On("Name").Return("filename.7z.001").Once()
 				one.On("Close").Return(nil).Once()
 
-				two := newMockFile(tb)
-				two.On("Stat").Return(info, nil).Once()
-				two.On("Close").Return(nil).Once()
-
 				fs := newMockFs(tb)
 				fs.On("Open", "filename.7z.001").Return(one, nil).Once()
-				fs.On("Open", "filename.7z.002").Return(two, nil).Once()
-				fs.On("Open", "filename.7z.003").Return(nil, iofs.ErrNotExist).Once()
+				fs.On("Stat", "filename.7z.002").Return(info, nil).Once()
+				fs.On("Stat", "filename.7z.003").Return(nil, iofs.ErrNotExist).Once()
 
 				return fs
 			},
@@ -344,7 +348,7 @@ func TestOpenReader(t *testing.T) {
 			err: iofs.ErrPermission,
 		},
 		{
-			name: "multi open error",
+			name: "multi stat error",
 			fs: func(tb testing.TB) afero.Fs {
 				tb.Helper()
 
@@ -353,39 +357,552 @@ func TestOpenReader(t *testing.T) {
 
 				one := newMockFile(tb)
 				one.On("Stat").Return(info, nil).Once()
+				one.On("Name").Return("filename.7z.001").Once()
 				one.On("Close").Return(nil).Once()
 
 				fs := newMockFs(tb)
 				fs.On("Open", "filename.7z.001").Return(one, nil).Once()
-				fs.On("Open", "filename.7z.002").Return(nil, iofs.ErrPermission).Once()
+				fs.On("Stat", "filename.7z.002").Return(nil, iofs.ErrPermission).Once()
 
 				return fs
 			},
 			err: iofs.ErrPermission,
 		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			vs, err := openReader(table.fs(t), "filename.7z.001", false, 0)
+			if table.err == nil {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			assert.Equal(t, int64(200), vs.Size())
+			assert.Equal(t, []string{"filename.7z.001", "filename.7z.002"}, vs.Names())
+			require.NoError(t, vs.Close())
+		})
+	}
+}
+
+// countingFs tracks how many files opened from it are open.
+type countingFs struct {
+	afero.Fs
+	mutex      sync.Mutex
+	open, peak int
+}
+
+type countingFile struct {
+	afero.File
+	fs *countingFs
+}
+
+func (fs *countingFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.open++
+	fs.peak = max(fs.peak, fs.open)
+
+	return &countingFile{f, fs}, nil
+}
+
+func (f *countingFile) Close() error {
+	f.fs.mutex.Lock()
+	f.fs.open--
+	f.fs.mutex.Unlock()
+
+	return f.File.Close()
+}
+
+func TestVolumes(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		maxOpen int
+		peak    int
+	}{
+		{
+			name: "unlimited",
+			peak: 3,
+		},
 		{
-			name: "multi stat error",
-			fs: func(tb testing.TB) afero.Fs {
-				tb.Helper()
+			name:    "limited",
+			maxOpen: 1,
+			peak:    1,
+		},
+	}
 
-				info := newMockFileInfo(tb)
-				info.On("Size").Return(int64(100)).Once()
+	for _, table := range tables {
+		table := table
 
-				one := newMockFile(tb)
-				one.On("Stat").Return(info, nil).Once()
-				one.On("Close").Return(nil).Once()
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
 
-				two := newMockFile(tb)
-				two.On("Stat").Return(nil, iofs.ErrPermission).Once()
-				two.On("Close").Return(nil).Once()
+			fs := &countingFs{Fs: afero.NewMemMapFs()}
 
-				fs := newMockFs(tb)
-				fs.On("Open", "filename.7z.001").Return(one, nil).Once()
-				fs.On("Open", "filename.7z.002").Return(two, nil).Once()
+			var b []byte
 
-				return fs
+			for i := 1; i <= 3; i++ {
+				v := bytes.Repeat([]byte{byte(i)}, 10*i)
+				require.NoError(t, afero.WriteFile(fs, volumeName("test.7z.001", i), v, 0o644))
+
+				b = append(b, v...)
+			}
+
+			vs, err := openReader(fs, "test.7z.001", false, table.maxOpen)
+			require.NoError(t, err)
+
+			// Only the first volume is opened up front
+			assert.Equal(t, 1, fs.open)
+			assert.Equal(t, int64(len(b)), vs.Size())
+
+			// Reads spanning volumes, in both directions
+			for _, off := range []int64{0, 5, 25, 55, 9, 0} {
+				p := make([]byte, 20)
+				n, err := vs.ReadAt(p, off)
+
+				end := min(off+int64(len(p)), int64(len(b)))
+				if end-off < int64(len(p)) {
+					assert.ErrorIs(t, err, io.EOF)
+				} else {
+					assert.NoError(t, err)
+				}
+
+				assert.Equal(t, b[off:end], p[:n])
+			}
+
+			_, err = vs.ReadAt(make([]byte, 1), -1)
+			assert.ErrorIs(t, err, errNegativeOffset)
+
+			assert.Equal(t, table.peak, fs.peak)
+
+			require.NoError(t, vs.Close())
+			assert.Equal(t, 0, fs.open)
+
+			_, err = vs.ReadAt(make([]byte, 1), 0)
+			assert.ErrorIs(t, err, iofs.ErrClosed)
+		})
+	}
+}
+
+func TestVolumeSpan(t *testing.T) {
+	t.Parallel()
+
+	vs := newVolumes(afero.NewMemMapFs(), false, 0)
+	vs.add("test.7z.001", 100, nil)
+	vs.add("test.7z.002", 100, nil)
+	vs.add("test.7z.003", 50, nil)
+
+	tables := []struct {
+		name    string
+		off, n  int64
+		indexes []int
+	}{
+		{
+			name: "empty",
+			off:  10,
+		},
+		{
+			name:    "first",
+			off:     0,
+			n:       100,
+			indexes: []int{0},
+		},
+		{
+			name:    "boundary",
+			off:     99,
+			n:       2,
+			indexes: []int{0, 1},
+		},
+		{
+			name:    "all",
+			off:     50,
+			n:       200,
+			indexes: []int{0, 1, 2},
+		},
+		{
+			name:    "last",
+			off:     200,
+			n:       10,
+			indexes: []int{2},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, table.indexes, vs.span(table.off, table.n))
+		})
+	}
+}
+
+func TestSharedPoolReuse(t *testing.T) {
+	t.Parallel()
+
+	sp := NewSharedPool(1)
+	name := filepath.Join("testdata", "lzma1900.7z")
+
+	r1, err := OpenReader(name, WithSharedPool(sp, name))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r1.Close())
+	}()
+
+	r2, err := OpenReader(name, WithSharedPool(sp, name))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r2.Close())
+	}()
+
+	r3, err := OpenReader(name, WithSharedPool(sp, "other"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r3.Close())
+	}()
+
+	// Reading part of the first file with one reader leaves the stream in
+	// the pool for the other to continue from
+	f := r1.File[0]
+
+	rc, err := f.Open()
+	require.NoError(t, err)
+
+	_, err = io.CopyN(io.Discard, rc, int64(f.UncompressedSize/2)) //nolint:gosec
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	assert.Same(t, r1.pool[f.folder], r2.pool[f.folder])
+	assert.NotSame(t, r1.pool[f.folder], r3.pool[f.folder])
+
+	pooled, ok := r2.pool[f.folder].Get(f.offset + int64(f.UncompressedSize)) //nolint:gosec
+	require.True(t, ok)
+	require.NoError(t, pooled.Close())
+	require.NoError(t, sp.Close())
+}
+
+var errClose = errors.New("close error")
+
+type errorCloser struct {
+	util.SizeReadSeekCloser
+}
+
+func (errorCloser) Close() error {
+	return errClose
+}
+
+func TestPoolClose(t *testing.T) {
+	t.Parallel()
+
+	r, err := OpenReader(filepath.Join("testdata", "lzma.7z"))
+	require.NoError(t, err)
+
+	f := r.File[0]
+
+	rc, err := f.Open()
+	require.NoError(t, err)
+
+	_, err = io.CopyN(io.Discard, rc, int64(f.UncompressedSize/2)) //nolint:gosec
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	// Evicting the pooled reader with a failing Close doesn't cause an
+	// error until the pool is closed
+	p, err := pool.NewPoolSize(1)
+	require.NoError(t, err)
+
+	assert.False(t, p.Put(0, errorCloser{}))
+	assert.True(t, p.Put(1, errorCloser{}))
+	assert.ErrorIs(t, p.Close(), errClose)
+	assert.NoError(t, p.Close())
+
+	// Closing the archive closes the pooled reader
+	require.NoError(t, r.Close())
+
+	_, ok := r.pool[f.folder].Get(f.offset + int64(f.UncompressedSize)) //nolint:gosec
+	assert.False(t, ok)
+}
+
+type closeCountingPool struct {
+	pool.Pooler
+	closed *atomic.Int64
+}
+
+func (p *closeCountingPool) Close() error {
+	p.closed.Add(1)
+
+	return p.Pooler.Close()
+}
+
+func TestSharedPoolClosePrivate(t *testing.T) {
+	t.Parallel()
+
+	var closed atomic.Int64
+
+	sp := NewSharedPool(1)
+	name := filepath.Join("testdata", "7zcracker.7z")
+
+	// Encrypted streams get their own pool despite the shared pool
+	r, err := OpenReaderWithPassword(name, "876", WithSharedPool(sp, name), WithPoolConstructor(func() (pool.Pooler, error) {
+		p, err := pool.NewPool()
+
+		return &closeCountingPool{p, &closed}, err
+	}))
+	require.NoError(t, err)
+
+	private := 0
+
+	for i := range r.pool {
+		if !r.shared[i] {
+			private++
+		}
+	}
+
+	require.Positive(t, private)
+	require.NoError(t, r.Close())
+
+	// Every pool that isn't shared is closed, whether it came from the
+	// constructor or not, and the shared pool is left alone
+	assert.Positive(t, closed.Load())
+	assert.LessOrEqual(t, closed.Load(), int64(private))
+
+	for i, p := range r.pool {
+		if _, ok := p.(*closeCountingPool); ok {
+			assert.False(t, r.shared[i])
+		}
+	}
+
+	require.NoError(t, sp.Close())
+}
+
+type resettableReader struct {
+	io.ReadCloser
+	resets int
+}
+
+func (r *resettableReader) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
+	r.ReadCloser = readers[0]
+	r.resets++
+
+	return nil
+}
+
+func TestResetReader(t *testing.T) {
+	t.Parallel()
+
+	// Use a method ID that no archive uses so other tests aren't affected
+	method := []byte{0x7f, 0xff, 0xff, 0xfe}
+
+	pool := resetPool(string(method))
+
+	rc, err := resetReader(pool, nil, 0, nil)
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+
+	r := &resettableReader{ReadCloser: io.NopCloser(bytes.NewReader(nil))}
+	rc = &resetReadCloser{r, string(method), pool}
+
+	require.NoError(t, rc.Close())
+	assert.ErrorIs(t, rc.Close(), errReaderClosed)
+
+	// The closed reader is reset and reused, unless the pool was cleared
+	// by the garbage collector in the meantime
+	reused, err := resetReader(pool, nil, 0, []io.ReadCloser{io.NopCloser(bytes.NewReader(nil))})
+	require.NoError(t, err)
+
+	if reused != nil {
+		assert.Same(t, r, reused)
+		assert.Equal(t, 1, r.resets)
+	}
+}
+
+func TestResetReaderReplaced(t *testing.T) {
+	t.Parallel()
+
+	// Use a method ID that no archive or other test uses as replacing it
+	// can't be undone
+	method := []byte{0x7f, 0xff, 0xff, 0xfc}
+	pool := resetPool(string(method))
+
+	r := &resettableReader{ReadCloser: io.NopCloser(bytes.NewReader(nil))}
+	rc := &resetReadCloser{r, string(method), pool}
+
+	// A reader from the old implementation that's still open when the
+	// method is replaced isn't pooled once it's closed
+	ReplaceDecompressor(method, nil)
+	require.NoError(t, rc.Close())
+
+	readers := []io.ReadCloser{io.NopCloser(bytes.NewReader(nil))}
+
+	for _, p := range []*sync.Pool{resetPool(string(method)), pool} {
+		reused, err := resetReader(p, nil, 0, readers)
+		require.NoError(t, err)
+		assert.Nil(t, reused)
+	}
+
+	assert.Zero(t, r.resets)
+}
+
+var errTestReset = errors.New("reset failed")
+
+// countingResetReader counts every reset across all readers sharing resets.
+type countingResetReader struct {
+	io.ReadCloser
+	resets *int
+}
+
+func (r *countingResetReader) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
+	r.ReadCloser = readers[0]
+	*r.resets++
+
+	return nil
+}
+
+type failingResetReader struct {
+	resettableReader
+}
+
+func (r *failingResetReader) Reset(_ []byte, _ uint64, readers []io.ReadCloser) error {
+	// Consume some of the stream before failing, like a decoder reading
+	// its header
+	_, _ = readers[0].Read(make([]byte, 1))
+	r.resets++
+
+	return errTestReset
+}
+
+func TestResetDecompressorReuse(t *testing.T) {
+	t.Parallel()
+
+	const rounds = 10
+
+	// Use method IDs that no archive or other test uses, replacing rather
+	// than registering them so the test can be run more than once
+	var (
+		resetID, failID              = []byte{0x7f, 0xff, 0xff, 0xfb}, []byte{0x7f, 0xff, 0xff, 0xfa}
+		created, resets, failCreated int
+		failing                      = new(failingResetReader)
+	)
+
+	ReplaceDecompressor(resetID, func(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		created++
+
+		return &countingResetReader{ReadCloser: readers[0], resets: &resets}, nil
+	})
+
+	ReplaceDecompressor(failID, func(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		failCreated++
+
+		return &resettableReader{ReadCloser: readers[0]}, nil
+	})
+
+	open := func(id []byte) (io.ReadCloser, error) {
+		f := &folder{coder: []*coder{{id: id, in: 1, out: 1}}, size: []uint64{3}}
+		rc, _, err := f.coderReader([]io.ReadCloser{io.NopCloser(bytes.NewReader([]byte("abc")))}, 0, nil, decodeOptions{})
+
+		return rc, err
+	}
+
+	// Every closed reader is pooled and reset for the next stream so only
+	// the first needs creating, unless the pool is emptied by the garbage
+	// collector, the goroutine moves between processors in between, or
+	// the race detector randomly drops it
+	for i := 0; i < rounds; i++ {
+		rc, err := open(resetID)
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("abc"), b)
+		require.NoError(t, rc.Close())
+	}
+
+	assert.Equal(t, rounds, created+resets)
+	assert.Positive(t, resets)
+
+	// A failed reset is returned rather than falling back to the
+	// decompressor with readers that have already been read from
+	var err error
+
+	for i := 0; i < rounds && failing.resets == 0; i++ {
+		resetPool(string(failID)).Put(failing)
+
+		before := failCreated
+		if _, err = open(failID); failing.resets > 0 {
+			assert.Equal(t, before, failCreated)
+		}
+	}
+
+	require.Positive(t, failing.resets)
+	assert.ErrorIs(t, err, errTestReset)
+}
+
+func TestSetPackedSizes(t *testing.T) {
+	t.Parallel()
+
+	si := &streamsInfo{
+		packInfo: &packInfo{
+			size: []uint64{10, 10},
+		},
+		unpackInfo: &unpackInfo{
+			folder: []*folder{
+				{packedStreams: 1, size: []uint64{100}},
+				{packedStreams: 1, size: []uint64{100}},
 			},
-			err: iofs.ErrPermission,
+		},
+	}
+
+	si.computeOffsets()
+
+	type file struct {
+		folder int
+		size   uint64
+		empty  bool
+	}
+
+	tables := []struct {
+		name   string
+		files  []file
+		packed []uint64
+	}{
+		{
+			name:   "rounding",
+			files:  []file{{0, 33, false}, {0, 33, false}, {0, 34, false}},
+			packed: []uint64{3, 3, 4},
+		},
+		{
+			name:   "skipped",
+			files:  []file{{0, 50, false}, {0, 0, true}, {0, 150, false}, {0, 50, false}},
+			packed: []uint64{5, 0, 0, 5},
+		},
+		{
+			name:   "partially covered",
+			files:  []file{{0, 35, false}, {0, 35, false}},
+			packed: []uint64{3, 4},
+		},
+		{
+			name:   "more than the folder",
+			files:  []file{{0, 60, false}, {1, 100, false}, {0, 60, false}},
+			packed: []uint64{6, 10, 6},
 		},
 	}
 
@@ -395,22 +912,23 @@ func TestOpenReader(t *testing.T) {
 		t.Run(table.name, func(t *testing.T) {
 			t.Parallel()
 
-			_, _, files, err := openReader(table.fs(t), "filename.7z.001")
-			if table.err == nil {
-				require.NoError(t, err)
-			} else {
-				assert.ErrorIs(t, err, table.err)
+			z := &Reader{si: si}
 
-				return
+			for _, f := range table.files {
+				z.File = append(z.File, &File{
+					FileHeader: FileHeader{UncompressedSize: f.size, isEmptyStream: f.empty},
+					folder:     f.folder,
+				})
 			}
 
-			defer func() {
-				for _, f := range files {
-					if err := f.Close(); err != nil {
-						t.Fatal(err)
-					}
-				}
-			}()
+			z.setPackedSizes()
+
+			packed := make([]uint64, 0, len(z.File))
+			for _, f := range z.File {
+				packed = append(packed, f.PackedSize)
+			}
+
+			assert.Equal(t, table.packed, packed)
 		})
 	}
 }
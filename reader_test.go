@@ -1,21 +1,36 @@
 package sevenzip_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
 	"testing/iotest"
+	"time"
 
 	"github.com/bodgit/sevenzip"
 	"github.com/bodgit/sevenzip/internal/util"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
@@ -152,6 +167,10 @@ func TestOpenReader(t *testing.T) {
 			name: "complex",
 			file: "lzma1900.7z",
 		},
+		{
+			name: "ppmd",
+			file: "ppmd.7z",
+		},
 		{
 			name: "lz4",
 			file: "lz4.7z",
@@ -164,6 +183,10 @@ func TestOpenReader(t *testing.T) {
 			name: "zstd",
 			file: "zstd.7z",
 		},
+		{
+			name: "xz",
+			file: "xz.7z",
+		},
 		{
 			name: "sfx",
 			file: "sfx.exe",
@@ -180,6 +203,18 @@ func TestOpenReader(t *testing.T) {
 			name: "arm",
 			file: "arm.7z",
 		},
+		{
+			name: "armt",
+			file: "armt.7z",
+		},
+		{
+			name: "ia64",
+			file: "ia64.7z",
+		},
+		{
+			name: "riscv",
+			file: "riscv.7z",
+		},
 		{
 			name: "sparc",
 			file: "sparc.7z",
@@ -239,6 +274,163 @@ func TestOpenReader(t *testing.T) {
 	}
 }
 
+// splitHeader rewrites an archive with an uncompressed header so that the
+// header is encoded as two Copy folders.
+func splitHeader(tb testing.TB, b []byte) []byte {
+	tb.Helper()
+
+	const startHeaderEnd = 32
+
+	offset := binary.LittleEndian.Uint64(b[12:])
+	size := binary.LittleEndian.Uint64(b[20:])
+	require.Less(tb, size, uint64(0x100))
+	require.Less(tb, offset, uint64(0x80))
+
+	streams := b[startHeaderEnd : startHeaderEnd+offset]
+	header := b[startHeaderEnd+offset : startHeaderEnd+offset+size]
+	first, second := header[:size/2], header[size/2:]
+
+	encoded := []byte{
+		// kEncodedHeader, kPackInfo with two streams
+		0x17, 0x06, byte(offset), 0x02, 0x09, byte(len(first)), byte(len(second)), 0x00,
+		// kUnpackInfo with two folders each with a single Copy coder
+		0x07, 0x0b, 0x02, 0x00, 0x01, 0x01, 0x00, 0x01, 0x01, 0x00,
+		// kCodersUnpackSize, kCRC
+		0x0c, byte(len(first)), byte(len(second)), 0x0a, 0x01,
+	}
+	encoded = binary.LittleEndian.AppendUint32(encoded, crc32.ChecksumIEEE(first))
+	encoded = binary.LittleEndian.AppendUint32(encoded, crc32.ChecksumIEEE(second))
+	encoded = append(encoded, 0x00, 0x00)
+
+	start := binary.LittleEndian.AppendUint64(nil, offset+size)
+	start = binary.LittleEndian.AppendUint64(start, uint64(len(encoded)))
+	start = binary.LittleEndian.AppendUint32(start, crc32.ChecksumIEEE(encoded))
+
+	out := append([]byte{}, b[:8]...)
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(start))
+	out = append(out, start...)
+	out = append(out, streams...)
+	out = append(out, header...)
+
+	return append(out, encoded...)
+}
+
+func TestMultiFolderHeader(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "t0.7z"))
+	require.NoError(t, err)
+
+	b = splitHeader(t, b)
+
+	r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	require.Len(t, r.File, 2)
+
+	require.NoError(t, extractArchive(t, r, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+}
+
+func TestZeroEntries(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file string
+	}{
+		{
+			name: "no header",
+			file: "zero_entries.7z",
+		},
+		{
+			name: "empty header",
+			file: "zero_entries_header.7z",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			assert.Empty(t, r.File)
+			assert.Empty(t, r.Streams())
+			assert.Empty(t, r.Listing().Entries)
+			assert.Zero(t, r.ArchiveStats().Entries)
+			require.NoError(t, r.DumpHeader(io.Discard))
+			require.NoError(t, r.ExtractConcurrent(context.Background(), 1, func(_ *sevenzip.File, _ io.Reader) error {
+				return nil
+			}))
+
+			entries, err := fs.ReadDir(r, ".")
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+
+			if err := fstest.TestFS(r); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMissingVolume(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		volumes []int
+		missing string
+	}{
+		{
+			name:    "middle",
+			volumes: []int{1, 2, 4, 5, 6},
+			missing: "multi.7z.003",
+		},
+		{
+			name:    "last",
+			volumes: []int{1, 2, 3, 4, 5},
+			missing: "multi.7z.006",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			for _, i := range table.volumes {
+				name := fmt.Sprintf("multi.7z.%03d", i)
+
+				b, err := os.ReadFile(filepath.Join("testdata", name))
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), b, 0o600))
+			}
+
+			_, err := sevenzip.OpenReader(filepath.Join(dir, "multi.7z.001"))
+
+			var mve *sevenzip.MissingVolumeError
+			require.ErrorAs(t, err, &mve)
+			assert.Equal(t, filepath.Join(dir, table.missing), mve.Name)
+			assert.ErrorIs(t, err, sevenzip.ErrTruncated)
+		})
+	}
+
+	b, err := os.ReadFile(filepath.Join("testdata", "t0.7z"))
+	require.NoError(t, err)
+
+	_, err = sevenzip.NewReader(bytes.NewReader(b[:len(b)-1]), int64(len(b)-1))
+	assert.ErrorIs(t, err, sevenzip.ErrTruncated)
+}
+
 func TestOpenReaderWithPassword(t *testing.T) {
 	t.Parallel()
 
@@ -307,7 +499,11 @@ func TestOpenReaderWithWrongPassword(t *testing.T) {
 		var e *sevenzip.ReadError
 		if assert.ErrorAs(t, err, &e) {
 			assert.True(t, e.Encrypted)
+			assert.Equal(t, -1, e.Stream)
+			assert.Empty(t, e.Name)
 		}
+
+		assert.ErrorIs(t, err, sevenzip.ErrWrongPassword)
 	})
 
 	t.Run("unencrypted headers compressed files", func(t *testing.T) {
@@ -325,7 +521,13 @@ func TestOpenReaderWithWrongPassword(t *testing.T) {
 		var e *sevenzip.ReadError
 		if assert.ErrorAs(t, err, &e) {
 			assert.True(t, e.Encrypted)
+			assert.NotEmpty(t, e.Name)
+			assert.GreaterOrEqual(t, e.Stream, 0)
+			assert.NotEmpty(t, e.Method)
+			assert.Contains(t, e.Error(), e.Name)
 		}
+
+		assert.ErrorIs(t, err, sevenzip.ErrWrongPassword)
 	})
 
 	t.Run("unencrypted headers uncompressed files", func(t *testing.T) {
@@ -339,7 +541,147 @@ func TestOpenReaderWithWrongPassword(t *testing.T) {
 		}()
 
 		err = extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true)
-		assert.ErrorIs(t, err, errCRCMismatch)
+		assert.ErrorIs(t, err, sevenzip.ErrWrongPassword)
+	})
+}
+
+// startHeader returns just the signature and start headers of an archive
+// with the header at offset and of the given size.
+func startHeader(offset, size uint64) []byte {
+	b := make([]byte, 32)
+	copy(b, "7z\xbc\xaf\x27\x1c\x00\x04")
+	binary.LittleEndian.PutUint64(b[12:], offset)
+	binary.LittleEndian.PutUint64(b[20:], size)
+	binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[12:]))
+
+	return b
+}
+
+func TestStartHeader(t *testing.T) {
+	t.Parallel()
+
+	overflow, err := hex.DecodeString("377abcaf271c00045ad65b5800000000000000b800000000710000ff00000000")
+	require.NoError(t, err)
+
+	tables := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{
+			name: "overflow",
+			b:    overflow,
+			err:  sevenzip.ErrFormat,
+		},
+		{
+			name: "offset past end",
+			b:    startHeader(1, 0),
+			err:  sevenzip.ErrTruncated,
+		},
+		{
+			name: "size past end",
+			b:    startHeader(0, 1),
+			err:  sevenzip.ErrTruncated,
+		},
+		{
+			name: "offset and size past end",
+			b:    startHeader(math.MaxInt64, math.MaxInt64),
+			err:  sevenzip.ErrTruncated,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := sevenzip.NewReader(bytes.NewReader(table.b), int64(len(table.b)))
+			assert.ErrorIs(t, err, table.err)
+		})
+	}
+}
+
+// fixChecksums rewrites both CRCs in the start header of b so that it
+// reaches the header parsing, it assumes len(b) is at least 32
+func fixChecksums(b []byte) {
+	offset := binary.LittleEndian.Uint64(b[12:])
+	size := binary.LittleEndian.Uint64(b[20:])
+
+	if end := uint64(len(b) - 32); offset <= end && size <= end-offset {
+		binary.LittleEndian.PutUint32(b[28:], crc32.ChecksumIEEE(b[32+offset:32+offset+size]))
+	}
+
+	binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[12:32]))
+}
+
+func TestFilesWithoutStreams(t *testing.T) {
+	t.Parallel()
+
+	short, err := hex.DecodeString("377abcaf271c000473895c3000000000000000000500000000000000611f73eb0105320000")
+	require.NoError(t, err)
+
+	header := append(startHeader(0, 5), 0x01, 0x05, 0x01, 0x00, 0x00)
+	fixChecksums(header)
+
+	tables := []struct {
+		name string
+		b    []byte
+	}{
+		{
+			name: "one file",
+			b:    header,
+		},
+		{
+			name: "many files",
+			b:    short,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := sevenzip.NewReader(bytes.NewReader(table.b), int64(len(table.b)))
+			assert.ErrorIs(t, err, sevenzip.ErrMissingStream)
+		})
+	}
+}
+
+func FuzzNewReader(f *testing.F) {
+	for _, file := range []string{"empty.7z", "file_and_empty.7z", "t0.7z", "t1.7z", "t2.7z", "t4.7z", "symlink.7z"} {
+		b, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(b)
+	}
+
+	f.Fuzz(func(_ *testing.T, b []byte) {
+		if len(b) < 32 {
+			return
+		}
+
+		fixChecksums(b)
+
+		// Anything is allowed except panicking or allocating wildly
+		r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)), sevenzip.WithMaxHeaderEntries(1<<10), sevenzip.WithMaxMemory(1<<26))
+		if err != nil {
+			return
+		}
+
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+
+			_, _ = io.Copy(io.Discard, io.LimitReader(rc, 1<<16))
+			_ = rc.Close()
+		}
 	})
 }
 
@@ -425,101 +767,3489 @@ func TestFS(t *testing.T) {
 	}
 }
 
-func ExampleOpenReader() {
-	r, err := sevenzip.OpenReader(filepath.Join("testdata", "multi.7z.001"))
-	if err != nil {
-		panic(err)
+func TestPackedSize(t *testing.T) {
+	t.Parallel()
+
+	tables := []string{
+		"t0.7z",
+		"bcj2.7z",
+		"copy.7z",
+		"empty.7z",
+		"lzma1900.7z",
 	}
 
-	defer func() {
-		if err := r.Close(); err != nil {
-			panic(err)
-		}
-	}()
+	for _, table := range tables {
+		table := table
 
-	for _, file := range r.File {
-		fmt.Println(file.Name)
-	}
-	// Output: 01
-	// 02
-	// 03
-	// 04
-	// 05
-	// 06
-	// 07
-	// 08
-	// 09
-	// 10
-}
+		t.Run(table, func(t *testing.T) {
+			t.Parallel()
 
-func benchmarkArchiveParallel(b *testing.B, file string) {
-	b.Helper()
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table))
+			require.NoError(t, err)
 
-	for n := 0; n < b.N; n++ {
-		r, err := sevenzip.OpenReader(filepath.Join("testdata", file))
-		if err != nil {
-			b.Fatal(err)
-		}
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
 
-		var once sync.Once
+			streams := make(map[int]uint64)
 
-		f := func() {
-			if err := r.Close(); err != nil {
-				b.Fatal(err)
+			for _, f := range r.File {
+				if f.UncompressedSize == 0 {
+					assert.Zero(t, f.PackedSize)
+
+					continue
+				}
+
+				streams[f.Stream] += f.PackedSize
 			}
-		}
 
-		defer once.Do(f)
+			for stream, size := range streams {
+				assert.NotZero(t, size)
+				assert.Equal(t, r.StreamPackedSize(stream), size)
+			}
 
-		streams := make(map[int]struct{}, len(r.File))
+			assert.Zero(t, r.StreamPackedSize(-1))
+			assert.Zero(t, r.StreamPackedSize(len(streams)))
+		})
+	}
+}
 
-		for _, f := range r.File {
-			streams[f.Stream] = struct{}{}
-		}
+func TestReadlink(t *testing.T) {
+	t.Parallel()
 
-		eg := new(errgroup.Group)
-		eg.SetLimit(runtime.NumCPU())
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+	require.NoError(t, err)
 
-		for stream := range streams {
-			stream := stream
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
 
-			eg.Go(func() error {
-				return extractArchive(b, &r.Reader, stream, crc32.NewIEEE(), reader, true)
-			})
-		}
+	links := map[string]string{
+		"dir/link": "file.txt",
+		"abs":      "/etc/passwd",
+		"dangling": "missing",
+	}
 
-		if err := eg.Wait(); err != nil {
-			b.Fatal(err)
+	for _, f := range r.File {
+		// Linkname is populated before Readlink is called
+		linkname := f.Linkname
+		target, err := f.Readlink()
+
+		if expected, ok := links[f.Name]; ok {
+			require.NoError(t, err)
+			assert.True(t, f.IsSymlink())
+			assert.Equal(t, expected, target)
+			assert.Equal(t, expected, linkname)
+
+			continue
 		}
 
-		once.Do(f)
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+		assert.Empty(t, f.Linkname)
 	}
 }
 
-func benchmarkArchiveNaiveParallel(b *testing.B, file string, workers int) {
-	b.Helper()
+func TestReadLinkFS(t *testing.T) {
+	t.Parallel()
 
-	for n := 0; n < b.N; n++ {
-		r, err := sevenzip.OpenReader(filepath.Join("testdata", file))
-		if err != nil {
-			b.Fatal(err)
-		}
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+	require.NoError(t, err)
 
-		var once sync.Once
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
 
-		f := func() {
-			if err := r.Close(); err != nil {
-				b.Fatal(err)
-			}
-		}
+	target, err := r.ReadLink("dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, "file.txt", target)
 
-		defer once.Do(f)
+	_, err = r.ReadLink("dir/file.txt")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
 
-		eg := new(errgroup.Group)
-		eg.SetLimit(workers)
+	_, err = r.ReadLink("dir")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
 
-		for _, f := range r.File {
-			f := f
+	_, err = r.ReadLink("missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	info, err := r.Lstat("dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+
+	b, err := fs.ReadFile(r, "dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world\n", string(b))
+
+	_, err = r.Open("abs")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = r.Open("dangling")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	sub, err := fs.Sub(r, "dir")
+	require.NoError(t, err)
+
+	if err := fstest.TestFS(sub, "file.txt", "link"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAferoFs(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	afs := r.AferoFs()
+
+	for _, name := range []string{"dir/file.txt", "/dir/file.txt", "/dir/../dir/link"} {
+		b, err := afero.ReadFile(afs, name)
+		require.NoError(t, err)
+		assert.Equal(t, "hello, world\n", string(b))
+	}
+
+	infos, err := afero.ReadDir(afs, "/dir")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "file.txt", infos[0].Name())
+	assert.Equal(t, "link", infos[1].Name())
+
+	info, err := afs.Stat("/")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	info, ok, err := afs.(afero.Lstater).LstatIfPossible("/dir/link")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+
+	_, err = afs.Stat("/missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = afs.Create("/new")
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	_, err = afs.OpenFile("/dir/file.txt", os.O_RDWR, 0)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	assert.ErrorIs(t, afs.Remove("/dir/file.txt"), fs.ErrPermission)
+}
+
+func TestHTTPFileSystem(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	b, err := fs.ReadFile(r, "01")
+	require.NoError(t, err)
+
+	fi, err := r.Stat("01")
+	require.NoError(t, err)
+
+	handler := http.FileServer(r.HTTPFileSystem())
+
+	tables := []struct {
+		name, path, rng string
+		status          int
+		body            string
+	}{
+		{
+			name:   "file",
+			path:   "/01",
+			status: http.StatusOK,
+			body:   string(b),
+		},
+		{
+			name:   "range",
+			path:   "/01",
+			rng:    "bytes=1000-1999",
+			status: http.StatusPartialContent,
+			body:   string(b[1000:2000]),
+		},
+		{
+			name:   "suffix range",
+			path:   "/01",
+			rng:    "bytes=-100",
+			status: http.StatusPartialContent,
+			body:   string(b[len(b)-100:]),
+		},
+		{
+			name:   "missing",
+			path:   "/missing",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, table.path, nil)
+			if table.rng != "" {
+				req.Header.Set("Range", table.rng)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, table.status, rec.Code)
+
+			if table.status == http.StatusNotFound {
+				return
+			}
+
+			assert.Equal(t, table.body, rec.Body.String())
+			assert.Equal(t, fi.ModTime().Format(http.TimeFormat), rec.Header().Get("Last-Modified"))
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<a href="01">01</a>`)
+}
+
+func TestFSFastPaths(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	matches, err := r.Glob("*/*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/file.txt", "dir/link"}, matches)
+
+	_, err = r.Glob("[]")
+	assert.ErrorIs(t, err, path.ErrBadPattern)
+
+	entries, err := r.ReadDir("dir")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = r.ReadDir("dir/file.txt")
+	assert.Error(t, err) //nolint:testifylint
+
+	info, err := r.Stat("dir/link")
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	assert.Equal(t, int64(len("hello, world\n")), info.Size())
+
+	b, err := r.ReadFile("dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world\n", string(b))
+
+	_, err = r.ReadFile("dir")
+	assert.Error(t, err) //nolint:testifylint
+}
+
+func TestSub(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	sub, err := r.Sub("dir")
+	require.NoError(t, err)
+
+	if err := fstest.TestFS(sub, "file.txt", "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := fs.Glob(sub, "*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file.txt", "link"}, matches)
+
+	_, err = sub.Open("missing")
+
+	var pe *fs.PathError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, "missing", pe.Path)
+		assert.ErrorIs(t, pe, fs.ErrNotExist)
+	}
+
+	_, err = r.Sub("../dir")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	root, err := r.Sub(".")
+	require.NoError(t, err)
+	assert.Same(t, &r.Reader, root)
+}
+
+func TestCaseInsensitiveFS(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file string
+		opts       []sevenzip.ReaderOption
+		contents   string
+		err        error
+	}{
+		{
+			name:     "exact",
+			file:     "readme.txt",
+			contents: "lower\n",
+		},
+		{
+			name:     "exact with option",
+			file:     "README.TXT",
+			opts:     []sevenzip.ReaderOption{sevenzip.WithCaseInsensitiveFS()},
+			contents: "upper\n",
+		},
+		{
+			name: "mismatch",
+			file: "Readme.Txt",
+			err:  fs.ErrNotExist,
+		},
+		{
+			name:     "mismatch with option",
+			file:     "Readme.Txt",
+			opts:     []sevenzip.ReaderOption{sevenzip.WithCaseInsensitiveFS()},
+			contents: "upper\n",
+		},
+		{
+			name:     "directory mismatch with option",
+			file:     "dir/FILE.TXT",
+			opts:     []sevenzip.ReaderOption{sevenzip.WithCaseInsensitiveFS()},
+			contents: "file\n",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "case.7z"), table.opts...)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			b, err := fs.ReadFile(r, table.file)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, table.contents, string(b))
+
+			_, err = r.Stat(table.file)
+			require.NoError(t, err)
+		})
+	}
+}
+
+//nolint:funlen
+func TestDuplicatePolicy(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name   string
+		policy sevenzip.DuplicatePolicy
+		a, c   string
+		err    error
+	}{
+		{
+			name:   "error",
+			policy: sevenzip.DuplicateError,
+		},
+		{
+			name:   "first wins",
+			policy: sevenzip.DuplicateFirstWins,
+			a:      "first\n",
+			c:      "nested\n",
+		},
+		{
+			name:   "last wins",
+			policy: sevenzip.DuplicateLastWins,
+			a:      "second\n",
+			c:      "nested\n",
+		},
+		{
+			name:   "invalid",
+			policy: sevenzip.DuplicatePolicy(-1),
+			err:    sevenzip.ErrInvalidDuplicatePolicy,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "duplicate.7z"), sevenzip.WithDuplicatePolicy(table.policy))
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			if table.policy == sevenzip.DuplicateError {
+				for _, name := range []string{"a.txt", "b"} {
+					_, err = r.Stat(name)
+					assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+
+					_, err = r.Lstat(name)
+					assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+
+					_, err = r.Open(name)
+					assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+
+					_, err = fs.ReadFile(r, name)
+					assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+
+					_, err = r.ReadLink(name)
+					assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+				}
+
+				_, err = r.ReadDir("b")
+				assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+
+				_, err = fs.ReadDir(r, ".")
+				assert.ErrorIs(t, err, sevenzip.ErrDuplicate)
+
+				return
+			}
+
+			b, err := fs.ReadFile(r, "a.txt")
+			require.NoError(t, err)
+			assert.Equal(t, table.a, string(b))
+
+			b, err = fs.ReadFile(r, "b/c")
+			require.NoError(t, err)
+			assert.Equal(t, table.c, string(b))
+
+			info, err := r.Stat("b")
+			require.NoError(t, err)
+			assert.True(t, info.IsDir())
+
+			if err := fstest.TestFS(r, "a.txt", "b/c"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, clean string
+	}{
+		{"dir/file", "dir/file"},
+		{`dir\file`, "dir/file"},
+		{"/dir/./file", "dir/file"},
+		{"../../dir/file", "dir/file"},
+		{"dir/../../file", "file"},
+		{"dir/", "dir"},
+		{"/", ""},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			fh := sevenzip.FileHeader{Name: table.name}
+			assert.Equal(t, table.clean, fh.CleanName())
+		})
+	}
+}
+
+func TestNameMapping(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		mapping func(string) string
+		exists  []string
+		missing []string
+		err     error
+	}{
+		{
+			name:    "prefix",
+			mapping: func(name string) string { return path.Join("prefix", name) },
+			exists:  []string{"prefix/readme.txt", "prefix/Dir/File.txt"},
+			missing: []string{"readme.txt"},
+		},
+		{
+			name: "exclude",
+			mapping: func(name string) string {
+				if strings.HasPrefix(name, "Dir") {
+					return ""
+				}
+
+				return name
+			},
+			exists:  []string{"readme.txt"},
+			missing: []string{"Dir", "Dir/File.txt"},
+		},
+		{
+			name: "invalid",
+			err:  sevenzip.ErrNilNameMapping,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "case.7z"), sevenzip.WithNameMapping(table.mapping))
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			for _, name := range table.exists {
+				_, err := r.Stat(name)
+				assert.NoError(t, err, name)
+			}
+
+			for _, name := range table.missing {
+				_, err := r.Stat(name)
+				assert.ErrorIs(t, err, fs.ErrNotExist, name)
+			}
+
+			if err := fstest.TestFS(r, table.exists...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNamePolicy(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name   string
+		policy sevenzip.NamePolicy
+		raw    bool
+		err    error
+	}{
+		{
+			name:   "replace",
+			policy: sevenzip.NameReplace,
+		},
+		{
+			name:   "error",
+			policy: sevenzip.NameError,
+		},
+		{
+			name:   "raw",
+			policy: sevenzip.NameRaw,
+			raw:    true,
+		},
+		{
+			name:   "invalid",
+			policy: sevenzip.NamePolicy(-1),
+			err:    sevenzip.ErrInvalidNamePolicy,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "case.7z"), sevenzip.WithNamePolicy(table.policy))
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			for _, f := range r.File {
+				if !table.raw {
+					assert.Empty(t, f.RawName)
+
+					continue
+				}
+
+				name := strings.TrimSuffix(f.Name, "/")
+				require.Len(t, f.RawName, 2*len(name))
+
+				for i := range name {
+					assert.Equal(t, string([]byte{name[i], 0x00}), f.RawName[2*i:2*i+2])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractConcurrent(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	var (
+		mu    sync.Mutex
+		count int
+	)
+
+	err = r.ExtractConcurrent(context.Background(), 0, func(f *sevenzip.File, rc io.Reader) error {
+		mu.Lock()
+		count++
+		mu.Unlock()
+
+		return extractFile(t, rc, crc32.NewIEEE(), f)
+	})
+	require.NoError(t, err)
+	assert.Len(t, r.File, count)
+
+	errStop := errors.New("stop")
+
+	err = r.ExtractConcurrent(context.Background(), 1, func(_ *sevenzip.File, _ io.Reader) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = r.ExtractConcurrent(ctx, 1, func(_ *sevenzip.File, _ io.Reader) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestStreams(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	streams := r.Streams()
+	require.NotEmpty(t, streams)
+
+	for _, stream := range streams {
+		assert.Equal(t, r.StreamPackedSize(stream.Stream), stream.PackedSize)
+		assert.NotEmpty(t, stream.Methods)
+		assert.Equal(t, stream.Files, r.FilesInStream(stream.Stream))
+
+		rc, err := r.OpenStream(stream.Stream)
+		require.NoError(t, err)
+
+		for _, f := range stream.Files {
+			assert.Equal(t, stream.Stream, f.Stream)
+			require.NoError(t, extractFile(t, io.LimitReader(rc, int64(f.UncompressedSize)), crc32.NewIEEE(), f)) //nolint:gosec
+		}
+
+		n, err := io.Copy(io.Discard, rc)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		require.NoError(t, rc.Close())
+	}
+
+	_, err = r.OpenStream(len(streams))
+	assert.ErrorIs(t, err, sevenzip.ErrNoSuchStream)
+
+	assert.Nil(t, r.FilesInStream(-1))
+	assert.Nil(t, r.FilesInStream(len(streams)))
+}
+
+func TestOpenStreamChecksum(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "riscv.7z"))
+	require.NoError(t, err)
+
+	// The stream is stored straight after the signature header
+	b[64] ^= 0xff
+
+	r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+
+	streams := r.Streams()
+	require.Len(t, streams, 1)
+	require.NotZero(t, streams[0].CRC32)
+	assert.Equal(t, []string{"RISCV"}, streams[0].Methods)
+
+	rc, err := r.OpenStream(0)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, rc.Close())
+	}()
+
+	_, err = io.Copy(io.Discard, rc)
+
+	var e *sevenzip.ReadError
+	if assert.ErrorAs(t, err, &e) {
+		assert.Equal(t, 0, e.Stream)
+		assert.Empty(t, e.Name)
+	}
+
+	assert.ErrorIs(t, err, sevenzip.ErrChecksum)
+}
+
+func TestFileHeaderString(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file string
+		s, json    string
+	}{
+		{
+			name: "t0.7z",
+			file: "bar",
+			s:    "-rw-r--r-- bar 4 (4 packed, Copy) crc32=04a2b3e9 modified=2020-07-27T08:46:42Z",
+			json: `{"name":"bar","mode":420,"attributes":2175041568,"size":4,"packed_size":4,"methods":["Copy"],"crc32":77771753,"modified":"2020-07-27T08:46:42Z"}`, //nolint:lll
+		},
+		{
+			name: "file_and_empty.7z",
+			file: "empty",
+			s:    "-rw-rw-rw- empty 0",
+			json: `{"name":"empty","mode":438,"attributes":0,"size":0,"packed_size":0,"crc32":0}`,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.name))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			for _, f := range r.File {
+				if f.Name != table.file {
+					continue
+				}
+
+				assert.Equal(t, table.s, f.String())
+
+				b, err := json.Marshal(f)
+				require.NoError(t, err)
+				assert.JSONEq(t, table.json, string(b))
+
+				return
+			}
+
+			t.Fatal("file not found")
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	var stream sevenzip.StreamInfo
+	for _, s := range r.Streams() {
+		if len(s.Files) > len(stream.Files) {
+			stream = s
+		}
+	}
+
+	require.GreaterOrEqual(t, len(stream.Files), 3)
+
+	// Request files out of order, with a duplicate and a directory
+	files := []*sevenzip.File{stream.Files[len(stream.Files)/2], stream.Files[1], stream.Files[0]}
+	names := []string{files[0].Name, files[1].Name, files[2].Name, files[1].Name, "C", "."}
+
+	plan, err := r.Plan(names)
+	require.NoError(t, err)
+	require.Len(t, plan.Streams, 1)
+
+	var size, decoded uint64
+
+	for _, f := range stream.Files[:len(stream.Files)/2+1] {
+		decoded += f.UncompressedSize
+	}
+
+	for _, f := range files {
+		size += f.UncompressedSize
+	}
+
+	sp := plan.Streams[0]
+	assert.Equal(t, stream.Stream, sp.Stream)
+	assert.Equal(t, []*sevenzip.File{files[2], files[1], files[0]}, sp.Files)
+	assert.Equal(t, size, sp.Size)
+	assert.Equal(t, decoded, sp.DecodedSize)
+	assert.Equal(t, size, plan.Size)
+	assert.Equal(t, decoded, plan.DecodedSize)
+
+	_, err = r.Plan([]string{"missing"})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = r.Plan([]string{"/C"})
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestListing(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	l := r.Listing()
+	require.Len(t, l.Entries, len(r.File))
+
+	var size, packed uint64
+
+	for i, e := range l.Entries {
+		f := r.File[i]
+
+		assert.Equal(t, f.Name, e.Name)
+		assert.Equal(t, f.Mode(), e.Mode)
+		assert.Equal(t, f.UncompressedSize, e.Size)
+		assert.Equal(t, f.PackedSize, e.PackedSize)
+		assert.Equal(t, f.CRC32, e.CRC32)
+		assert.Equal(t, f.Modified, e.Modified)
+
+		// The listing and the file are encoded the same way
+		b, err := json.Marshal(e)
+		require.NoError(t, err)
+
+		expected, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(expected), string(b))
+
+		if f.Mode().IsDir() {
+			assert.Empty(t, e.Methods)
+		} else {
+			assert.Equal(t, []string{"Copy"}, e.Methods)
+		}
+
+		size += e.Size
+		packed += e.PackedSize
+	}
+
+	assert.Equal(t, size, l.Size)
+	assert.Equal(t, packed, l.PackedSize)
+}
+
+func TestPasswordCallback(t *testing.T) {
+	t.Parallel()
+
+	t.Run("encrypted headers", func(t *testing.T) {
+		t.Parallel()
+
+		var called bool
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "t3.7z"), sevenzip.WithPasswordCallback(func(f *sevenzip.File) (string, error) {
+			if f == nil {
+				called = true
+			}
+
+			return "password", nil
+		}))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		assert.True(t, called)
+		require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+	})
+
+	t.Run("unencrypted headers", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+
+		files := make(map[string]struct{})
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "t4.7z"), sevenzip.WithPasswordCallback(func(f *sevenzip.File) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			require.NotNil(t, f)
+			files[f.Name] = struct{}{}
+
+			return "password", nil
+		}))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		assert.Empty(t, files)
+		require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+		assert.NotEmpty(t, files)
+	})
+
+	t.Run("open stream", func(t *testing.T) {
+		t.Parallel()
+
+		var file *sevenzip.File
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "t4.7z"), sevenzip.WithPasswordCallback(func(f *sevenzip.File) (string, error) {
+			file = f
+
+			return "password", nil
+		}))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		files := r.FilesInStream(0)
+		require.NotEmpty(t, files)
+
+		rc, err := r.OpenStream(0)
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, rc.Close())
+		}()
+
+		_, err = io.Copy(io.Discard, rc)
+		require.NoError(t, err)
+		assert.Same(t, files[0], file)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+
+		errNoPassword := errors.New("no password")
+
+		_, err := sevenzip.OpenReader(filepath.Join("testdata", "t3.7z"), sevenzip.WithPasswordCallback(func(_ *sevenzip.File) (string, error) {
+			return "", errNoPassword
+		}))
+		assert.ErrorIs(t, err, errNoPassword)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := sevenzip.OpenReader(filepath.Join("testdata", "t4.7z"), sevenzip.WithPasswordCallback(nil))
+		assert.ErrorIs(t, err, sevenzip.ErrNilPasswordCallback)
+	})
+}
+
+func TestPasswordBytes(t *testing.T) {
+	t.Parallel()
+
+	password := []byte("password")
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "t4.7z"), sevenzip.WithPasswordBytes(password))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	// The reader keeps its own copy of the password
+	clear(password)
+
+	require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+
+	r.Wipe()
+
+	assert.ErrorIs(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true), sevenzip.ErrWrongPassword)
+}
+
+func TestSetPassword(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "t4.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	assert.True(t, r.Encrypted())
+	assert.ErrorIs(t, r.CheckPassword(), sevenzip.ErrWrongPassword)
+
+	r.SetPassword("notpassword")
+	assert.ErrorIs(t, r.CheckPassword(), sevenzip.ErrWrongPassword)
+
+	r.SetPassword("password")
+	require.NoError(t, r.CheckPassword())
+	require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+}
+
+func TestSetPasswordCache(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", "t5.7z"), "wrong", sevenzip.WithCache(1<<20))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	assert.ErrorIs(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true), sevenzip.ErrWrongPassword)
+
+	// The streams decoded with the wrong password mustn't be reused
+	r.SetPassword("password")
+	require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+}
+
+func TestCheckPassword(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file, password string
+		err                  error
+	}{
+		{
+			name:     "compressed files",
+			file:     "t4.7z",
+			password: "password",
+		},
+		{
+			name:     "compressed files wrong password",
+			file:     "t4.7z",
+			password: "wrong",
+			err:      sevenzip.ErrWrongPassword,
+		},
+		{
+			name:     "uncompressed files",
+			file:     "t5.7z",
+			password: "password",
+		},
+		{
+			name:     "uncompressed files wrong password",
+			file:     "t5.7z",
+			password: "wrong",
+			err:      sevenzip.ErrWrongPassword,
+		},
+		{
+			name:     "large file",
+			file:     "7zcracker.7z",
+			password: "876",
+		},
+		{
+			name:     "large file wrong password",
+			file:     "7zcracker.7z",
+			password: "wrong",
+			err:      sevenzip.ErrWrongPassword,
+		},
+		{
+			name: "not encrypted",
+			file: "lzma.7z",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			if table.err != nil {
+				assert.ErrorIs(t, r.CheckPassword(), table.err)
+
+				return
+			}
+
+			assert.NoError(t, r.CheckPassword())
+		})
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file, password string
+		encrypted            bool
+		err                  error
+	}{
+		{
+			name: "unencrypted",
+			file: "t0.7z",
+		},
+		{
+			name:      "encrypted headers",
+			file:      "t3.7z",
+			password:  "password",
+			encrypted: true,
+		},
+		{
+			name: "encrypted headers without password",
+			file: "t3.7z",
+			err:  sevenzip.ErrPasswordRequired,
+		},
+		{
+			name:      "unencrypted headers without password",
+			file:      "t5.7z",
+			encrypted: true,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			assert.Equal(t, table.encrypted, r.Encrypted())
+		})
+	}
+}
+
+func TestSignatureOptions(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		file string
+		opts []sevenzip.ReaderOption
+		err  error
+	}{
+		{
+			name: "default search",
+			file: "sfx.exe",
+		},
+		{
+			name: "search limit too small",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithSignatureSearchLimit(1 << 16)},
+			err:  sevenzip.ErrFormat,
+		},
+		{
+			name: "search limit larger",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithSignatureSearchLimit(1 << 24)},
+		},
+		{
+			name: "invalid search limit",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithSignatureSearchLimit(0)},
+			err:  sevenzip.ErrInvalidSearchLimit,
+		},
+		{
+			name: "archive offset",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithArchiveOffset(441592)},
+		},
+		{
+			name: "wrong archive offset",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithArchiveOffset(0)},
+			err:  sevenzip.ErrFormat,
+		},
+		{
+			name: "archive offset beyond end",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithArchiveOffset(1 << 30)},
+			err:  sevenzip.ErrFormat,
+		},
+		{
+			name: "invalid archive offset",
+			file: "sfx.exe",
+			opts: []sevenzip.ReaderOption{sevenzip.WithArchiveOffset(-1)},
+			err:  sevenzip.ErrInvalidArchiveOffset,
+		},
+		{
+			name: "regular archive offset",
+			file: "t0.7z",
+			opts: []sevenzip.ReaderOption{sevenzip.WithArchiveOffset(0)},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file), table.opts...)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			assert.NotEmpty(t, r.File)
+		})
+	}
+}
+
+func TestVersionAndOffset(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		file         string
+		major, minor int
+		offset       int64
+	}{
+		{"lzma1900.7z", 0, 4, 0},
+		{"t0.7z", 0, 4, 0},
+		{"sfx.exe", 0, 4, 441592},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.file, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			major, minor := r.Version()
+			assert.Equal(t, table.major, major)
+			assert.Equal(t, table.minor, minor)
+			assert.Equal(t, table.offset, r.ArchiveOffset())
+		})
+	}
+}
+
+func TestFindArchives(t *testing.T) {
+	t.Parallel()
+
+	var (
+		buf     bytes.Buffer
+		offsets []int64
+		names   [][]string
+	)
+
+	for _, file := range []string{"bzip2.7z", "lzma.7z"} {
+		b, err := os.ReadFile(filepath.Join("testdata", file))
+		require.NoError(t, err)
+
+		buf.WriteString("junk")
+
+		offsets = append(offsets, int64(buf.Len()))
+
+		buf.Write(b)
+
+		r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)))
+		require.NoError(t, err)
+
+		var files []string
+		for _, f := range r.File {
+			files = append(files, f.Name)
+		}
+
+		names = append(names, files)
+	}
+
+	ra := bytes.NewReader(buf.Bytes())
+
+	found, err := sevenzip.FindArchives(ra, ra.Size())
+	require.NoError(t, err)
+	assert.Equal(t, offsets, found)
+
+	for i, offset := range found {
+		r, err := sevenzip.NewReaderAt(ra, ra.Size(), offset)
+		require.NoError(t, err)
+
+		var files []string
+		for _, f := range r.File {
+			files = append(files, f.Name)
+		}
+
+		assert.Equal(t, names[i], files)
+		assert.NoError(t, extractArchive(t, r, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name  string
+		file  string
+		limit int64
+		err   error
+	}{
+		{
+			name:  "lzma header",
+			file:  "lzma.7z",
+			limit: 2048,
+			err:   sevenzip.ErrMemoryLimit,
+		},
+		{
+			name:  "lzma",
+			file:  "lzma.7z",
+			limit: 1 << 20,
+		},
+		{
+			name:  "lzma2",
+			file:  "lzma2.7z",
+			limit: 1 << 20,
+		},
+		{
+			name:  "zstd window",
+			file:  "zstd.7z",
+			limit: 1 << 16,
+			err:   sevenzip.ErrMemoryLimit,
+		},
+		{
+			name:  "zstd",
+			file:  "zstd.7z",
+			limit: 1 << 24,
+		},
+		{
+			name:  "ppmd model",
+			file:  "ppmd.7z",
+			limit: 1 << 20,
+			err:   sevenzip.ErrMemoryLimit,
+		},
+		{
+			name:  "ppmd",
+			file:  "ppmd.7z",
+			limit: 1 << 25,
+		},
+		{
+			name:  "invalid",
+			file:  "lzma.7z",
+			limit: 0,
+			err:   sevenzip.ErrInvalidMaxMemory,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file), sevenzip.WithMaxMemory(table.limit))
+			if err == nil {
+				defer func() {
+					require.NoError(t, r.Close())
+				}()
+
+				err = extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true)
+			}
+
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMaxDictionarySize(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name  string
+		file  string
+		limit int64
+		fail  bool
+		err   error
+	}{
+		{
+			name:  "lzma distance",
+			file:  "lzma.7z",
+			limit: 1 << 12,
+			fail:  true,
+		},
+		{
+			name:  "lzma",
+			file:  "lzma.7z",
+			limit: 1 << 16,
+		},
+		{
+			name:  "lzma2 distance",
+			file:  "lzma2.7z",
+			limit: 1 << 12,
+			fail:  true,
+		},
+		{
+			name:  "lzma2",
+			file:  "lzma2.7z",
+			limit: 1 << 16,
+		},
+		{
+			name:  "invalid",
+			file:  "lzma.7z",
+			limit: 1 << 10,
+			err:   sevenzip.ErrInvalidMaxDictionary,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file), sevenzip.WithMaxDictionarySize(table.limit))
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			err = extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true)
+			if table.fail {
+				assert.Error(t, err) //nolint:testifylint
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestZstdOptions(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		opts []sevenzip.ReaderOption
+		err  error
+	}{
+		{
+			name: "window exceeded",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdMaxWindow(1 << 10)},
+			err:  sevenzip.ErrMemoryLimit,
+		},
+		{
+			name: "window",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdMaxWindow(1 << 24)},
+		},
+		{
+			name: "window and memory",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdMaxWindow(1 << 24), sevenzip.WithMaxMemory(1 << 16)},
+			err:  sevenzip.ErrMemoryLimit,
+		},
+		{
+			name: "concurrency",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdConcurrency(1)},
+		},
+		{
+			name: "low memory",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdLowMemory()},
+		},
+		{
+			name: "invalid window",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdMaxWindow(512)},
+			err:  sevenzip.ErrInvalidZstdWindow,
+		},
+		{
+			name: "invalid concurrency",
+			opts: []sevenzip.ReaderOption{sevenzip.WithZstdConcurrency(0)},
+			err:  sevenzip.ErrInvalidZstdConcurrency,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "zstd.7z"), table.opts...)
+			if err == nil {
+				defer func() {
+					require.NoError(t, r.Close())
+				}()
+
+				err = extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true)
+			}
+
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDecompressors(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	copyReader := func(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		calls.Add(1)
+
+		return readers[0], nil
+	}
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "copy.7z"), sevenzip.WithDecompressors(map[string]sevenzip.Decompressor{
+		sevenzip.MethodCopy: copyReader,
+	}))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+	assert.Positive(t, calls.Load())
+
+	// Disabling a method only affects the Reader it was disabled for
+	disabled, err := sevenzip.OpenReader(filepath.Join("testdata", "copy.7z"), sevenzip.WithDecompressors(map[string]sevenzip.Decompressor{
+		sevenzip.MethodCopy: nil,
+	}))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, disabled.Close())
+	}()
+
+	err = extractArchive(t, &disabled.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true)
+
+	var ume *sevenzip.UnsupportedMethodError
+	if assert.ErrorAs(t, err, &ume) {
+		assert.Equal(t, []byte(sevenzip.MethodCopy), ume.ID)
+	}
+
+	global, err := sevenzip.OpenReader(filepath.Join("testdata", "copy.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, global.Close())
+	}()
+
+	assert.NoError(t, extractArchive(t, &global.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+}
+
+func TestReplaceDecompressor(t *testing.T) {
+	t.Parallel()
+
+	// Use a method ID that no archive uses so other tests aren't affected
+	method := []byte{0x7f, 0xff, 0xff, 0xff}
+
+	dcomp := func(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+		return readers[0], nil
+	}
+
+	sevenzip.RegisterDecompressor(method, dcomp)
+
+	assert.Panics(t, func() {
+		sevenzip.RegisterDecompressor(method, dcomp)
+	})
+
+	assert.NotPanics(t, func() {
+		sevenzip.ReplaceDecompressor(method, dcomp)
+	})
+
+	// Once unregistered, the method can be registered again
+	sevenzip.ReplaceDecompressor(method, nil)
+
+	assert.NotPanics(t, func() {
+		sevenzip.RegisterDecompressor(method, dcomp)
+	})
+}
+
+func TestMethodName(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		method []byte
+		name   string
+	}{
+		{
+			method: []byte(sevenzip.MethodLZMA2),
+			name:   "LZMA2",
+		},
+		{
+			method: []byte(sevenzip.MethodAES256SHA256),
+			name:   "AES",
+		},
+		{
+			method: []byte(sevenzip.MethodLizard),
+			name:   "Lizard",
+		},
+		{
+			method: []byte{0x7f, 0xff},
+			name:   "7fff",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, table.name, sevenzip.MethodName(table.method))
+		})
+	}
+}
+
+func TestResetDecompressor(t *testing.T) {
+	t.Parallel()
+
+	// With pooling disabled, every file opens a new PPMd decoder which may
+	// be a closed one that's been reset, check that it decodes the same
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "ppmd.7z"), sevenzip.WithPoolSize(0))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+	}
+}
+
+func TestListOnly(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file, password string
+	}{
+		{
+			name: "compressed header",
+			file: "lzma1900.7z",
+		},
+		{
+			name:     "encrypted header",
+			file:     "t3.7z",
+			password: "password",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			lr, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password, sevenzip.ListOnly())
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, lr.Close())
+			}()
+
+			require.Len(t, lr.File, len(r.File))
+
+			for i, f := range lr.File {
+				assert.Equal(t, r.File[i].Name, f.Name)
+				assert.Equal(t, r.File[i].UncompressedSize, f.UncompressedSize)
+				assert.Equal(t, r.File[i].Modified, f.Modified)
+				assert.Equal(t, r.File[i].Stream, f.Stream)
+				assert.Zero(t, f.CRC32)
+
+				_, err := f.Open()
+				assert.ErrorIs(t, err, sevenzip.ErrListOnly)
+			}
+
+			require.NotEmpty(t, lr.Streams())
+
+			for _, s := range lr.Streams() {
+				_, err := lr.OpenStream(s.Stream)
+				assert.ErrorIs(t, err, sevenzip.ErrListOnly)
+			}
+		})
+	}
+}
+
+func TestRandomAccess(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name   string
+		file   string
+		random bool
+	}{
+		{
+			name:   "copy",
+			file:   "copy.7z",
+			random: true,
+		},
+		{
+			name: "compressed",
+			file: "lzma.7z",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			for _, f := range r.File {
+				if f.FileInfo().IsDir() || f.UncompressedSize == 0 {
+					continue
+				}
+
+				rc, err := f.Open()
+				require.NoError(t, err)
+
+				_, isReaderAt := rc.(io.ReaderAt)
+				_, isSeeker := rc.(io.Seeker)
+
+				assert.Equal(t, table.random, isReaderAt)
+				assert.Equal(t, table.random, isSeeker)
+
+				if table.random {
+					b, err := io.ReadAll(rc)
+					require.NoError(t, err)
+					assert.Equal(t, f.CRC32, crc32.ChecksumIEEE(b))
+
+					// Read the second half and then the whole file again
+					half := len(b) / 2
+					p := make([]byte, len(b)-half)
+
+					_, err = rc.(io.ReaderAt).ReadAt(p, int64(half))
+					require.NoError(t, err)
+					assert.Equal(t, b[half:], p)
+
+					_, err = rc.(io.Seeker).Seek(0, io.SeekStart)
+					require.NoError(t, err)
+
+					b2, err := io.ReadAll(rc)
+					require.NoError(t, err)
+					assert.Equal(t, b, b2)
+				}
+
+				require.NoError(t, rc.Close())
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name     string
+		file     string
+		password string
+		size     int64
+		err      error
+	}{
+		{
+			name: "cached",
+			file: "lzma1900.7z",
+			size: 1 << 26,
+		},
+		{
+			name: "too small",
+			file: "lzma.7z",
+			size: 1,
+		},
+		{
+			name:     "encrypted",
+			file:     "t5.7z",
+			password: "password",
+			size:     1 << 20,
+		},
+		{
+			name:     "wrong password",
+			file:     "t5.7z",
+			password: "notpassword",
+			size:     1 << 20,
+			err:      sevenzip.ErrWrongPassword,
+		},
+		{
+			name: "invalid",
+			file: "lzma1900.7z",
+			err:  sevenzip.ErrInvalidCacheSize,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password, sevenzip.WithCache(table.size))
+			if err == nil {
+				defer func() {
+					require.NoError(t, r.Close())
+				}()
+
+				// Extract in reverse order which would otherwise
+				// decode each stream repeatedly
+				h := crc32.NewIEEE()
+
+				for i := len(r.File) - 1; i >= 0 && err == nil; i-- {
+					var rc io.ReadCloser
+
+					if rc, err = r.File[i].Open(); err == nil {
+						err = errors.Join(extractFile(t, rc, h, r.File[i]), rc.Close())
+					}
+				}
+			}
+
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+type countingPool struct {
+	puts *atomic.Int64
+}
+
+func (p *countingPool) Get(_ int64) (sevenzip.SizeReadSeekCloser, bool) {
+	return nil, false
+}
+
+func (p *countingPool) Put(_ int64, rc sevenzip.SizeReadSeekCloser) bool {
+	p.puts.Add(1)
+
+	_ = rc.Close()
+
+	return true
+}
+
+func (p *countingPool) Close() error {
+	return nil
+}
+
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads.Add(1)
+
+	return c.r.ReadAt(p, off) //nolint:wrapcheck
+}
+
+func TestReadCache(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+
+	read := func(opts ...sevenzip.ReaderOption) int64 {
+		c := &countingReaderAt{r: f}
+
+		r, err := sevenzip.NewReader(c, fi.Size(), opts...)
+		require.NoError(t, err)
+
+		for _, f := range r.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+
+			_, err = io.Copy(io.Discard, rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+		}
+
+		return c.reads.Load()
+	}
+
+	uncached := read()
+	cached := read(sevenzip.WithReadCache(1<<16, 16))
+
+	assert.Less(t, cached*10, uncached)
+
+	_, err = sevenzip.NewReader(f, fi.Size(), sevenzip.WithReadCache(0, 16))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidReadCache)
+
+	_, err = sevenzip.NewReader(f, fi.Size(), sevenzip.WithReadCache(1<<16, 0))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidReadCache)
+}
+
+func TestReadBufferSize(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+
+	read := func(opts ...sevenzip.ReaderOption) int64 {
+		c := &countingReaderAt{r: f}
+
+		r, err := sevenzip.NewReader(c, fi.Size(), opts...)
+		require.NoError(t, err)
+		require.NoError(t, extractArchive(t, r, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+
+		return c.reads.Load()
+	}
+
+	small := read()
+	large := read(sevenzip.WithReadBufferSize(1 << 20))
+
+	assert.Less(t, large*10, small)
+
+	_, err = sevenzip.NewReader(f, fi.Size(), sevenzip.WithReadBufferSize(0))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidBufferSize)
+}
+
+func TestReadAhead(t *testing.T) {
+	t.Parallel()
+
+	tables := []string{
+		"bcj2.7z",
+		"lzma1900.7z",
+		"multi.7z.001",
+		"t3.7z",
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table), "password", sevenzip.WithReadAhead(1<<12, 2))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+		})
+	}
+
+	_, err := sevenzip.OpenReader(filepath.Join("testdata", "t0.7z"), sevenzip.WithReadAhead(0, 2))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidReadAhead)
+
+	_, err = sevenzip.OpenReader(filepath.Join("testdata", "t0.7z"), sevenzip.WithReadAhead(1<<12, 0))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidReadAhead)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file, password string
+	}{
+		{
+			name: "solid",
+			file: "lzma1900.7z",
+		},
+		{
+			name: "multiple streams",
+			file: "copy.7z",
+		},
+		{
+			name: "multi-volume",
+			file: "multi.7z.001",
+		},
+		{
+			name:     "encrypted",
+			file:     "t3.7z",
+			password: "password",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			require.NoError(t, r.Verify(context.Background(), 0))
+		})
+	}
+
+	t.Run("corrupt", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := os.ReadFile(filepath.Join("testdata", "copy.7z"))
+		require.NoError(t, err)
+
+		// The first file is stored straight after the signature header
+		b[32] ^= 0xff
+
+		r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)))
+		require.NoError(t, err)
+
+		err = r.Verify(context.Background(), 2)
+
+		var e *sevenzip.ReadError
+		if assert.ErrorAs(t, err, &e) {
+			assert.NotEmpty(t, e.Name)
+			assert.False(t, e.Encrypted)
+		}
+
+		assert.ErrorIs(t, err, sevenzip.ErrChecksum)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "copy.7z"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, r.Verify(ctx, 1), context.Canceled)
+	})
+}
+
+func TestVerifyAll(t *testing.T) {
+	t.Parallel()
+
+	t.Run("corrupt", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := os.ReadFile(filepath.Join("testdata", "copy.7z"))
+		require.NoError(t, err)
+
+		// The first file is stored straight after the signature header
+		b[32] ^= 0xff
+
+		r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)))
+		require.NoError(t, err)
+
+		report, err := r.VerifyAll(context.Background(), 2)
+		require.NoError(t, err)
+		require.Len(t, report.Files, len(r.File))
+
+		failed := report.Failed()
+		if assert.Len(t, failed, 1) {
+			assert.Equal(t, sevenzip.OutcomeChecksum, failed[0].Outcome)
+			assert.ErrorIs(t, failed[0].Err, sevenzip.ErrChecksum)
+		}
+
+		assert.ErrorIs(t, report.Err(), sevenzip.ErrChecksum)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", "t4.7z"), "notpassword")
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		report, err := r.VerifyAll(context.Background(), 0)
+		require.NoError(t, err)
+
+		for _, fr := range report.Failed() {
+			assert.Equal(t, sevenzip.OutcomeWrongPassword, fr.Outcome)
+		}
+
+		assert.ErrorIs(t, report.Err(), sevenzip.ErrWrongPassword)
+	})
+}
+
+func TestExtractAll(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "copy.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	require.Greater(t, len(r.File), 1)
+
+	errStop := errors.New("stop")
+	bad := r.File[0]
+
+	report, err := r.ExtractAll(context.Background(), 1, func(f *sevenzip.File, rc io.Reader) error {
+		if f == bad {
+			return errStop
+		}
+
+		return extractFile(t, rc, crc32.NewIEEE(), f)
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Files, len(r.File))
+
+	for i, fr := range report.Files {
+		assert.Equal(t, r.File[i], fr.File)
+
+		if fr.File == bad {
+			assert.Equal(t, sevenzip.OutcomeError, fr.Outcome)
+			assert.ErrorIs(t, fr.Err, errStop)
+		} else {
+			assert.Equal(t, sevenzip.OutcomeOK, fr.Outcome)
+			assert.NoError(t, fr.Err)
+		}
+	}
+
+	assert.Len(t, report.Failed(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err = r.ExtractAll(ctx, 1, func(_ *sevenzip.File, _ io.Reader) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	for _, fr := range report.Files {
+		assert.Equal(t, sevenzip.OutcomeSkipped, fr.Outcome)
+	}
+
+	assert.Len(t, report.Failed(), len(r.File))
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+
+	// The volumes are about 6 KiB in total so reading them at 20 KB/s
+	// takes at least 300ms
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "multi.7z.001"), sevenzip.WithRateLimit(20000))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		_, err = io.Copy(io.Discard, rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	_, err = sevenzip.OpenReader(filepath.Join("testdata", "t0.7z"), sevenzip.WithRateLimit(0))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidRateLimit)
+}
+
+func TestMmap(t *testing.T) {
+	t.Parallel()
+
+	tables := []string{
+		"copy.7z",
+		"lzma1900.7z",
+		"multi.7z.001",
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table), sevenzip.WithMmap())
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+		})
+	}
+}
+
+func TestMaxOpenVolumes(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "multi.7z.001"), sevenzip.WithMaxOpenVolumes(1))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	assert.Len(t, r.Volumes(), 6)
+	require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+
+	_, err = sevenzip.OpenReader(filepath.Join("testdata", "multi.7z.001"), sevenzip.WithMaxOpenVolumes(0))
+	assert.ErrorIs(t, err, sevenzip.ErrInvalidMaxVolumes)
+}
+
+func TestVolumeSizes(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "multi.7z.001"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	assert.Equal(t, []int64{1024, 1024, 1024, 1024, 1024, 990}, r.VolumeSizes())
+
+	// The single stream is spread across every volume
+	volumes, err := r.StreamVolumes(0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, volumes)
+
+	for _, f := range r.File {
+		assert.Equal(t, volumes, r.FileVolumes(f))
+	}
+
+	_, err = r.StreamVolumes(1)
+	assert.ErrorIs(t, err, sevenzip.ErrNoSuchStream)
+
+	r2, err := sevenzip.OpenReader(filepath.Join("testdata", "file_and_empty.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r2.Close())
+	}()
+
+	assert.Len(t, r2.VolumeSizes(), 1)
+
+	for _, f := range r2.File {
+		if f.UncompressedSize == 0 {
+			assert.Nil(t, r2.FileVolumes(f))
+		} else {
+			assert.Equal(t, []int{0}, r2.FileVolumes(f))
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	stats := r.Stats()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		c, err := r.Clone()
+		require.NoError(t, err)
+
+		require.Len(t, c.File, len(r.File))
+		assert.Equal(t, r.File[0].FileHeader, c.File[0].FileHeader)
+		assert.NotSame(t, r.File[0], c.File[0])
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, extractArchive(t, c, -1, crc32.NewIEEE(), iotest.OneByteReader, true))
+			assert.NotZero(t, c.Stats().BytesDecoded)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, stats, r.Stats())
+}
+
+func TestPoolOptions(t *testing.T) {
+	t.Parallel()
+
+	puts := new(atomic.Int64)
+
+	tables := []struct {
+		name string
+		opt  sevenzip.ReaderOption
+		puts *atomic.Int64
+		err  error
+	}{
+		{
+			name: "size",
+			opt:  sevenzip.WithPoolSize(1),
+		},
+		{
+			name: "disabled",
+			opt:  sevenzip.WithPoolSize(0),
+		},
+		{
+			name: "invalid size",
+			opt:  sevenzip.WithPoolSize(-1),
+			err:  sevenzip.ErrInvalidPoolSize,
+		},
+		{
+			name: "constructor",
+			opt: sevenzip.WithPoolConstructor(func() (sevenzip.Pooler, error) {
+				return &countingPool{puts}, nil
+			}),
+			puts: puts,
+		},
+		{
+			name: "nil constructor",
+			opt:  sevenzip.WithPoolConstructor(nil),
+			err:  sevenzip.ErrNilPoolConstructor,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"), table.opt)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			// Close each file before reading all of it so it is pooled
+			for _, f := range r.File {
+				rc, err := f.Open()
+				require.NoError(t, err)
+
+				_, err = io.CopyN(io.Discard, rc, int64(f.UncompressedSize/2)) //nolint:gosec
+				require.NoError(t, err)
+				require.NoError(t, rc.Close())
+			}
+
+			if table.puts != nil {
+				assert.Positive(t, table.puts.Load())
+			}
+		})
+	}
+}
+
+func TestWithoutPooling(t *testing.T) {
+	t.Parallel()
+
+	puts := new(atomic.Int64)
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"),
+		sevenzip.WithPoolConstructor(func() (sevenzip.Pooler, error) {
+			return &countingPool{puts}, nil
+		}),
+		sevenzip.WithoutPooling(),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		_, err = io.CopyN(io.Discard, rc, int64(f.UncompressedSize/2)) //nolint:gosec
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+	}
+
+	assert.Zero(t, puts.Load())
+	assert.Zero(t, r.Stats().PoolHits)
+}
+
+var errPoolClose = errors.New("pool close")
+
+type errorPool struct {
+	countingPool
+}
+
+func (p *errorPool) Close() error {
+	return errPoolClose
+}
+
+func TestReaderClose(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "lzma.7z"))
+	require.NoError(t, err)
+
+	r, err := sevenzip.NewReader(bytes.NewReader(b), int64(len(b)),
+		sevenzip.WithPoolConstructor(func() (sevenzip.Pooler, error) {
+			return &errorPool{countingPool{new(atomic.Int64)}}, nil
+		}),
+	)
+	require.NoError(t, err)
+
+	f := r.File[0]
+
+	rc, err := f.Open()
+	require.NoError(t, err)
+
+	_, err = io.CopyN(io.Discard, rc, int64(f.UncompressedSize/2)) //nolint:gosec
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	assert.ErrorIs(t, r.Close(), errPoolClose)
+
+	// The Reader is still usable afterwards
+	rc, err = f.Open()
+	require.NoError(t, err)
+
+	_, err = io.Copy(io.Discard, rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+}
+
+func TestSharedPool(t *testing.T) {
+	t.Parallel()
+
+	_, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"), sevenzip.WithSharedPool(nil, ""))
+	assert.ErrorIs(t, err, sevenzip.ErrNilSharedPool)
+
+	sp := sevenzip.NewSharedPool(0)
+	name := filepath.Join("testdata", "lzma1900.7z")
+
+	g := new(errgroup.Group)
+
+	for i := 0; i < 4; i++ {
+		g.Go(func() error {
+			r, err := sevenzip.OpenReader(name, sevenzip.WithSharedPool(sp, name))
+			if err != nil {
+				return err
+			}
+
+			return errors.Join(extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true), r.Close())
+		})
+	}
+
+	assert.NoError(t, g.Wait())
+	assert.NoError(t, sp.Close())
+}
+
+func TestSharedPoolEncrypted(t *testing.T) {
+	t.Parallel()
+
+	sp := sevenzip.NewSharedPool(0)
+	name := filepath.Join("testdata", "7zcracker.7z")
+
+	r1, err := sevenzip.OpenReaderWithPassword(name, "876", sevenzip.WithSharedPool(sp, name))
+	require.NoError(t, err)
+
+	f, err := r1.Open("7-zip.chm")
+	require.NoError(t, err)
+
+	_, err = io.Copy(io.Discard, f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, r1.Close())
+
+	for _, password := range []string{"", "wrong"} {
+		r2, err := sevenzip.OpenReaderWithPassword(name, password, sevenzip.WithSharedPool(sp, name))
+		require.NoError(t, err)
+
+		f, err = r2.Open("7z2john.pl")
+		if err == nil {
+			_, err = io.Copy(io.Discard, f)
+			_ = f.Close()
+		}
+
+		assert.ErrorIs(t, err, sevenzip.ErrWrongPassword, password)
+		require.NoError(t, r2.Close())
+	}
+
+	assert.NoError(t, sp.Close())
+}
+
+type testCollector struct {
+	packed, decoded    atomic.Int64
+	streams            atomic.Int64
+	poolHits, poolMiss atomic.Int64
+
+	mu     sync.Mutex
+	codecs map[string]time.Duration
+}
+
+func (c *testCollector) PackedRead(n int)    { c.packed.Add(int64(n)) }
+func (c *testCollector) Decoded(n int)       { c.decoded.Add(int64(n)) }
+func (c *testCollector) StreamDecoded(_ int) { c.streams.Add(1) }
+func (c *testCollector) PoolHit()            { c.poolHits.Add(1) }
+func (c *testCollector) PoolMiss()           { c.poolMiss.Add(1) }
+
+func (c *testCollector) CodecTime(method string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.codecs == nil {
+		c.codecs = make(map[string]time.Duration)
+	}
+
+	c.codecs[method] += d
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file string
+	}{
+		{
+			name: "solid",
+			file: "lzma.7z",
+		},
+		{
+			name: "chained coders",
+			file: "bcj.7z",
+		},
+		{
+			name: "multiple inputs",
+			file: "bcj2.7z",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := new(testCollector)
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file), sevenzip.WithMetrics(c))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+
+			stats := r.Stats()
+
+			assert.Positive(t, c.packed.Load())
+			assert.Equal(t, stats.BytesDecoded, uint64(c.decoded.Load())) //nolint:gosec
+			assert.Equal(t, stats.PoolHits, uint64(c.poolHits.Load()))    //nolint:gosec
+			assert.Equal(t, stats.PoolMisses, uint64(c.poolMiss.Load()))  //nolint:gosec
+			assert.Equal(t, stats.PoolMisses, uint64(c.streams.Load()))   //nolint:gosec
+
+			for _, stream := range r.Streams() {
+				for _, method := range stream.Methods {
+					assert.Contains(t, c.codecs, method)
+				}
+			}
+		})
+	}
+
+	_, err := sevenzip.OpenReader(filepath.Join("testdata", "t0.7z"), sevenzip.WithMetrics(nil))
+	assert.ErrorIs(t, err, sevenzip.ErrNilCollector)
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name     string
+		level    slog.Level
+		messages []string
+	}{
+		{
+			name:  "debug",
+			level: slog.LevelDebug,
+			messages: []string{
+				"found start header",
+				"found encoded header",
+				"parsed header",
+				"found stream",
+				"found file",
+				"decoding folder",
+			},
+		},
+		{
+			name:  "info",
+			level: slog.LevelInfo,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := new(bytes.Buffer)
+			logger := slog.New(slog.NewJSONHandler(b, &slog.HandlerOptions{Level: table.level}))
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"), sevenzip.WithLogger(logger))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, true))
+
+			messages := make(map[string]struct{})
+			dec := json.NewDecoder(b)
+
+			for {
+				var record struct {
+					Msg    string   `json:"msg"`
+					Coders []string `json:"coders"`
+				}
+
+				if err := dec.Decode(&record); err != nil {
+					require.ErrorIs(t, err, io.EOF)
+
+					break
+				}
+
+				messages[record.Msg] = struct{}{}
+
+				if record.Msg == "found stream" {
+					assert.NotEmpty(t, record.Coders)
+				}
+			}
+
+			assert.Len(t, messages, len(table.messages))
+
+			for _, msg := range table.messages {
+				assert.Contains(t, messages, msg)
+			}
+		})
+	}
+
+	_, err := sevenzip.OpenReader(filepath.Join("testdata", "t0.7z"), sevenzip.WithLogger(nil))
+	assert.ErrorIs(t, err, sevenzip.ErrNilLogger)
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("write error") //nolint:err113
+}
+
+func TestDumpHeader(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file string
+		contains   []string
+	}{
+		{
+			name: "solid with bcj2",
+			file: "lzma1900.7z",
+			contains: []string{
+				"Version = 0.4\n",
+				"Headers Compressed = +\n",
+				"\nFolder = 2\n",
+				"Coder = 3 BCJ2 in=4 out=1 props=\n",
+				"Bind Pair = in5 <- out0\n",
+				"Path = DOC/7zFormat.txt\n",
+			},
+		},
+		{
+			name: "empty",
+			file: "empty.7z",
+			contains: []string{
+				"Version = 0.4\n",
+			},
+		},
+		{
+			name: "sfx",
+			file: "sfx.exe",
+			contains: []string{
+				"Offset = 441592\n",
+			},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			b := new(bytes.Buffer)
+			require.NoError(t, r.DumpHeader(b))
+
+			for _, s := range table.contains {
+				assert.Contains(t, b.String(), s)
+			}
+
+			assert.Equal(t, len(r.File), strings.Count(b.String(), "\nPath = "))
+
+			assert.Error(t, r.DumpHeader(errWriter{})) //nolint:testifylint
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name      string
+		optimised bool
+	}{
+		{
+			name:      "optimised",
+			optimised: true,
+		},
+		{
+			name: "naive",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			var (
+				files, size uint64
+				streams     = make(map[int]struct{})
+			)
+
+			for _, f := range r.File {
+				if f.UncompressedSize > 0 {
+					files++
+					size += f.UncompressedSize
+					streams[f.Stream] = struct{}{}
+				}
+			}
+
+			require.NoError(t, extractArchive(t, &r.Reader, -1, crc32.NewIEEE(), reader, table.optimised))
+
+			stats := r.Stats()
+
+			if table.optimised {
+				assert.Equal(t, uint64(len(streams)), stats.PoolMisses)
+				assert.Equal(t, files-uint64(len(streams)), stats.PoolHits)
+				assert.Zero(t, stats.BytesSkipped)
+			} else {
+				assert.Equal(t, files, stats.PoolMisses)
+				assert.Zero(t, stats.PoolHits)
+				assert.Positive(t, stats.BytesSkipped)
+			}
+
+			assert.GreaterOrEqual(t, stats.BytesDecoded, size+stats.BytesSkipped)
+			assert.Zero(t, stats.CacheHits)
+			assert.Zero(t, stats.CacheMisses)
+		})
+	}
+}
+
+func TestArchiveStats(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		file  string
+		stats sevenzip.ArchiveStats
+	}{
+		{
+			file: "lzma1900.7z",
+			stats: sevenzip.ArchiveStats{
+				Entries:          633,
+				Size:             5019390,
+				PackedSize:       1071644,
+				Streams:          3,
+				Methods:          map[string]int{"BCJ2": 1, "LZMA": 3},
+				Solid:            true,
+				HeaderCompressed: true,
+			},
+		},
+		{
+			file: "t2.7z",
+			stats: sevenzip.ArchiveStats{
+				Entries:         2,
+				Size:            8,
+				PackedSize:      32,
+				Streams:         2,
+				Methods:         map[string]int{"AES": 2, "Copy": 2},
+				Encrypted:       true,
+				HeaderEncrypted: true,
+			},
+		},
+		{
+			file: "symlink.7z",
+			stats: sevenzip.ArchiveStats{
+				Entries:     5,
+				Directories: 1,
+				Symlinks:    3,
+				Size:        39,
+				PackedSize:  39,
+				Streams:     1,
+				Methods:     map[string]int{"Copy": 1},
+				Solid:       true,
+			},
+		},
+		{
+			file: "empty.7z",
+			stats: sevenzip.ArchiveStats{
+				Entries:          10,
+				Directories:      5,
+				Methods:          map[string]int{},
+				HeaderCompressed: true,
+			},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.file, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReaderWithPassword(filepath.Join("testdata", table.file), "password")
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			assert.Equal(t, table.stats, r.ArchiveStats())
+		})
+	}
+}
+
+func TestMaxSeekDistance(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name   string
+		opts   []sevenzip.ReaderOption
+		hits   uint64
+		misses uint64
+		err    error
+	}{
+		{
+			name:   "unlimited",
+			hits:   1,
+			misses: 1,
+		},
+		{
+			name:   "limited",
+			opts:   []sevenzip.ReaderOption{sevenzip.WithMaxSeekDistance(1)},
+			misses: 2,
+		},
+		{
+			name: "invalid",
+			opts: []sevenzip.ReaderOption{sevenzip.WithMaxSeekDistance(0)},
+			err:  sevenzip.ErrInvalidSeekDistance,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"), table.opts...)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			var files []*sevenzip.File
+
+			for _, f := range r.File {
+				if f.UncompressedSize > 1 && (len(files) == 0 || f.Stream == files[0].Stream) {
+					files = append(files, f)
+				}
+			}
+
+			require.GreaterOrEqual(t, len(files), 2)
+
+			// Read half of the first file so the second file is
+			// more than one byte away
+			rc, err := files[0].Open()
+			require.NoError(t, err)
+
+			_, err = io.CopyN(io.Discard, rc, int64(files[0].UncompressedSize/2)) //nolint:gosec
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			rc, err = files[1].Open()
+			require.NoError(t, err)
+			require.NoError(t, extractFile(t, rc, crc32.NewIEEE(), files[1]))
+			require.NoError(t, rc.Close())
+
+			stats := r.Stats()
+			assert.Equal(t, table.hits, stats.PoolHits)
+			assert.Equal(t, table.misses, stats.PoolMisses)
+		})
+	}
+}
+
+func TestOpenWait(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name       string
+		opts       []sevenzip.ReaderOption
+		concurrent bool
+		hits       uint64
+		misses     uint64
+		err        error
+	}{
+		{
+			name:   "no wait",
+			misses: 2,
+		},
+		{
+			name:       "wait",
+			opts:       []sevenzip.ReaderOption{sevenzip.WithOpenWait(time.Minute)},
+			concurrent: true,
+			hits:       1,
+			misses:     1,
+		},
+		{
+			name:   "timeout",
+			opts:   []sevenzip.ReaderOption{sevenzip.WithOpenWait(time.Millisecond)},
+			misses: 2,
+		},
+		{
+			name: "invalid",
+			opts: []sevenzip.ReaderOption{sevenzip.WithOpenWait(0)},
+			err:  sevenzip.ErrInvalidOpenWait,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"), table.opts...)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			var files []*sevenzip.File
+
+			for _, f := range r.File {
+				if f.UncompressedSize > 0 && (len(files) == 0 || f.Stream == files[0].Stream) {
+					files = append(files, f)
+				}
+			}
+
+			require.GreaterOrEqual(t, len(files), 2)
+
+			rc, err := files[0].Open()
+			require.NoError(t, err)
+			require.NoError(t, extractFile(t, rc, crc32.NewIEEE(), files[0]))
+
+			open := func() error {
+				rc, err := files[1].Open()
+				if err != nil {
+					return err
+				}
+
+				if err := extractFile(t, rc, crc32.NewIEEE(), files[1]); err != nil {
+					return err
+				}
+
+				return rc.Close()
+			}
+
+			if table.concurrent {
+				// The second file waits for the first to be closed
+				errs := make(chan error)
+
+				go func() {
+					errs <- open()
+				}()
+
+				require.NoError(t, rc.Close())
+				require.NoError(t, <-errs)
+			} else {
+				// The second file is opened while the first is still
+				// open so it has to be decoded from the beginning
+				require.NoError(t, open())
+				require.NoError(t, rc.Close())
+			}
+
+			stats := r.Stats()
+			assert.Equal(t, table.hits, stats.PoolHits)
+			assert.Equal(t, table.misses, stats.PoolMisses)
+		})
+	}
+}
+
+func TestChangeDetection(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "lzma.7z"))
+	require.NoError(t, err)
+
+	tables := []struct {
+		name string
+		file bool
+		opts []sevenzip.ReaderOption
+		err  error
+	}{
+		{
+			name: "file",
+			file: true,
+			opts: []sevenzip.ReaderOption{sevenzip.WithChangeDetection()},
+			err:  sevenzip.ErrArchiveChanged,
+		},
+		{
+			name: "file not detected",
+			file: true,
+		},
+		{
+			name: "reader",
+			opts: []sevenzip.ReaderOption{sevenzip.WithChangeDetection()},
+			err:  sevenzip.ErrArchiveChanged,
+		},
+		{
+			name: "reader not detected",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				r      *sevenzip.Reader
+				modify func()
+			)
+
+			if table.file {
+				name := filepath.Join(t.TempDir(), "lzma.7z")
+				require.NoError(t, os.WriteFile(name, b, 0o600))
+
+				rc, err := sevenzip.OpenReader(name, table.opts...)
+				require.NoError(t, err)
+
+				defer func() {
+					require.NoError(t, rc.Close())
+				}()
+
+				r = &rc.Reader
+				modify = func() {
+					mtime := time.Now().Add(time.Hour)
+					require.NoError(t, os.Chtimes(name, mtime, mtime))
+				}
+			} else {
+				c := bytes.Clone(b)
+
+				r, err = sevenzip.NewReader(bytes.NewReader(c), int64(len(c)), table.opts...)
+				require.NoError(t, err)
+
+				modify = func() {
+					// Change the offset of the header
+					c[12]++
+				}
+			}
+
+			f := r.File[0]
+			require.Positive(t, f.UncompressedSize)
+
+			rc, err := f.Open()
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, rc.Close())
+			}()
+
+			sr, err := r.OpenStream(f.Stream)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, sr.Close())
+			}()
+
+			modify()
+
+			// Reading the rest of the already open file or stream and
+			// opening either again all fail
+			for _, rc := range []io.Reader{rc, sr} {
+				_, err = io.Copy(io.Discard, rc)
+				if table.err != nil {
+					assert.ErrorIs(t, err, table.err)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+
+			sr2, err := r.OpenStream(f.Stream)
+			if table.err == nil {
+				require.NoError(t, err)
+				require.NoError(t, sr2.Close())
+			} else {
+				assert.ErrorIs(t, err, table.err)
+			}
+
+			rc2, err := f.Open()
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, rc2.Close())
+		})
+	}
+}
+
+func TestSanitizePath(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		want string
+		err  error
+	}{
+		{name: "file.txt", want: "file.txt"},
+		{name: "dir/file.txt", want: "dir/file.txt"},
+		{name: `dir\file.txt`, want: "dir/file.txt"},
+		{name: "dir/../file.txt", want: "file.txt"},
+		{name: "./dir//file.txt", want: "dir/file.txt"},
+		{name: "dir/", want: "dir"},
+		{name: "CONFIG.SYS", want: "CONFIG.SYS"},
+		{name: "", err: sevenzip.ErrInsecurePath},
+		{name: ".", err: sevenzip.ErrInsecurePath},
+		{name: "dir/..", err: sevenzip.ErrInsecurePath},
+		{name: "..", err: sevenzip.ErrInsecurePath},
+		{name: "../file.txt", err: sevenzip.ErrInsecurePath},
+		{name: "dir/../../file.txt", err: sevenzip.ErrInsecurePath},
+		{name: `..\file.txt`, err: sevenzip.ErrInsecurePath},
+		{name: "/etc/passwd", err: sevenzip.ErrInsecurePath},
+		{name: `\\server\share\file.txt`, err: sevenzip.ErrInsecurePath},
+		{name: `C:\Windows\file.txt`, err: sevenzip.ErrInsecurePath},
+		{name: "c:file.txt", err: sevenzip.ErrInsecurePath},
+		{name: "file.txt:stream", err: sevenzip.ErrInsecurePath},
+		{name: "file\x00.txt", err: sevenzip.ErrInsecurePath},
+		{name: "CON", err: sevenzip.ErrInsecurePath},
+		{name: "dir/nul.txt", err: sevenzip.ErrInsecurePath},
+		{name: "Com1.tar.gz", err: sevenzip.ErrInsecurePath},
+		{name: "lpt9 ", err: sevenzip.ErrInsecurePath},
+		{name: "dir./file.txt", err: sevenzip.ErrInsecurePath},
+		{name: "file.txt ", err: sevenzip.ErrInsecurePath},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := sevenzip.SanitizePath(table.name)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+				assert.Empty(t, got)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, table.want, got)
+		})
+	}
+}
+
+func TestMaxHeaderEntries(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		n    int
+		err  error
+	}{
+		{
+			name: "enough",
+			n:    100,
+		},
+		{
+			name: "too few",
+			n:    1,
+			err:  sevenzip.ErrTooManyEntries,
+		},
+		{
+			name: "invalid",
+			err:  sevenzip.ErrInvalidMaxEntries,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"), sevenzip.WithMaxHeaderEntries(table.n))
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotEmpty(t, r.File)
+			require.NoError(t, r.Close())
+		})
+	}
+}
+
+func ExampleOpenReader() {
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "multi.7z.001"))
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := r.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	for _, file := range r.File {
+		fmt.Println(file.Name)
+	}
+	// Output: 01
+	// 02
+	// 03
+	// 04
+	// 05
+	// 06
+	// 07
+	// 08
+	// 09
+	// 10
+}
+
+func benchmarkArchiveParallel(b *testing.B, file string) {
+	b.Helper()
+
+	for n := 0; n < b.N; n++ {
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", file))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var once sync.Once
+
+		f := func() {
+			if err := r.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		defer once.Do(f)
+
+		streams := make(map[int]struct{}, len(r.File))
+
+		for _, f := range r.File {
+			streams[f.Stream] = struct{}{}
+		}
+
+		eg := new(errgroup.Group)
+		eg.SetLimit(runtime.NumCPU())
+
+		for stream := range streams {
+			stream := stream
+
+			eg.Go(func() error {
+				return extractArchive(b, &r.Reader, stream, crc32.NewIEEE(), reader, true)
+			})
+		}
+
+		if err := eg.Wait(); err != nil {
+			b.Fatal(err)
+		}
+
+		once.Do(f)
+	}
+}
+
+func benchmarkArchiveNaiveParallel(b *testing.B, file string, workers int) {
+	b.Helper()
+
+	for n := 0; n < b.N; n++ {
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", file))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var once sync.Once
+
+		f := func() {
+			if err := r.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		defer once.Do(f)
+
+		eg := new(errgroup.Group)
+		eg.SetLimit(workers)
+
+		for _, f := range r.File {
+			f := f
 
 			eg.Go(func() (err error) {
 				var rc io.ReadCloser
@@ -657,3 +4387,273 @@ func BenchmarkARM(b *testing.B) {
 func BenchmarkSPARC(b *testing.B) {
 	benchmarkArchive(b, "sparc.7z", "", true)
 }
+
+func BenchmarkOpen(b *testing.B) {
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma.7z"), sevenzip.WithoutPooling())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		if err := r.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if err := rc.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+var errNoChtimes = errors.New("chtimes not supported")
+
+type noChtimesFs struct {
+	afero.Fs
+}
+
+func (*noChtimesFs) Chtimes(string, time.Time, time.Time) error {
+	return errNoChtimes
+}
+
+func TestExtractTo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("memory", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, r.ExtractTo(fsys, "out"))
+
+		for _, f := range r.File {
+			name := filepath.Join("out", filepath.FromSlash(f.Name))
+
+			fi, err := fsys.Stat(name)
+			require.NoError(t, err)
+			assert.Equal(t, f.Mode().IsDir(), fi.IsDir())
+
+			if fi.IsDir() {
+				continue
+			}
+
+			b, err := afero.ReadFile(fsys, name)
+			require.NoError(t, err)
+			assert.Equal(t, f.CRC32, crc32.ChecksumIEEE(b))
+			assert.True(t, f.Modified.Equal(fi.ModTime()))
+		}
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "symlink.7z"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		fsys := afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())
+		require.NoError(t, r.ExtractTo(fsys, "."))
+
+		for _, f := range r.File {
+			if !f.IsSymlink() {
+				continue
+			}
+
+			fi, _, err := fsys.(afero.Lstater).LstatIfPossible(f.Name) //nolint:forcetypeassert
+			require.NoError(t, err)
+			assert.Equal(t, fs.ModeSymlink, fi.Mode().Type())
+		}
+
+		assert.ErrorIs(t, r.ExtractTo(afero.NewMemMapFs(), "."), afero.ErrNoSymlink)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, r.ExtractToContext(ctx, afero.NewMemMapFs(), "out"), context.Canceled)
+	})
+
+	t.Run("times", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		assert.ErrorIs(t, r.ExtractTo(&noChtimesFs{afero.NewMemMapFs()}, "out"), errNoChtimes)
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := sevenzip.OpenReader(filepath.Join("testdata", "lzma1900.7z"))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		// Only the last entry is unsafe, none of the earlier ones should
+		// be written either
+		r.File[len(r.File)-1].Name = "../escape"
+
+		fsys := afero.NewMemMapFs()
+		require.ErrorIs(t, r.ExtractTo(fsys, "out"), sevenzip.ErrInsecurePath)
+
+		_, err = fsys.Stat("escape")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = fsys.Stat("out")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+func TestFileByIndex(t *testing.T) {
+	t.Parallel()
+
+	r, err := sevenzip.OpenReader(filepath.Join("testdata", "duplicate.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	for i, f := range r.File {
+		assert.Equal(t, i, f.Index)
+		assert.Same(t, f, r.FileByIndex(i))
+	}
+
+	assert.Nil(t, r.FileByIndex(-1))
+	assert.Nil(t, r.FileByIndex(len(r.File)))
+
+	c, err := r.Clone()
+	require.NoError(t, err)
+
+	for i, f := range c.File {
+		assert.Equal(t, i, f.Index)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file string
+		encoded    bool
+	}{
+		{
+			name: "plain",
+			file: "bcj.7z",
+		},
+		{
+			name: "duplicates",
+			file: "duplicate.7z",
+		},
+		{
+			name:    "encoded",
+			file:    "lzma1900.7z",
+			encoded: true,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := os.ReadFile(filepath.Join("testdata", table.file))
+			require.NoError(t, err)
+
+			// The signature header holds the offset and size of the header
+			offset := 32 + binary.LittleEndian.Uint64(b[12:])
+			size := binary.LittleEndian.Uint64(b[20:])
+
+			h, err := sevenzip.ParseHeader(bytes.NewReader(b[offset : offset+size]))
+			require.NoError(t, err)
+			assert.Equal(t, table.encoded, h.Encoded)
+			require.NotNil(t, h.Streams)
+			require.NotEmpty(t, h.Streams.Folders)
+
+			for _, f := range h.Streams.Folders {
+				assert.NotEmpty(t, f.Coders)
+				assert.Len(t, f.UnpackSizes, len(f.Coders))
+			}
+
+			if table.encoded {
+				assert.Empty(t, h.Files)
+
+				return
+			}
+
+			r, err := sevenzip.OpenReader(filepath.Join("testdata", table.file))
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			require.Len(t, h.Files, len(r.File))
+
+			for i, f := range r.File {
+				assert.Equal(t, i, h.Files[i].Index)
+				assert.Equal(t, f.Stream, h.Files[i].Stream)
+				assert.Equal(t, f.CRC32, h.Files[i].CRC32)
+				assert.Equal(t, f.UncompressedSize, h.Files[i].UncompressedSize)
+				assert.Equal(t, f.Modified, h.Files[i].Modified)
+				assert.Len(t, h.Files[i].RawName, 2*len(strings.TrimSuffix(f.Name, "/")))
+			}
+
+			var sizes uint64
+			for _, f := range h.Streams.Folders {
+				for _, size := range f.SubStreamSizes {
+					sizes += size
+				}
+			}
+
+			assert.Equal(t, r.Listing().Size, sizes)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := sevenzip.ParseHeader(bytes.NewReader([]byte{0x02}))
+		require.ErrorIs(t, err, sevenzip.ErrUnexpectedID)
+
+		_, err = sevenzip.ParseHeader(bytes.NewReader(nil))
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
@@ -2,6 +2,7 @@ package sevenzip
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 
@@ -15,20 +16,81 @@ import (
 	"github.com/bodgit/sevenzip/internal/lz4"
 	"github.com/bodgit/sevenzip/internal/lzma"
 	"github.com/bodgit/sevenzip/internal/lzma2"
+	"github.com/bodgit/sevenzip/internal/ppmd"
+	"github.com/bodgit/sevenzip/internal/xz"
 	"github.com/bodgit/sevenzip/internal/zstd"
 )
 
+// Method IDs of the methods known to this package, for use with
+// [RegisterDecompressor], [ReplaceDecompressor], [WithDecompressors] and
+// [MethodName]. MethodLZ5 and MethodLizard, used by 7-Zip ZS, are only named
+// and have no decompressor registered, so reading them fails with an
+// [UnsupportedMethodError] unless one is added. The Fast LZMA2 codec in
+// 7-Zip ZS has no method ID of its own, it writes standard LZMA2 streams
+// and properties as MethodLZMA2, so they're read by the LZMA2 decompressor.
+const (
+	MethodCopy         = "\x00"
+	MethodDelta        = "\x03"
+	MethodLZMA         = "\x03\x01\x01"
+	MethodBCJ          = "\x03\x03\x01\x03"
+	MethodBCJ2         = "\x03\x03\x01\x1b"
+	MethodPPC          = "\x03\x03\x02\x05"
+	MethodIA64         = "\x03\x03\x04\x01"
+	MethodARM          = "\x03\x03\x05\x01"
+	MethodARMT         = "\x03\x03\x07\x01"
+	MethodSPARC        = "\x03\x03\x08\x05"
+	MethodPPMd         = "\x03\x04\x01"
+	MethodDeflate      = "\x04\x01\x08"
+	MethodXZ           = "\x04\x01\x5f"
+	MethodBzip2        = "\x04\x02\x02"
+	MethodZstd         = "\x04\xf7\x11\x01"
+	MethodBrotli       = "\x04\xf7\x11\x02"
+	MethodLZ4          = "\x04\xf7\x11\x04"
+	MethodLZ5          = "\x04\xf7\x11\x05"
+	MethodLizard       = "\x04\xf7\x11\x06"
+	MethodAES256SHA256 = "\x06\xf1\x07\x01"
+	MethodARM64        = "\x0a"
+	MethodRISCV        = "\x0b"
+	MethodLZMA2        = "\x21"
+)
+
 // Decompressor describes the function signature that decompression/decryption
 // methods must implement to return a new instance of themselves. They are
 // passed any property bytes, the size of the stream and a slice of at least
 // one io.ReadCloser's providing the stream(s) of bytes.
 type Decompressor func([]byte, uint64, []io.ReadCloser) (io.ReadCloser, error)
 
+// Resetter is optionally implemented by the io.ReadCloser returned by a
+// [Decompressor]. Once it has been closed, it's pooled and Reset is called to
+// reuse it in place of calling the [Decompressor] again with the same
+// arguments, which saves reallocating any large buffers. As Reset may have
+// read from the readers before failing, an error from it is returned when
+// opening the stream rather than calling the [Decompressor] instead. Only
+// decompressors registered with [RegisterDecompressor] or
+// [ReplaceDecompressor] are pooled. Of the built-in methods, Deflate, PPMd,
+// Zstandard, Brotli and LZ4 implement it, the LZMA, LZMA2, Bzip2 and XZ
+// decoders this package uses can't be reset so they aren't pooled.
+type Resetter interface {
+	Reset(props []byte, size uint64, readers []io.ReadCloser) error
+}
+
 var (
 	//nolint:gochecknoglobals
 	decompressors sync.Map
 
+	// replaced holds the method IDs passed to ReplaceDecompressor.
+	//
+	//nolint:gochecknoglobals
+	replaced sync.Map
+
+	// resetPools holds a *sync.Pool of closed Resetter's for each method
+	// ID.
+	//
+	//nolint:gochecknoglobals
+	resetPools sync.Map
+
 	errNeedOneReader = errors.New("copy: need exactly one reader")
+	errReaderClosed  = errors.New("sevenzip: reader already closed")
 )
 
 func newCopyReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
@@ -41,36 +103,27 @@ func newCopyReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser,
 
 //nolint:gochecknoinits
 func init() {
-	// Copy
-	RegisterDecompressor([]byte{0x00}, Decompressor(newCopyReader))
-	// Delta
-	RegisterDecompressor([]byte{0x03}, Decompressor(delta.NewReader))
-	// LZMA
-	RegisterDecompressor([]byte{0x03, 0x01, 0x01}, Decompressor(lzma.NewReader))
-	// BCJ
-	RegisterDecompressor([]byte{0x03, 0x03, 0x01, 0x03}, Decompressor(bra.NewBCJReader))
-	// BCJ2
-	RegisterDecompressor([]byte{0x03, 0x03, 0x01, 0x1b}, Decompressor(bcj2.NewReader))
-	// PPC
-	RegisterDecompressor([]byte{0x03, 0x03, 0x02, 0x05}, Decompressor(bra.NewPPCReader))
-	// ARM
-	RegisterDecompressor([]byte{0x03, 0x03, 0x05, 0x01}, Decompressor(bra.NewARMReader))
-	// SPARC
-	RegisterDecompressor([]byte{0x03, 0x03, 0x08, 0x05}, Decompressor(bra.NewSPARCReader))
-	// Deflate
-	RegisterDecompressor([]byte{0x04, 0x01, 0x08}, Decompressor(deflate.NewReader))
-	// Bzip2
-	RegisterDecompressor([]byte{0x04, 0x02, 0x02}, Decompressor(bzip2.NewReader))
-	// Zstandard
-	RegisterDecompressor([]byte{0x04, 0xf7, 0x11, 0x01}, Decompressor(zstd.NewReader))
-	// Brotli
-	RegisterDecompressor([]byte{0x04, 0xf7, 0x11, 0x02}, Decompressor(brotli.NewReader))
-	// LZ4
-	RegisterDecompressor([]byte{0x04, 0xf7, 0x11, 0x04}, Decompressor(lz4.NewReader))
-	// AES-CBC-256 & SHA-256
-	RegisterDecompressor([]byte{0x06, 0xf1, 0x07, 0x01}, Decompressor(aes7z.NewReader))
-	// LZMA2
-	RegisterDecompressor([]byte{0x21}, Decompressor(lzma2.NewReader))
+	RegisterDecompressor([]byte(MethodCopy), Decompressor(newCopyReader))
+	RegisterDecompressor([]byte(MethodDelta), Decompressor(delta.NewReader))
+	RegisterDecompressor([]byte(MethodLZMA), Decompressor(lzma.NewReader))
+	RegisterDecompressor([]byte(MethodBCJ), Decompressor(bra.NewBCJReader))
+	RegisterDecompressor([]byte(MethodBCJ2), Decompressor(bcj2.NewReader))
+	RegisterDecompressor([]byte(MethodPPC), Decompressor(bra.NewPPCReader))
+	RegisterDecompressor([]byte(MethodIA64), Decompressor(bra.NewIA64Reader))
+	RegisterDecompressor([]byte(MethodARM), Decompressor(bra.NewARMReader))
+	RegisterDecompressor([]byte(MethodARMT), Decompressor(bra.NewARMTReader))
+	RegisterDecompressor([]byte(MethodSPARC), Decompressor(bra.NewSPARCReader))
+	RegisterDecompressor([]byte(MethodPPMd), Decompressor(ppmd.NewReader))
+	RegisterDecompressor([]byte(MethodDeflate), Decompressor(deflate.NewReader))
+	RegisterDecompressor([]byte(MethodXZ), Decompressor(xz.NewReader))
+	RegisterDecompressor([]byte(MethodBzip2), Decompressor(bzip2.NewReader))
+	RegisterDecompressor([]byte(MethodZstd), Decompressor(zstd.NewReader))
+	RegisterDecompressor([]byte(MethodBrotli), Decompressor(brotli.NewReader))
+	RegisterDecompressor([]byte(MethodLZ4), Decompressor(lz4.NewReader))
+	RegisterDecompressor([]byte(MethodAES256SHA256), Decompressor(aes7z.NewReader))
+	RegisterDecompressor([]byte(MethodARM64), Decompressor(bra.NewARM64Reader))
+	RegisterDecompressor([]byte(MethodRISCV), Decompressor(bra.NewRISCVReader))
+	RegisterDecompressor([]byte(MethodLZMA2), Decompressor(lzma2.NewReader))
 }
 
 // RegisterDecompressor allows custom decompressors for a specified method ID.
@@ -80,6 +133,70 @@ func RegisterDecompressor(method []byte, dcomp Decompressor) {
 	}
 }
 
+// ReplaceDecompressor sets the decompressor for a specified method ID,
+// replacing any existing one rather than panicking like
+// [RegisterDecompressor]. This allows an alternative implementation of a
+// built-in method to be used instead. A nil [Decompressor] unregisters the
+// method. Unlike the built-in decompressors, a replacement isn't configured
+// by options such as [WithMaxDictionarySize] that it can't know about.
+func ReplaceDecompressor(method []byte, dcomp Decompressor) {
+	replaced.Store(string(method), struct{}{})
+	resetPools.Delete(string(method))
+
+	if dcomp == nil {
+		decompressors.Delete(string(method))
+
+		return
+	}
+
+	decompressors.Store(string(method), dcomp)
+}
+
+// isReplaced reports whether the method has been passed to
+// ReplaceDecompressor.
+func isReplaced(method string) bool {
+	_, ok := replaced.Load(method)
+
+	return ok
+}
+
+//nolint:gochecknoglobals
+var methodNames = map[string]string{
+	MethodCopy:         "Copy",
+	MethodDelta:        "Delta",
+	MethodLZMA:         "LZMA",
+	MethodBCJ:          "BCJ",
+	MethodBCJ2:         "BCJ2",
+	MethodPPC:          "PPC",
+	MethodIA64:         "IA64",
+	MethodARM:          "ARM",
+	MethodARMT:         "ARMT",
+	MethodSPARC:        "SPARC",
+	MethodPPMd:         "PPMd",
+	MethodDeflate:      "Deflate",
+	MethodXZ:           "XZ",
+	MethodBzip2:        "Bzip2",
+	MethodZstd:         "Zstandard",
+	MethodBrotli:       "Brotli",
+	MethodLZ4:          "LZ4",
+	MethodLZ5:          "LZ5",
+	MethodLizard:       "Lizard",
+	MethodAES256SHA256: "AES",
+	MethodARM64:        "ARM64",
+	MethodRISCV:        "RISCV",
+	MethodLZMA2:        "LZMA2",
+}
+
+// MethodName returns a human-readable name for the method ID, falling back
+// to the hex-encoded ID for unknown or custom methods.
+func MethodName(method []byte) string {
+	if name, ok := methodNames[string(method)]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%x", method)
+}
+
 func decompressor(method []byte) Decompressor {
 	di, ok := decompressors.Load(string(method))
 	if !ok {
@@ -92,3 +209,72 @@ func decompressor(method []byte) Decompressor {
 
 	return nil
 }
+
+func resetPool(method string) *sync.Pool {
+	pi, ok := resetPools.Load(method)
+	if !ok {
+		pi, _ = resetPools.LoadOrStore(method, new(sync.Pool))
+	}
+
+	p, ok := pi.(*sync.Pool)
+	if !ok {
+		panic("reset pool has wrong type")
+	}
+
+	return p
+}
+
+// resetReadCloser returns the wrapped io.ReadCloser to the pool it came
+// from once closed, unless the method has since been replaced with
+// [ReplaceDecompressor] which discards that pool.
+type resetReadCloser struct {
+	rc     io.ReadCloser
+	method string
+	pool   *sync.Pool
+}
+
+func (rc *resetReadCloser) Read(p []byte) (int, error) {
+	if rc.rc == nil {
+		return 0, errReaderClosed
+	}
+
+	return rc.rc.Read(p) //nolint:wrapcheck
+}
+
+func (rc *resetReadCloser) Close() error {
+	if rc.rc == nil {
+		return errReaderClosed
+	}
+
+	err := rc.rc.Close()
+	if pi, ok := resetPools.Load(rc.method); err == nil && ok && pi == any(rc.pool) {
+		rc.pool.Put(rc.rc)
+	}
+
+	rc.rc = nil
+
+	return err //nolint:wrapcheck
+}
+
+// resetReader returns a Resetter from pool that has been reset with the
+// arguments, or nil if there isn't one. An error resetting it is returned
+// as the readers may have been read from.
+//
+//nolint:nilnil
+func resetReader(pool *sync.Pool, p []byte, s uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	r, ok := pool.Get().(Resetter)
+	if !ok {
+		return nil, nil
+	}
+
+	rc, ok := r.(io.ReadCloser)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := r.Reset(p, s, readers); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return rc, nil
+}
@@ -0,0 +1,6 @@
+package remote
+
+var (
+	ErrChanged           = errChanged
+	ErrRangeNotSupported = errRangeNotSupported
+)
@@ -0,0 +1,256 @@
+// Package remote provides an io.ReaderAt over a file served by an HTTP(S)
+// server, using Range requests to fetch only the parts that are read. As a
+// 7-zip archive is read by seeking to its header and then to the start of
+// each stream, opening a large remote archive and reading a few files from
+// it downloads only a small fraction of it.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bodgit/sevenzip/internal/cache"
+)
+
+const (
+	defaultBlockSize = 1 << 20
+	defaultBlocks    = 64
+)
+
+var (
+	errInvalidBlockSize    = errors.New("remote: block size must be positive")
+	errInvalidBlocks       = errors.New("remote: number of cached blocks must be positive")
+	errNilClient           = errors.New("remote: client cannot be nil")
+	errRangeNotSupported   = errors.New("remote: server does not support range requests")
+	errInvalidContentRange = errors.New("remote: invalid Content-Range")
+	errChanged             = errors.New("remote: file changed on server")
+	errUnexpectedStatus    = errors.New("remote: unexpected status")
+	errNegativeOffset      = errors.New("remote: negative offset")
+)
+
+type readerAtFunc func([]byte, int64) (int, error)
+
+func (f readerAtFunc) ReadAt(p []byte, off int64) (int, error) { return f(p, off) }
+
+// A ReaderAt reads a remote file with HTTP Range requests. The file is read
+// in blocks, the most recently used of which are cached. It is safe for
+// concurrent use.
+type ReaderAt struct {
+	ctx       context.Context //nolint:containedctx
+	client    *http.Client
+	url       string
+	size      int64
+	etag      string
+	blockSize int64
+	blocks    int
+	cache     *cache.ReaderAt
+}
+
+// An Option configures a [ReaderAt].
+type Option func(*ReaderAt) error
+
+// WithClient sets the [http.Client] used to make requests, the default is
+// [http.DefaultClient].
+func WithClient(client *http.Client) Option {
+	return func(r *ReaderAt) error {
+		if client == nil {
+			return errNilClient
+		}
+
+		r.client = client
+
+		return nil
+	}
+}
+
+// WithBlockSize sets the size of each block requested from the server, the
+// default is 1 MiB. Larger blocks mean fewer requests but more data read
+// that may not be needed.
+func WithBlockSize(n int) Option {
+	return func(r *ReaderAt) error {
+		if n <= 0 {
+			return errInvalidBlockSize
+		}
+
+		r.blockSize = int64(n)
+
+		return nil
+	}
+}
+
+// WithCacheBlocks sets how many blocks are cached, the default is 64.
+func WithCacheBlocks(n int) Option {
+	return func(r *ReaderAt) error {
+		if n <= 0 {
+			return errInvalidBlocks
+		}
+
+		r.blocks = n
+
+		return nil
+	}
+}
+
+// NewReaderAt returns a [ReaderAt] for the file at url. A request is made to
+// find the size of the file and to check that the server supports Range
+// requests. The context is used for every request.
+func NewReaderAt(ctx context.Context, url string, opts ...Option) (*ReaderAt, error) {
+	r := &ReaderAt{
+		ctx:       ctx,
+		client:    http.DefaultClient,
+		url:       url,
+		blockSize: defaultBlockSize,
+		blocks:    defaultBlocks,
+	}
+
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := r.get(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if _, _, r.size, err = parseContentRange(resp.Header.Get("Content-Range")); err != nil {
+		return nil, err
+	}
+
+	if etag := resp.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+		r.etag = etag
+	}
+
+	if r.cache, err = cache.NewReaderAt(readerAtFunc(r.readAt), int(r.blockSize), r.blocks); err != nil {
+		return nil, fmt.Errorf("remote: error creating cache: %w", err)
+	}
+
+	return r, nil
+}
+
+// Size returns the size of the remote file.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// get requests the bytes from start to end inclusive.
+func (r *ReaderAt) get(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("remote: error creating request: %w", err)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	if r.etag != "" {
+		req.Header.Set("If-Range", r.etag)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote: error requesting: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusOK && r.etag != "":
+			// The If-Range validator didn't match
+			return nil, errChanged
+		case resp.StatusCode == http.StatusOK:
+			return nil, errRangeNotSupported
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnexpectedStatus, resp.Status)
+		}
+	}
+
+	return resp, nil
+}
+
+// parseContentRange parses a header of the form "bytes start-end/size".
+func parseContentRange(s string) (start, end, size int64, err error) {
+	s, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, 0, errInvalidContentRange
+	}
+
+	rng, total, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, 0, errInvalidContentRange
+	}
+
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, errInvalidContentRange
+	}
+
+	for _, x := range []struct {
+		s string
+		v *int64
+	}{{first, &start}, {last, &end}, {total, &size}} {
+		if *x.v, err = strconv.ParseInt(x.s, 10, 64); err != nil || *x.v < 0 {
+			return 0, 0, 0, errInvalidContentRange
+		}
+	}
+
+	if start > end || end >= size {
+		return 0, 0, 0, errInvalidContentRange
+	}
+
+	return start, end, size, nil
+}
+
+// readAt reads p from the remote file at off with a single request, which
+// the block cache uses to read consecutive blocks.
+func (r *ReaderAt) readAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	n := len(p)
+	if int64(n) > r.size-off {
+		p = p[:r.size-off]
+	}
+
+	start, end := off, off+int64(len(p))-1
+
+	resp, err := r.get(start, end)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if s, e, size, err := parseContentRange(resp.Header.Get("Content-Range")); err != nil {
+		return 0, err
+	} else if s != start || e != end || size != r.size {
+		return 0, errChanged
+	}
+
+	read, err := io.ReadFull(resp.Body, p)
+	if err != nil {
+		return read, fmt.Errorf("remote: error reading: %w", err)
+	}
+
+	if read < n {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// ReadAt implements the [io.ReaderAt] interface.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	return r.cache.ReadAt(p, off) //nolint:wrapcheck
+}
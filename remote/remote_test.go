@@ -0,0 +1,152 @@
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/bodgit/sevenzip/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+
+	return w.ResponseWriter.Write(p) //nolint:wrapcheck
+}
+
+// newServer serves testdata, counting the requests made and the bytes
+// returned.
+func newServer(t *testing.T) (*httptest.Server, *atomic.Int64, *atomic.Int64) {
+	t.Helper()
+
+	var requests, transferred atomic.Int64
+
+	fs := http.FileServer(http.Dir(filepath.Join("..", "testdata")))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fs.ServeHTTP(countingWriter{w, &transferred}, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s, &requests, &transferred
+}
+
+func TestReadAt(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := newServer(t)
+
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "lzma.7z"))
+	require.NoError(t, err)
+
+	r, err := remote.NewReaderAt(context.Background(), s.URL+"/lzma.7z", remote.WithBlockSize(1000), remote.WithCacheBlocks(4))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(b)), r.Size())
+
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+
+	for i := 0; i < 100; i++ {
+		off := rnd.Int63n(int64(len(b)))
+		p := make([]byte, rnd.Intn(3000))
+
+		n, err := r.ReadAt(p, off)
+		if off+int64(len(p)) > int64(len(b)) {
+			assert.ErrorIs(t, err, io.EOF)
+		} else {
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, b[off:off+int64(n)], p[:n])
+	}
+
+	_, err = r.ReadAt(make([]byte, 1), int64(len(b)))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+
+	s, requests, transferred := newServer(t)
+
+	r, err := remote.NewReaderAt(context.Background(), s.URL+"/lzma1900.7z", remote.WithBlockSize(4096))
+	require.NoError(t, err)
+
+	z, err := sevenzip.NewReader(r, r.Size())
+	require.NoError(t, err)
+
+	// Only the start and the header at the end of the archive are needed
+	assert.Less(t, transferred.Load(), r.Size()/10)
+
+	for _, f := range z.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		_, err = io.Copy(io.Discard, rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+	}
+
+	// Very little should be downloaded more than once
+	assert.Less(t, transferred.Load(), r.Size()+r.Size()/10)
+	assert.Less(t, requests.Load(), (r.Size()/4096)+(r.Size()/4096)/10)
+}
+
+func TestNoRangeSupport(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("no ranges here"))
+	}))
+	t.Cleanup(s.Close)
+
+	_, err := remote.NewReaderAt(context.Background(), s.URL)
+	assert.ErrorIs(t, err, remote.ErrRangeNotSupported)
+}
+
+func TestChanged(t *testing.T) {
+	t.Parallel()
+
+	var etag atomic.Value
+
+	etag.Store(`"1"`)
+
+	b := bytes.Repeat([]byte("0123456789"), 1000)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag.Load().(string)) //nolint:forcetypeassert
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(b))
+	}))
+	t.Cleanup(s.Close)
+
+	r, err := remote.NewReaderAt(context.Background(), s.URL, remote.WithBlockSize(1000))
+	require.NoError(t, err)
+
+	p := make([]byte, 10)
+
+	_, err = r.ReadAt(p, 0)
+	require.NoError(t, err)
+
+	etag.Store(`"2"`)
+
+	// Still cached
+	_, err = r.ReadAt(p, 0)
+	require.NoError(t, err)
+
+	_, err = r.ReadAt(p, 5000)
+	assert.ErrorIs(t, err, remote.ErrChanged)
+}
@@ -0,0 +1,107 @@
+package sevenzip
+
+import (
+	"context"
+	"errors"
+)
+
+// Outcome describes what happened to a file in a [Report].
+type Outcome int
+
+const (
+	// OutcomeOK means the file was processed successfully.
+	OutcomeOK Outcome = iota
+	// OutcomeSkipped means the file wasn't processed because the context
+	// was cancelled first.
+	OutcomeSkipped
+	// OutcomeChecksum means the file didn't match its CRC32.
+	OutcomeChecksum
+	// OutcomeUnsupported means the file uses a method with no registered
+	// decompressor.
+	OutcomeUnsupported
+	// OutcomeWrongPassword means the file couldn't be decrypted, either
+	// because the password is wrong or none was given.
+	OutcomeWrongPassword
+	// OutcomeError means the file failed for any other reason.
+	OutcomeError
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeOK:
+		return "ok"
+	case OutcomeSkipped:
+		return "skipped"
+	case OutcomeChecksum:
+		return "checksum error"
+	case OutcomeUnsupported:
+		return "unsupported method"
+	case OutcomeWrongPassword:
+		return "wrong password"
+	case OutcomeError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// FileResult is the outcome of processing a single file.
+type FileResult struct {
+	File    *File
+	Outcome Outcome
+	// Err is the error returned while processing the file, which is nil
+	// for [OutcomeOK].
+	Err error
+}
+
+// Report lists the outcome for every file in an archive, as returned by
+// [Reader.ExtractAll] and [Reader.VerifyAll].
+type Report struct {
+	// Files is in the same order as [Reader.File].
+	Files []FileResult
+}
+
+// Failed returns the results for the files that weren't processed
+// successfully, including any that were skipped.
+func (r *Report) Failed() []FileResult {
+	var failed []FileResult
+
+	for _, fr := range r.Files {
+		if fr.Outcome != OutcomeOK {
+			failed = append(failed, fr)
+		}
+	}
+
+	return failed
+}
+
+// Err returns the errors for the files that failed joined together, or nil
+// if every file was processed successfully.
+func (r *Report) Err() error {
+	errs := make([]error, 0, len(r.Files))
+
+	for _, fr := range r.Files {
+		errs = append(errs, fr.Err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func classify(err error) Outcome {
+	var ume *UnsupportedMethodError
+
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, ErrWrongPassword), errors.Is(err, ErrPasswordRequired):
+		return OutcomeWrongPassword
+	case errors.As(err, &ume):
+		return OutcomeUnsupported
+	case errors.Is(err, errChecksum):
+		return OutcomeChecksum
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeSkipped
+	default:
+		return OutcomeError
+	}
+}
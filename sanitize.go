@@ -0,0 +1,68 @@
+package sevenzip
+
+import (
+	"errors"
+	iofs "io/fs"
+	"path"
+	"strings"
+)
+
+// ErrInsecurePath is returned by [SanitizePath] for a name that isn't safe
+// to extract to.
+var ErrInsecurePath = errors.New("sevenzip: insecure file path")
+
+// reservedNames are the device names Windows reserves in every directory,
+// with or without an extension.
+//
+//nolint:gochecknoglobals
+var reservedNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {},
+	"COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {},
+	"LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// SanitizePath returns name, such as [FileHeader.Name], as a clean relative
+// path using forward slashes that is safe to join to a destination
+// directory when extracting, after converting it with
+// [path/filepath.FromSlash]. Like [FileHeader.CleanName] any backslashes are
+// treated as separators, but rather than quietly removing anything unsafe,
+// an error wrapping [ErrInsecurePath] is returned if name:
+//
+//   - is empty or refers to the destination directory itself
+//   - is absolute, including a Windows drive letter or UNC path
+//   - escapes the destination directory with ".." elements
+//   - contains a NUL byte or a colon, which Windows uses for drive letters
+//     and alternate data streams
+//   - has an element that Windows reserves for a device, such as "CON" or
+//     "com1.txt", or that ends with a dot or space, which Windows ignores
+func SanitizePath(name string) (string, error) {
+	insecure := func() (string, error) {
+		return "", &iofs.PathError{Op: "sanitize", Path: name, Err: ErrInsecurePath}
+	}
+
+	p := strings.ReplaceAll(name, `\`, `/`)
+
+	if p == "" || strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\x00:") {
+		return insecure()
+	}
+
+	p = path.Clean(p)
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return insecure()
+	}
+
+	for _, elem := range strings.Split(p, "/") {
+		if strings.HasSuffix(elem, ".") || strings.HasSuffix(elem, " ") {
+			return insecure()
+		}
+
+		base, _, _ := strings.Cut(elem, ".")
+		if _, ok := reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]; ok {
+			return insecure()
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,123 @@
+package sevenzip
+
+import "sync/atomic"
+
+// Stats holds counters describing how a [Reader] has read its streams, which
+// can be used to check whether the access pattern is making good use of the
+// pool of partially-read streams and the cache enabled with [WithCache].
+type Stats struct {
+	// PoolHits is the number of times a file was opened by reusing a
+	// partially-read stream from the pool.
+	PoolHits uint64
+	// PoolMisses is the number of times a file was opened by decoding its
+	// stream from the beginning.
+	PoolMisses uint64
+	// CacheHits is the number of times a file was opened from a stream
+	// already in the cache.
+	CacheHits uint64
+	// CacheMisses is the number of times a stream had to be decoded to
+	// add it to the cache.
+	CacheMisses uint64
+	// BytesDecoded is the total number of bytes decoded from all streams,
+	// including the archive header.
+	BytesDecoded uint64
+	// BytesSkipped is the number of decoded bytes that were discarded to
+	// seek forward to the start of a file.
+	BytesSkipped uint64
+}
+
+type readerStats struct {
+	poolHits, poolMisses   atomic.Uint64
+	cacheHits, cacheMisses atomic.Uint64
+	decoded, skipped       atomic.Uint64
+}
+
+// Stats returns a snapshot of the counters describing how the [Reader] has
+// read its streams so far.
+func (z *Reader) Stats() Stats {
+	return Stats{
+		PoolHits:     z.stats.poolHits.Load(),
+		PoolMisses:   z.stats.poolMisses.Load(),
+		CacheHits:    z.stats.cacheHits.Load(),
+		CacheMisses:  z.stats.cacheMisses.Load(),
+		BytesDecoded: z.stats.decoded.Load(),
+		BytesSkipped: z.stats.skipped.Load(),
+	}
+}
+
+// ArchiveStats summarises the contents of an archive.
+type ArchiveStats struct {
+	// Entries is the total number of entries in the archive.
+	Entries int
+	// Directories is the number of entries that are directories.
+	Directories int
+	// Symlinks is the number of entries that are symbolic links.
+	Symlinks int
+	// Size is the total uncompressed size of the entries.
+	Size uint64
+	// PackedSize is the total compressed size of the streams.
+	PackedSize uint64
+	// Streams is the number of compressed streams, sometimes referred to
+	// as solid blocks.
+	Streams int
+	// Methods counts the streams using each method, keyed by the name
+	// returned by [MethodName].
+	Methods map[string]int
+	// Encrypted reports whether any stream or the header is encrypted.
+	Encrypted bool
+	// Solid reports whether any stream contains more than one file.
+	Solid bool
+	// HeaderCompressed reports whether the header is compressed.
+	HeaderCompressed bool
+	// HeaderEncrypted reports whether the header is encrypted, which
+	// hides the names of the entries without the password.
+	HeaderEncrypted bool
+}
+
+// ArchiveStats returns an [ArchiveStats] summarising the contents of the
+// archive, as needed for policy checks or to display a summary. Unlike
+// [Reader.Stats] it describes the archive rather than how it has been read.
+func (z *Reader) ArchiveStats() ArchiveStats {
+	s := ArchiveStats{
+		Entries:          len(z.File),
+		Streams:          z.si.Folders(),
+		Methods:          make(map[string]int),
+		Encrypted:        z.Encrypted(),
+		HeaderCompressed: z.headerCompressed,
+		HeaderEncrypted:  z.headerEncrypted,
+	}
+
+	for i := 0; i < s.Streams; i++ {
+		s.PackedSize += z.si.folderPackedSize(i)
+
+		seen := make(map[string]bool)
+
+		for _, c := range z.si.unpackInfo.folder[i].coder {
+			if name := MethodName(c.id); !seen[name] {
+				seen[name] = true
+				s.Methods[name]++
+			}
+		}
+	}
+
+	files := make(map[int]int, s.Streams)
+
+	for _, f := range z.File {
+		switch {
+		case f.Mode().IsDir():
+			s.Directories++
+		case f.IsSymlink():
+			s.Symlinks++
+		}
+
+		s.Size += f.UncompressedSize
+
+		if !f.isEmptyStream && !f.isEmptyFile {
+			if files[f.folder]++; files[f.folder] > 1 {
+				s.Solid = true
+			}
+		}
+	}
+
+	return s
+}
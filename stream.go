@@ -0,0 +1,152 @@
+package sevenzip
+
+import (
+	"errors"
+	"io"
+
+	"github.com/bodgit/sevenzip/internal/util"
+)
+
+var errNoSuchStream = errors.New("sevenzip: no such stream")
+
+// StreamInfo describes a compressed stream within the archive, sometimes
+// referred to as a solid block or folder, which contains one or more files.
+type StreamInfo struct {
+	// Stream is the identifier of the stream, matching the value of
+	// [FileHeader.Stream] for each file it contains.
+	Stream int
+
+	// PackedSize is the number of compressed bytes used to store the
+	// stream.
+	PackedSize uint64
+
+	// UncompressedSize is the size of the stream once decompressed.
+	UncompressedSize uint64
+
+	// CRC32 is the checksum of the decompressed stream, or zero if the
+	// archive doesn't store one.
+	CRC32 uint32
+
+	// Methods lists the name of each method used to decompress the
+	// stream, as returned by [MethodName], in the order that the archive
+	// stores them.
+	Methods []string
+
+	// Files lists the files within the stream in the order that they are
+	// stored.
+	Files []*File
+}
+
+// Streams returns information about each compressed stream in the archive.
+func (z *Reader) Streams() []StreamInfo {
+	streams := make([]StreamInfo, z.si.Folders())
+
+	for i := range streams {
+		streams[i].Stream = i
+		streams[i].PackedSize = z.si.folderPackedSize(i)
+		streams[i].UncompressedSize = z.si.unpackInfo.folder[i].unpackSize()
+
+		for _, c := range z.si.unpackInfo.folder[i].coder {
+			streams[i].Methods = append(streams[i].Methods, MethodName(c.id))
+		}
+
+		if z.si.unpackInfo.digest != nil {
+			streams[i].CRC32 = z.si.unpackInfo.digest[i]
+		}
+	}
+
+	for _, f := range z.File {
+		if f.isEmptyStream || f.isEmptyFile {
+			continue
+		}
+
+		streams[f.folder].Files = append(streams[f.folder].Files, f)
+	}
+
+	return streams
+}
+
+// FilesInStream returns the files within the stream identified by stream, in
+// the order that they are decompressed, which is the order that they're
+// stored. It returns nil if there is no such stream.
+func (z *Reader) FilesInStream(stream int) []*File {
+	if stream < 0 || stream >= z.si.Folders() {
+		return nil
+	}
+
+	var files []*File
+
+	for _, f := range z.File {
+		if f.isEmptyStream || f.isEmptyFile || f.folder != stream {
+			continue
+		}
+
+		files = append(files, f)
+	}
+
+	return files
+}
+
+type streamReader struct {
+	*folderReadCloser
+	crc     uint32
+	stream  int
+	changed func() error
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	n, err := sr.folderReadCloser.Read(p)
+
+	// A stream that's finished, or failed, might have been read from an
+	// archive that's been modified since the stream was opened
+	if sr.changed != nil && err != nil {
+		if cerr := sr.changed(); cerr != nil {
+			return n, newReadError(nil, sr.stream, false, cerr)
+		}
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, newReadError(nil, sr.stream, sr.hasEncryption, err)
+	}
+
+	if errors.Is(err, io.EOF) && sr.crc != 0 && !util.CRC32Equal(sr.Checksum(), sr.crc) {
+		return n, newReadError(nil, sr.stream, sr.hasEncryption, wrongPassword(errChecksum, sr.hasEncryption))
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// OpenStream returns an [io.ReadCloser] that provides access to the entire
+// decompressed contents of the stream identified by stream, which will be
+// the contents of each file in the stream concatenated together. If the
+// archive stores a checksum for the stream, it is verified once the end of
+// the stream is reached. Any callback set with [WithPasswordCallback] is
+// passed the first file in the stream.
+func (z *Reader) OpenStream(stream int) (io.ReadCloser, error) {
+	if z.listOnly {
+		return nil, errListOnly
+	}
+
+	if stream < 0 || stream >= z.si.Folders() {
+		return nil, errNoSuchStream
+	}
+
+	if z.changed != nil {
+		if err := z.changed(); err != nil {
+			return nil, newReadError(nil, stream, false, err)
+		}
+	}
+
+	// Any password callback is passed the first file in the stream
+	var file *File
+	if files := z.FilesInStream(stream); len(files) > 0 {
+		file = files[0]
+	}
+
+	fr, crc, encrypted, err := z.folderReader(z.si, stream, file)
+	if err != nil {
+		return nil, newReadError(nil, stream, encrypted, err)
+	}
+
+	return &streamReader{fr, crc, stream, z.changed}, nil
+}
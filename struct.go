@@ -2,21 +2,31 @@ package sevenzip
 
 import (
 	"bufio"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
 	iofs "io/fs"
+	"math"
 	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bodgit/plumbing"
+	"github.com/bodgit/sevenzip/internal/lzma"
+	"github.com/bodgit/sevenzip/internal/lzma2"
+	"github.com/bodgit/sevenzip/internal/readahead"
 	"github.com/bodgit/sevenzip/internal/util"
+	"github.com/bodgit/sevenzip/internal/zstd"
+	"github.com/bodgit/windows"
 )
 
 var (
-	errAlgorithm             = errors.New("sevenzip: unsupported compression algorithm")
 	errInvalidWhence         = errors.New("invalid whence")
 	errNegativeSeek          = errors.New("negative seek")
 	errSeekBackwards         = errors.New("cannot seek backwards")
@@ -26,11 +36,23 @@ var (
 	errNoUnboundStream       = errors.New("expecting one unbound output stream")
 )
 
+// passwordFunc returns the password to use for an encrypted coder. It is only
+// called once an encrypted coder is found and the caller zeroes the returned
+// copy of the password once it's finished with it.
+type passwordFunc func() ([]byte, error)
+
 // CryptoReadCloser adds a Password method to decompressors.
 type CryptoReadCloser interface {
 	Password(password string) error
 }
 
+// bytesPasswordSetter is implemented by decompressors that can take the
+// password as a byte slice, so it doesn't need converting to a string that
+// can't be zeroed.
+type bytesPasswordSetter interface {
+	PasswordBytes(password []byte) error
+}
+
 type signatureHeader struct {
 	Signature [6]byte
 	Major     byte
@@ -70,6 +92,158 @@ type folder struct {
 	packed        []uint64
 }
 
+// minZstdWindow is the smallest window a Zstandard frame can use.
+const minZstdWindow = 1 << 10
+
+// decodeOptions configures the decompressors reading a folder, a zero field
+// means there is no limit or the default is used.
+type decodeOptions struct {
+	// memory is the total for all of the coders in the folder.
+	memory uint64
+	// dictionary is the largest LZMA or LZMA2 dictionary.
+	dictionary uint64
+	// window is the largest Zstandard window, which is whatever is
+	// left over from memory.
+	window uint64
+	// zstd tunes the Zstandard decoder, any window limit in it is
+	// further bounded by window.
+	zstd zstd.Options
+	// decompressors overrides the registered decompressors.
+	decompressors map[string]Decompressor
+	// metrics, if set, receives the time spent by each coder.
+	metrics Collector
+	// bufferSize is the size of the buffer used to read each packed
+	// stream, zero uses the bufio default.
+	bufferSize int
+	// readAheadSize and readAheads, if set, are the size and number of
+	// blocks read ahead of the coders from each packed stream.
+	readAheadSize, readAheads int
+	// packed, if set, holds a pool of readers for each packed stream to
+	// save allocating them each time a folder is decoded.
+	packed []sync.Pool
+}
+
+// overridden reports whether the decompressor for the method isn't the
+// registered one.
+func (o decodeOptions) overridden(method string) bool {
+	_, ok := o.decompressors[method]
+
+	return ok || isReplaced(method)
+}
+
+// decompressor returns the decompressor for the method, preferring any
+// override and otherwise configuring the registered one with any limits
+// that it has to enforce itself. It also reports whether the decompressor is
+// the registered one unchanged, so its readers can be pooled.
+func (o decodeOptions) decompressor(method []byte) (Decompressor, bool) {
+	if dcomp, ok := o.decompressors[string(method)]; ok {
+		return dcomp, false
+	}
+
+	if isReplaced(string(method)) {
+		return decompressor(method), true
+	}
+
+	switch string(method) {
+	case MethodLZMA:
+		if o.dictionary > 0 {
+			return lzma.NewReaderWithMaxDictionary(o.dictionary), false
+		}
+	case MethodLZMA2:
+		if o.dictionary > 0 {
+			return lzma2.NewReaderWithMaxDictionary(o.dictionary), false
+		}
+	case MethodZstd:
+		zo := o.zstd
+		if o.window > 0 {
+			if zo.MaxWindow == 0 || zo.MaxWindow > o.window {
+				zo.MaxWindow = o.window
+			}
+
+			zo.MaxMemory = o.window
+		}
+
+		if zo != (zstd.Options{}) {
+			return zstd.NewReaderWithOptions(zo), false
+		}
+	}
+
+	return decompressor(method), true
+}
+
+// dictionarySize returns the size of the dictionary used by an LZMA or LZMA2
+// coder, or zero for any other coder.
+func (c *coder) dictionarySize() uint64 {
+	switch string(c.id) {
+	case MethodLZMA:
+		if len(c.properties) == 5 {
+			return uint64(binary.LittleEndian.Uint32(c.properties[1:]))
+		}
+	case MethodLZMA2:
+		if len(c.properties) == 1 && c.properties[0] < 40 {
+			return uint64(2|(c.properties[0]&1)) << (c.properties[0]/2 + 11)
+		} else if len(c.properties) == 1 && c.properties[0] == 40 {
+			return math.MaxUint32
+		}
+	}
+
+	return 0
+}
+
+// memoryUsage estimates how much memory the coder needs up front, which is
+// dominated by the dictionary or window size. Any LZMA or LZMA2 dictionary
+// is capped at maxDictionary if it's non-zero.
+func (c *coder) memoryUsage(maxDictionary uint64) uint64 {
+	switch string(c.id) {
+	case MethodLZMA, MethodLZMA2:
+		size := c.dictionarySize()
+		if maxDictionary > 0 {
+			size = min(size, maxDictionary)
+		}
+
+		return size
+	case MethodPPMd:
+		if len(c.properties) == 5 {
+			return uint64(binary.LittleEndian.Uint32(c.properties[1:]))
+		}
+	case MethodZstd:
+		// The window size isn't known until the frame is read
+		return minZstdWindow
+	}
+
+	return 0
+}
+
+// memoryBudget checks the coders in the folder fit within the memory limit
+// and returns how much is left over.
+func (f *folder) memoryBudget(opts decodeOptions) (uint64, error) {
+	var used uint64
+
+	for _, c := range f.coder {
+		if used += c.memoryUsage(opts.dictionary); used > opts.memory {
+			return 0, withMethod(fmt.Errorf("%w: %d bytes needed", ErrMemoryLimit, used), MethodName(c.id))
+		}
+	}
+
+	return opts.memory - used, nil
+}
+
+func (f *folder) encrypted() bool {
+	for _, c := range f.coder {
+		if string(c.id) == MethodAES256SHA256 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCopy reports whether the folder is a single stream stored with the Copy
+// method, so its contents are stored as-is in the archive.
+func (f *folder) isCopy() bool {
+	return len(f.coder) == 1 && string(f.coder[0].id) == MethodCopy && f.packedStreams == 1
+}
+
 func (f *folder) findInBindPair(i uint64) *bindPair {
 	for _, v := range f.bindPair {
 		if v.in == i {
@@ -90,25 +264,140 @@ func (f *folder) findOutBindPair(i uint64) *bindPair {
 	return nil
 }
 
-func (f *folder) coderReader(readers []io.ReadCloser, coder uint64, password string) (io.ReadCloser, bool, error) {
-	dcomp := decompressor(f.coder[coder].id)
+// methodError records which coder method an error originated from. It is
+// transparent, only its wrapped error is reported.
+type methodError struct {
+	method string
+	err    error
+}
+
+func (e *methodError) Error() string {
+	return e.err.Error()
+}
+
+func (e *methodError) Unwrap() error {
+	return e.err
+}
+
+// withMethod tags err with method unless it has already been tagged by a
+// coder further down the chain.
+func withMethod(err error, method string) error {
+	var me *methodError
+	if err == nil || errors.Is(err, io.EOF) || errors.As(err, &me) {
+		return err
+	}
+
+	return &methodError{method: method, err: err}
+}
+
+// coderReadCloser wraps the reader for each coder, limiting it to the
+// coder's unpacked size and adding the method to any read error. Closed
+// wrappers aren't pooled for reuse as a stale pointer to one would then
+// reach whichever stream's decoder it was reused for, rather than failing
+// with errReaderClosed.
+type coderReadCloser struct {
+	rc     io.ReadCloser
+	method string
+	n      int64
+}
+
+func newCoderReadCloser(rc io.ReadCloser, method string, n int64) *coderReadCloser {
+	return &coderReadCloser{
+		rc:     rc,
+		method: method,
+		n:      n,
+	}
+}
+
+func (rc *coderReadCloser) Read(p []byte) (int, error) {
+	if rc.rc == nil {
+		return 0, errReaderClosed
+	}
+
+	if rc.n <= 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > rc.n {
+		p = p[:rc.n]
+	}
+
+	n, err := rc.rc.Read(p)
+	rc.n -= int64(n)
+
+	return n, withMethod(err, rc.method)
+}
+
+func (rc *coderReadCloser) Close() error {
+	if rc.rc == nil {
+		return errReaderClosed
+	}
+
+	err := rc.rc.Close()
+	rc.rc = nil
+
+	return err //nolint:wrapcheck
+}
+
+// coderReader returns a reader for the coder.
+//
+//nolint:cyclop
+func (f *folder) coderReader(readers []io.ReadCloser, coder uint64, password passwordFunc, opts decodeOptions) (io.ReadCloser, bool, error) {
+	id, props, size := f.coder[coder].id, f.coder[coder].properties, f.size[coder]
+	method := MethodName(id)
+
+	// The pool is looked up before the decompressor so that if the method
+	// is replaced in between, the reader is discarded rather than pooled
+	// alongside readers from the replacement
+	pool := resetPool(string(id))
+
+	dcomp, poolable := opts.decompressor(id)
 	if dcomp == nil {
-		return nil, false, errAlgorithm
+		return nil, false, withMethod(&UnsupportedMethodError{ID: id}, method)
 	}
 
-	cr, err := dcomp(f.coder[coder].properties, f.size[coder], readers)
-	if err != nil {
-		return nil, false, err
+	var (
+		cr  io.ReadCloser
+		err error
+	)
+
+	if poolable {
+		if cr, err = resetReader(pool, props, size, readers); err != nil {
+			return nil, false, withMethod(err, method)
+		}
+	}
+
+	if cr == nil {
+		if cr, err = dcomp(props, size, readers); err != nil {
+			return nil, false, withMethod(err, method)
+		}
 	}
 
 	crc, ok := cr.(CryptoReadCloser)
 	if ok {
-		if err = crc.Password(password); err != nil {
-			return nil, true, fmt.Errorf("sevenzip: error setting password: %w", err)
+		p, err := password()
+		if err != nil {
+			return nil, true, fmt.Errorf("sevenzip: error getting password: %w", err)
 		}
+
+		if bps, isBytes := cr.(bytesPasswordSetter); isBytes {
+			err = bps.PasswordBytes(p)
+		} else {
+			err = crc.Password(string(p))
+		}
+
+		clear(p)
+
+		if err != nil {
+			return nil, true, withMethod(fmt.Errorf("sevenzip: error setting password: %w", err), method)
+		}
+	}
+
+	if _, isResetter := cr.(Resetter); isResetter && poolable {
+		cr = &resetReadCloser{cr, string(id), pool}
 	}
 
-	return plumbing.LimitReadCloser(cr, int64(f.size[coder])), ok, nil //nolint:gosec
+	return newCoderReadCloser(cr, method, int64(size)), ok, nil //nolint:gosec
 }
 
 type folderReadCloser struct {
@@ -117,12 +406,115 @@ type folderReadCloser struct {
 	wc            *plumbing.WriteCounter
 	size          int64
 	hasEncryption bool
+	decoded       *atomic.Uint64
+	metrics       Collector
+	readAheads    []io.Closer
+	packed        []*packedReader
+	seeker        io.Seeker // set if seeking doesn't need to read
+	skipped       int64     // bytes seeked over using seeker
 }
 
+func (rc *folderReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.ReadCloser.Read(p)
+
+	if rc.decoded != nil {
+		rc.decoded.Add(uint64(n)) //nolint:gosec
+	}
+
+	if rc.metrics != nil && n > 0 {
+		rc.metrics.Decoded(n)
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// Close closes the coders and stops any reading ahead of them.
+func (rc *folderReadCloser) Close() error {
+	errs := make([]error, 0, len(rc.readAheads)+1)
+	errs = append(errs, rc.ReadCloser.Close())
+
+	for _, ra := range rc.readAheads {
+		errs = append(errs, ra.Close())
+	}
+
+	// Nothing reads the packed streams once the read-aheads have stopped
+	for _, pr := range rc.packed {
+		pr.release()
+	}
+
+	rc.packed = nil
+
+	return errors.Join(errs...)
+}
+
+// A packedReader reads a packed stream. It can be returned to the pool it
+// came from so the next reader of the same packed stream can reuse it and
+// its buffer.
+type packedReader struct {
+	pool *sync.Pool
+	sr   io.SectionReader
+	br   *bufio.Reader
+}
+
+// newPackedReader returns a reader for the n bytes of packed stream i
+// starting at off, taking it from the pool if there is one.
+func newPackedReader(pools []sync.Pool, i uint64, r io.ReaderAt, off, n int64) *packedReader {
+	var pr *packedReader
+
+	if pools != nil {
+		pr, _ = pools[i].Get().(*packedReader)
+		if pr == nil {
+			pr = &packedReader{pool: &pools[i]}
+		}
+	} else {
+		pr = new(packedReader)
+	}
+
+	pr.sr = *io.NewSectionReader(r, off, n)
+
+	return pr
+}
+
+// buffer returns a buffered reader reading r, reusing the buffer if the
+// reader has been used before.
+func (pr *packedReader) buffer(r io.Reader, size int, n int64) *bufio.Reader {
+	if pr.br == nil {
+		pr.br = newBufferedReader(r, size, n)
+	} else {
+		pr.br.Reset(r)
+	}
+
+	return pr.br
+}
+
+func (pr *packedReader) release() {
+	if pr.pool == nil {
+		return
+	}
+
+	pr.sr = io.SectionReader{}
+	if pr.br != nil {
+		pr.br.Reset(nil)
+	}
+
+	pr.pool.Put(pr)
+}
+
+// Checksum returns the CRC32 of the bytes read so far, or nil if the folder
+// has no digest to verify so they weren't hashed.
 func (rc *folderReadCloser) Checksum() []byte {
+	if rc.h == nil {
+		return nil
+	}
+
 	return rc.h.Sum(nil)
 }
 
+// offset returns the current offset within the unpacked folder.
+func (rc *folderReadCloser) offset() int64 {
+	return int64(rc.wc.Count()) + rc.skipped //nolint:gosec
+}
+
 func (rc *folderReadCloser) Seek(offset int64, whence int) (int64, error) {
 	var newo int64
 
@@ -130,7 +522,7 @@ func (rc *folderReadCloser) Seek(offset int64, whence int) (int64, error) {
 	case io.SeekStart:
 		newo = offset
 	case io.SeekCurrent:
-		newo = int64(rc.wc.Count()) + offset //nolint:gosec
+		newo = rc.offset() + offset
 	case io.SeekEnd:
 		newo = rc.Size() + offset
 	default:
@@ -141,7 +533,7 @@ func (rc *folderReadCloser) Seek(offset int64, whence int) (int64, error) {
 		return 0, errNegativeSeek
 	}
 
-	if uint64(newo) < rc.wc.Count() {
+	if newo < rc.offset() {
 		return 0, errSeekBackwards
 	}
 
@@ -149,7 +541,19 @@ func (rc *folderReadCloser) Seek(offset int64, whence int) (int64, error) {
 		return 0, errSeekEOF
 	}
 
-	if _, err := io.CopyN(io.Discard, rc, newo-int64(rc.wc.Count())); err != nil { //nolint:gosec
+	// Without any decoding, or hashing, the underlying reader can skip
+	// ahead directly
+	if rc.seeker != nil {
+		if _, err := rc.seeker.Seek(newo, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("sevenzip: error seeking: %w", err)
+		}
+
+		rc.skipped += newo - rc.offset()
+
+		return newo, nil
+	}
+
+	if _, err := io.CopyN(io.Discard, rc, newo-rc.offset()); err != nil {
 		return 0, fmt.Errorf("sevenzip: error seeking: %w", err)
 	}
 
@@ -160,11 +564,19 @@ func (rc *folderReadCloser) Size() int64 {
 	return rc.size
 }
 
-func newFolderReadCloser(rc io.ReadCloser, size int64, hasEncryption bool) *folderReadCloser {
+// newFolderReadCloser wraps rc, counting the bytes read and, if there's a
+// digest to verify them against, hashing them.
+func newFolderReadCloser(rc io.ReadCloser, size int64, hasEncryption, hasDigest bool) *folderReadCloser {
 	nrc := new(folderReadCloser)
-	nrc.h = crc32.NewIEEE()
 	nrc.wc = new(plumbing.WriteCounter)
-	nrc.ReadCloser = plumbing.TeeReadCloser(rc, io.MultiWriter(nrc.h, nrc.wc))
+
+	if hasDigest {
+		nrc.h = crc32.NewIEEE()
+		nrc.ReadCloser = plumbing.TeeReadCloser(rc, io.MultiWriter(nrc.h, nrc.wc))
+	} else {
+		nrc.ReadCloser = plumbing.TeeReadCloser(rc, nrc.wc)
+	}
+
 	nrc.size = size
 	nrc.hasEncryption = hasEncryption
 
@@ -200,6 +612,34 @@ type streamsInfo struct {
 	packInfo       *packInfo
 	unpackInfo     *unpackInfo
 	subStreamsInfo *subStreamsInfo
+
+	// Index of the first packed stream and offset of each folder
+	packedStream []uint64
+	offset       []int64
+}
+
+// computeOffsets builds the table of where each folder starts so it doesn't
+// need to be recalculated every time a folder is read.
+func (si *streamsInfo) computeOffsets() {
+	if si.packInfo == nil || si.unpackInfo == nil {
+		return
+	}
+
+	si.packedStream = make([]uint64, len(si.unpackInfo.folder))
+	si.offset = make([]int64, len(si.unpackInfo.folder))
+
+	offset, k := si.packInfo.position, uint64(0)
+
+	for i, f := range si.unpackInfo.folder {
+		si.packedStream[i] = k
+		si.offset[i] = int64(offset) //nolint:gosec
+
+		for j := k; j < k+f.packedStreams && j < uint64(len(si.packInfo.size)); j++ {
+			offset += si.packInfo.size[j]
+		}
+
+		k += f.packedStreams
+	}
 }
 
 func (si *streamsInfo) Folders() int {
@@ -210,102 +650,210 @@ func (si *streamsInfo) Folders() int {
 	return 0
 }
 
-func (si *streamsInfo) FileFolderAndSize(file int) (int, uint64) {
-	total := uint64(0)
+func (si *streamsInfo) encrypted() bool {
+	for i := 0; i < si.Folders(); i++ {
+		if si.unpackInfo.folder[i].encrypted() {
+			return true
+		}
+	}
 
-	var (
-		folder  int
-		streams uint64 = 1
-	)
+	return false
+}
 
-	if si.subStreamsInfo != nil {
-		for folder, streams = range si.subStreamsInfo.streams {
-			total += streams
-			if uint64(file) < total { //nolint:gosec
-				break
-			}
-		}
+// digest returns the CRC of the unpacked folder, or zero if there isn't one.
+func (si *streamsInfo) digest(folder int) uint32 {
+	if si.unpackInfo.digest != nil {
+		return si.unpackInfo.digest[folder]
 	}
 
-	if streams == 1 {
-		return folder, si.unpackInfo.folder[folder].size[len(si.unpackInfo.folder[folder].coder)-1]
+	return 0
+}
+
+// copyFolderReader returns a reader for a folder that only stores its data
+// using the Copy method, which reads the packed stream directly. Unless it
+// has to be hashed this can also seek without reading anything.
+func (si *streamsInfo) copyFolderReader(r io.ReaderAt, folder int, opts decodeOptions) *folderReadCloser {
+	f := si.unpackInfo.folder[folder]
+	sr := io.NewSectionReader(r, si.folderOffset(folder), int64(f.unpackSize())) //nolint:gosec
+
+	var rc io.ReadCloser = io.NopCloser(sr)
+	if opts.metrics != nil {
+		rc = &codecTimer{ReadCloser: rc, method: MethodName(f.coder[0].id), metrics: opts.metrics}
 	}
 
-	return folder, si.subStreamsInfo.size[file]
+	crc := si.digest(folder)
+
+	fr := newFolderReadCloser(rc, int64(f.unpackSize()), false, crc != 0) //nolint:gosec
+	if crc == 0 {
+		fr.seeker = sr
+	}
+
+	return fr
 }
 
-func (si *streamsInfo) folderOffset(folder int) int64 {
-	offset := uint64(0)
+func (si *streamsInfo) folderPackedSize(folder int) uint64 {
+	if si == nil || si.packInfo == nil || folder < 0 || folder >= si.Folders() {
+		return 0
+	}
 
-	for i, k := 0, uint64(0); i < folder; i++ {
-		for j := k; j < k+si.unpackInfo.folder[i].packedStreams; j++ {
-			offset += si.packInfo.size[j]
-		}
+	k := si.packedStream[folder]
 
-		k += si.unpackInfo.folder[i].packedStreams
+	size := uint64(0)
+	for j := k; j < k+si.unpackInfo.folder[folder].packedStreams && j < uint64(len(si.packInfo.size)); j++ {
+		size += si.packInfo.size[j]
 	}
 
-	return int64(si.packInfo.position + offset) //nolint:gosec
+	return size
+}
+
+func (si *streamsInfo) folderOffset(folder int) int64 {
+	return si.offset[folder]
 }
 
 //nolint:cyclop,funlen,lll
-func (si *streamsInfo) FolderReader(r io.ReaderAt, folder int, password string) (*folderReadCloser, uint32, bool, error) {
+func (si *streamsInfo) FolderReader(r io.ReaderAt, folder int, password passwordFunc, opts decodeOptions) (*folderReadCloser, uint32, bool, error) {
 	f := si.unpackInfo.folder[folder]
+
+	if opts.memory > 0 {
+		remaining, err := f.memoryBudget(opts)
+		if err != nil {
+			return nil, 0, f.encrypted(), err
+		}
+
+		// Any Zstandard window can use its minimum plus whatever is left
+		opts.window = remaining + minZstdWindow
+	}
+
+	if f.isCopy() && !opts.overridden(MethodCopy) {
+		return si.copyFolderReader(r, folder, opts), si.digest(folder), false, nil
+	}
+
 	in := make([]io.ReadCloser, f.in)
 	out := make([]io.ReadCloser, f.out)
 
-	packedOffset := 0
-	for i := 0; i < folder; i++ {
-		packedOffset += len(si.unpackInfo.folder[i].packed)
-	}
+	packedOffset := si.packedStream[folder]
+	offset := si.folderOffset(folder)
 
-	offset := int64(0)
+	// With metrics enabled each stream is timed so that the time spent
+	// by each coder can be worked out
+	inTimer := make([]*codecTimer, f.in)
+	outTimer := make([]*codecTimer, f.out)
+
+	// Any read-ahead goroutines have to be stopped if the folder can't be
+	// read
+	var (
+		readAheads []io.Closer
+		packed     []*packedReader
+		ok         bool
+	)
+
+	defer func() {
+		if !ok {
+			for _, ra := range readAheads {
+				_ = ra.Close()
+			}
+		}
+	}()
 
 	for i, input := range f.packed {
-		size := int64(si.packInfo.size[packedOffset+i]) //nolint:gosec
-		in[input] = util.NopCloser(bufio.NewReader(io.NewSectionReader(r, si.folderOffset(folder)+offset, size)))
+		size := int64(si.packInfo.size[packedOffset+uint64(i)]) //nolint:gosec
+
+		pr := newPackedReader(opts.packed, packedOffset+uint64(i), r, offset, size)
+		packed = append(packed, pr)
+
+		var sr io.Reader = &pr.sr
+		if opts.readAheads > 0 {
+			ra := readahead.NewReader(sr, int(min(int64(opts.readAheadSize), max(size, 1))), opts.readAheads)
+			readAheads = append(readAheads, ra)
+			sr = ra
+		}
+
+		if opts.metrics != nil {
+			inTimer[input] = &codecTimer{ReadCloser: io.NopCloser(sr)}
+			sr = inTimer[input]
+		}
+
+		in[input] = util.NopCloser(pr.buffer(sr, opts.bufferSize, size))
 		offset += size
 	}
 
-	var (
-		hasEncryption bool
-		input, output uint64
-	)
+	var hasEncryption bool
+
+	// Each coder has exactly one output so the output streams are numbered
+	// the same as the coders, but the inputs have to be counted
+	first := make([]uint64, len(f.coder))
+
+	var input uint64
 
 	for i, c := range f.coder {
 		if c.out != 1 {
 			return nil, 0, hasEncryption, errMultipleOutputStreams
 		}
 
+		first[i] = input
+		input += c.in
+	}
+
+	// The coders can be listed in any order, 7-Zip itself lists them
+	// starting with the one producing the unpacked output, so each coder is
+	// built once the coders bound to its inputs have been built, starting
+	// from the unbound output
+	building := make([]bool, len(f.coder))
+
+	var build func(i uint64) error
+
+	build = func(i uint64) error {
+		if out[i] != nil {
+			return nil
+		}
+
+		// A bind pair loop can never be read
+		if building[i] {
+			return errNoBoundStream
+		}
+
+		building[i] = true
+
+		c, input := f.coder[i], first[i]
+
 		for j := input; j < input+c.in; j++ {
 			if in[j] != nil {
 				continue
 			}
 
 			bp := f.findInBindPair(j)
-			if bp == nil || out[bp.out] == nil {
-				return nil, 0, hasEncryption, errNoBoundStream
+			if bp == nil || bp.out >= uint64(len(f.coder)) {
+				return errNoBoundStream
 			}
 
-			in[j] = out[bp.out]
-		}
+			if err := build(bp.out); err != nil {
+				return err
+			}
 
-		var (
-			isEncrypted bool
-			err         error
-		)
+			in[j], inTimer[j] = out[bp.out], outTimer[bp.out]
+		}
 
-		out[output], isEncrypted, err = f.coderReader(in[input:input+c.in], uint64(i), password) //nolint:gosec
+		rc, isEncrypted, err := f.coderReader(in[input:input+c.in], i, password, opts)
 		if err != nil {
-			return nil, 0, hasEncryption, err
+			return err
 		}
 
 		if isEncrypted {
 			hasEncryption = true
 		}
 
-		input += c.in
-		output += c.out
+		if opts.metrics != nil {
+			t := &codecTimer{ReadCloser: rc, method: MethodName(c.id), metrics: opts.metrics}
+			for _, it := range inTimer[input : input+c.in] {
+				it.consumer = t
+			}
+
+			rc, outTimer[i] = t, t
+		}
+
+		out[i] = rc
+
+		return nil
 	}
 
 	unbound := make([]uint64, 0, f.out)
@@ -316,21 +864,28 @@ func (si *streamsInfo) FolderReader(r io.ReaderAt, folder int, password string)
 		}
 	}
 
-	if len(unbound) != 1 || out[unbound[0]] == nil {
+	if len(unbound) != 1 {
 		return nil, 0, hasEncryption, errNoUnboundStream
 	}
 
-	fr := newFolderReadCloser(out[unbound[0]], int64(f.unpackSize()), hasEncryption) //nolint:gosec
-
-	if si.unpackInfo.digest != nil {
-		return fr, si.unpackInfo.digest[folder], hasEncryption, nil
+	if err := build(unbound[0]); err != nil {
+		return nil, 0, hasEncryption, err
 	}
 
-	return fr, 0, hasEncryption, nil
+	crc := si.digest(folder)
+
+	fr := newFolderReadCloser(out[unbound[0]], int64(f.unpackSize()), hasEncryption, crc != 0) //nolint:gosec
+	fr.readAheads, fr.packed, ok = readAheads, packed, true
+
+	return fr, crc, hasEncryption, nil
 }
 
 type filesInfo struct {
 	file []FileHeader
+
+	// rawName holds the UTF-16LE name of each file as stored, which is
+	// only copied to FileHeader.RawName if asked for.
+	rawName [][]byte
 }
 
 type header struct {
@@ -338,9 +893,25 @@ type header struct {
 	filesInfo   *filesInfo
 }
 
-// FileHeader describes a file within a 7-zip file.
+// FileHeader describes a file within a 7-zip file. The format has no
+// equivalent of a hard link, each link is stored as a separate copy of the
+// file.
 type FileHeader struct {
-	Name             string
+	// Name is the name of the file exactly as stored in the archive,
+	// which may use backslashes as separators or contain ".." elements.
+	// See [FileHeader.CleanName] for the name used by the [fs.FS]
+	// methods of the [Reader].
+	Name string
+
+	// RawName is the name of the file as the UTF-16LE bytes stored in
+	// the archive, without the terminating NUL. It's only populated if
+	// the archive is opened with [WithNamePolicy] and [NameRaw], or by
+	// [ParseHeader], for callers that need to apply their own decoding.
+	RawName string
+
+	// Created, Accessed and Modified have the full 100 ns precision of
+	// the timestamps stored in the archive, or are the zero time.Time
+	// if they aren't stored.
 	Created          time.Time
 	Accessed         time.Time
 	Modified         time.Time
@@ -353,8 +924,60 @@ type FileHeader struct {
 	// to be stored within the same stream.
 	Stream int
 
+	// Index is the position of the file in the archive header, which is
+	// also its position in [Reader.File]. Unlike Name, which can be empty
+	// or shared by several files, it identifies the file, for example
+	// when comparing the entries of two archives. See [Reader.FileByIndex].
+	Index int
+
+	// PackedSize is the number of compressed bytes attributed to the
+	// file. As files in the same stream are compressed together, the
+	// packed size of the stream is shared between them in proportion to
+	// their uncompressed size.
+	PackedSize uint64
+
+	// Linkname is the destination of a symbolic link. As this is stored
+	// as the contents of the file it is read when the archive is opened,
+	// unless it's opened with [ListOnly] or the file can't be read, see
+	// [File.Readlink].
+	Linkname string
+
+	// IsAnti is set for an anti item, used by incremental backups to
+	// record that the file or directory with the same name has been
+	// deleted since the previous backup. An anti item has no contents.
+	IsAnti bool
+
+	// SecurityDescriptor is the raw self-relative Windows NT security
+	// descriptor of the file, which holds its owner, group and ACLs, if
+	// the archive was created with them stored. It's empty otherwise.
+	SecurityDescriptor string
+
+	// StartPos is the position of the file's data within some larger
+	// original file, stored as the kStartPos property by backup software
+	// that archives part of a file, which 7-Zip shows as "Position". It
+	// only describes where the data came from and has no effect on
+	// reading the file. It's zero if it isn't stored.
+	StartPos uint64
+
 	isEmptyStream bool
 	isEmptyFile   bool
+	hasStartPos   bool
+
+	// methods holds the names of the methods joined with methodSeparator
+	// rather than as a slice so that FileHeader stays comparable.
+	methods string
+}
+
+const methodSeparator = "+"
+
+// methodNames returns the names of the methods used to decompress the
+// file, or nil if it has no stream.
+func (h *FileHeader) methodNames() []string {
+	if h.methods == "" {
+		return nil
+	}
+
+	return strings.Split(h.methods, methodSeparator)
 }
 
 // FileInfo returns an [fs.FileInfo] for the FileHeader.
@@ -362,6 +985,54 @@ func (h *FileHeader) FileInfo() iofs.FileInfo {
 	return headerFileInfo{h}
 }
 
+// CleanName returns Name converted to a valid [fs.FS] name, by converting
+// any backslashes to forward slashes, cleaning the result and removing any
+// leading "/" or "../" elements. The result is "" or "." if nothing remains.
+func (h *FileHeader) CleanName() string {
+	return toValidName(h.Name)
+}
+
+// CreatedFiletime returns Created as the Windows FILETIME stored in the
+// archive, which is zero if it isn't stored.
+func (h *FileHeader) CreatedFiletime() windows.Filetime {
+	return timeToFiletime(h.Created)
+}
+
+// AccessedFiletime returns Accessed as the Windows FILETIME stored in the
+// archive, which is zero if it isn't stored.
+func (h *FileHeader) AccessedFiletime() windows.Filetime {
+	return timeToFiletime(h.Accessed)
+}
+
+// ModifiedFiletime returns Modified as the Windows FILETIME stored in the
+// archive, which is zero if it isn't stored.
+func (h *FileHeader) ModifiedFiletime() windows.Filetime {
+	return timeToFiletime(h.Modified)
+}
+
+// String returns a one-line description of the file, suitable for logging.
+func (h *FileHeader) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s %d", h.Mode(), h.Name, h.UncompressedSize)
+
+	if h.methods != "" {
+		fmt.Fprintf(&b, " (%d packed, %s) crc32=%08x", h.PackedSize, h.methods, h.CRC32)
+	}
+
+	if !h.Modified.IsZero() {
+		fmt.Fprintf(&b, " modified=%s", h.Modified.UTC().Format(time.RFC3339Nano))
+	}
+
+	return b.String()
+}
+
+// MarshalJSON implements the [json.Marshaler] interface, encoding the file
+// the same way as its [ListingEntry].
+func (h *FileHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.listingEntry()) //nolint:wrapcheck
+}
+
 type headerFileInfo struct {
 	fh *FileHeader
 }
@@ -391,30 +1062,91 @@ const (
 	sISGID  = 0x400
 	sISVTX  = 0x200
 
-	msdosDir      = 0x10
-	msdosReadOnly = 0x01
+	unixAttributes = 0xf0000000
+)
+
+// Windows file attribute bits that can be present in the lower 16 bits of
+// [FileHeader.Attributes].
+const (
+	AttributeReadOnly          uint32 = 0x0001
+	AttributeHidden            uint32 = 0x0002
+	AttributeSystem            uint32 = 0x0004
+	AttributeDirectory         uint32 = 0x0010
+	AttributeArchive           uint32 = 0x0020
+	AttributeDevice            uint32 = 0x0040
+	AttributeNormal            uint32 = 0x0080
+	AttributeTemporary         uint32 = 0x0100
+	AttributeSparseFile        uint32 = 0x0200
+	AttributeReparsePoint      uint32 = 0x0400
+	AttributeCompressed        uint32 = 0x0800
+	AttributeOffline           uint32 = 0x1000
+	AttributeNotContentIndexed uint32 = 0x2000
+	AttributeEncrypted         uint32 = 0x4000
+
+	// AttributeUnixExtension is set by 7-Zip when the upper 16 bits of
+	// the attributes contain Unix mode bits.
+	AttributeUnixExtension uint32 = 0x8000
 )
 
 // Mode returns the permission and mode bits for the FileHeader.
 func (h *FileHeader) Mode() (mode iofs.FileMode) {
 	// Prefer the POSIX attributes if they're present
-	if h.Attributes&0xf0000000 != 0 {
-		mode = unixModeToFileMode(h.Attributes >> 16)
+	if m, ok := h.UnixMode(); ok {
+		mode = unixModeToFileMode(m)
 	} else {
-		mode = msdosModeToFileMode(h.Attributes)
+		mode = msdosModeToFileMode(h.WindowsAttributes())
 	}
 
 	return
 }
 
+// WindowsAttributes returns the Windows file attribute bits from the
+// FileHeader, which can be tested with the Attribute constants.
+func (h *FileHeader) WindowsAttributes() uint32 {
+	return h.Attributes & 0xffff
+}
+
+// UnixMode returns the Unix mode bits from the FileHeader, (as found in the
+// st_mode field of struct stat). The boolean reports whether the archive
+// actually recorded any Unix mode bits for the file.
+func (h *FileHeader) UnixMode() (uint32, bool) {
+	if h.Attributes&unixAttributes == 0 {
+		return 0, false
+	}
+
+	return h.Attributes >> 16, true
+}
+
+// IsSymlink reports whether the FileHeader describes a symbolic link.
+func (h *FileHeader) IsSymlink() bool {
+	return h.Mode()&iofs.ModeSymlink != 0
+}
+
+// IsHidden reports whether the FileHeader has the Windows hidden attribute
+// set. A Unix file whose name begins with a dot is not considered hidden.
+func (h *FileHeader) IsHidden() bool {
+	return h.Attributes&AttributeHidden != 0
+}
+
+// IsReadOnly reports whether the FileHeader has no write permission bits.
+func (h *FileHeader) IsReadOnly() bool {
+	return h.Mode().Perm()&0o222 == 0
+}
+
+// IsSystem reports whether the FileHeader has the Windows system attribute
+// set.
+func (h *FileHeader) IsSystem() bool {
+	return h.Attributes&AttributeSystem != 0
+}
+
 func msdosModeToFileMode(m uint32) (mode iofs.FileMode) {
-	if m&msdosDir != 0 {
+	if m&AttributeDirectory != 0 {
 		mode = iofs.ModeDir | 0o777
 	} else {
 		mode = 0o666
 	}
 
-	if m&msdosReadOnly != 0 {
+	if m&AttributeReadOnly != 0 {
 		mode &^= 0o222
 	}
 
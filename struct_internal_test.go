@@ -1,13 +1,16 @@
 package sevenzip
 
 import (
+	"bytes"
 	"io"
 	"math"
 	"path/filepath"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz/lzma"
 )
 
 func TestFileReadCloser_Seek(t *testing.T) {
@@ -26,7 +29,7 @@ func TestFileReadCloser_Seek(t *testing.T) {
 
 	require.GreaterOrEqual(t, len(r.File), 1)
 
-	rc, _, _, err := r.folderReader(r.si, r.File[0].folder)
+	rc, _, _, err := r.folderReader(r.si, r.File[0].folder, r.File[0])
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -61,3 +64,408 @@ func TestFileReadCloser_Seek(t *testing.T) {
 	assert.Equal(t, n, int64(r.File[0].UncompressedSize)) //nolint:gosec
 	assert.NoError(t, err)
 }
+
+func TestStreamsInfo_ComputeOffsets(t *testing.T) {
+	t.Parallel()
+
+	si := &streamsInfo{
+		packInfo: &packInfo{
+			position: 10,
+			size:     []uint64{100, 200, 300, 400},
+		},
+		unpackInfo: &unpackInfo{
+			folder: []*folder{
+				{packedStreams: 1},
+				{packedStreams: 2},
+				{packedStreams: 1},
+			},
+		},
+	}
+
+	si.computeOffsets()
+
+	assert.Equal(t, []uint64{0, 1, 3}, si.packedStream)
+	assert.Equal(t, []int64{10, 110, 610}, si.offset)
+	assert.Equal(t, uint64(500), si.folderPackedSize(1))
+}
+
+func TestFolderReadCloser_Checksum(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name      string
+		hasDigest bool
+		checksum  []byte
+	}{
+		{
+			name:      "digest",
+			hasDigest: true,
+			checksum:  []byte{0x0d, 0x4a, 0x11, 0x85},
+		},
+		{
+			name: "no digest",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := []byte("hello world")
+			rc := newFolderReadCloser(io.NopCloser(bytes.NewReader(b)), int64(len(b)), false, table.hasDigest)
+
+			n, err := io.Copy(io.Discard, rc)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(b)), n)
+
+			// The bytes are always counted
+			assert.Equal(t, uint64(len(b)), rc.wc.Count())
+			assert.Equal(t, table.checksum, rc.Checksum())
+		})
+	}
+}
+
+func TestFolderReadCloser_CopySeek(t *testing.T) {
+	t.Parallel()
+
+	r, err := OpenReader(filepath.Join("testdata", "copy.7z"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	f := r.File[len(r.File)-1]
+	require.Greater(t, f.UncompressedSize, uint64(10))
+
+	want, err := r.ReadFile(f.Name)
+	require.NoError(t, err)
+
+	rc, _, _, err := r.folderReader(r.si, f.folder, f)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, rc.Close())
+	}()
+
+	// Stored data can be seeked without reading it
+	require.NotNil(t, rc.seeker)
+
+	p := make([]byte, 5)
+
+	for _, off := range []int64{0, 5, int64(len(want)) - 5} {
+		n, err := rc.Seek(off, io.SeekStart)
+		require.NoError(t, err)
+		assert.Equal(t, off, n)
+
+		_, err = io.ReadFull(rc, p)
+		require.NoError(t, err)
+		assert.Equal(t, want[off:off+5], p)
+
+		n, err = rc.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, off+5, n)
+	}
+
+	assert.Zero(t, r.Stats().BytesSkipped)
+}
+
+func TestCoderReadCloser(t *testing.T) {
+	t.Parallel()
+
+	rc := newCoderReadCloser(io.NopCloser(bytes.NewReader([]byte("0123456789"))), "Copy", 4)
+
+	// Reads stop at the coder's unpacked size
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0123"), b)
+
+	rc = newCoderReadCloser(io.NopCloser(iotest.ErrReader(errTruncated)), "Copy", 4)
+
+	_, err = rc.Read(make([]byte, 1))
+
+	var me *methodError
+	if assert.ErrorAs(t, err, &me) {
+		assert.Equal(t, "Copy", me.method)
+	}
+
+	assert.ErrorIs(t, err, errTruncated)
+
+	require.NoError(t, rc.Close())
+
+	// A stale wrapper never touches a reader opened after it's closed
+	other := newCoderReadCloser(io.NopCloser(bytes.NewReader([]byte("0123"))), "Copy", 4)
+
+	_, err = rc.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, errReaderClosed)
+	assert.ErrorIs(t, rc.Close(), errReaderClosed)
+
+	b, err = io.ReadAll(other)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0123"), b)
+	require.NoError(t, other.Close())
+}
+
+func TestFolderReader_CoderOrder(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name, file, password string
+		folder               func(*folder) *folder
+		err                  error
+	}{
+		{
+			// As 7-Zip lists them, outermost coder first
+			name:     "decrypt last",
+			file:     "t3.7z",
+			password: "password",
+			folder: func(f *folder) *folder {
+				return &folder{
+					in: 2, out: 2, packedStreams: 1,
+					coder:    []*coder{f.coder[1], f.coder[0]},
+					bindPair: []*bindPair{{in: 0, out: 1}},
+					size:     []uint64{f.size[1], f.size[0]},
+					packed:   []uint64{1},
+				}
+			},
+		},
+		{
+			name:     "filter after decryption",
+			file:     "t3.7z",
+			password: "password",
+			folder: func(f *folder) *folder {
+				return &folder{
+					in: 3, out: 3, packedStreams: 1,
+					coder:    []*coder{f.coder[1], {id: []byte(MethodARM64), in: 1, out: 1}, f.coder[0]},
+					bindPair: []*bindPair{{in: 0, out: 1}, {in: 1, out: 2}},
+					size:     []uint64{f.size[1], f.size[1], f.size[0]},
+					packed:   []uint64{2},
+				}
+			},
+		},
+		{
+			name: "filter first",
+			file: "bcj.7z",
+			folder: func(f *folder) *folder {
+				return &folder{
+					in: 2, out: 2, packedStreams: 1,
+					coder:    []*coder{f.coder[1], f.coder[0]},
+					bindPair: []*bindPair{{in: 0, out: 1}},
+					size:     []uint64{f.size[1], f.size[0]},
+					packed:   []uint64{1},
+				}
+			},
+		},
+		{
+			name: "bind pair loop",
+			file: "bcj.7z",
+			folder: func(f *folder) *folder {
+				return &folder{
+					in: 3, out: 3, packedStreams: 0,
+					coder:    []*coder{f.coder[1], f.coder[1], f.coder[1]},
+					bindPair: []*bindPair{{in: 0, out: 1}, {in: 1, out: 2}, {in: 2, out: 1}},
+					size:     []uint64{f.size[1], f.size[1], f.size[1]},
+				}
+			},
+			err: errNoBoundStream,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			want, err := OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, want.Close())
+			}()
+
+			r, err := OpenReaderWithPassword(filepath.Join("testdata", table.file), table.password)
+			require.NoError(t, err)
+
+			defer func() {
+				require.NoError(t, r.Close())
+			}()
+
+			r.si.unpackInfo.folder[0] = table.folder(r.si.unpackInfo.folder[0])
+
+			for _, f := range r.File {
+				if f.isEmptyStream || f.folder != 0 {
+					continue
+				}
+
+				b, err := r.ReadFile(f.Name)
+				if table.err != nil {
+					assert.ErrorIs(t, err, table.err)
+
+					continue
+				}
+
+				require.NoError(t, err)
+
+				expected, err := want.ReadFile(f.Name)
+				require.NoError(t, err)
+				assert.Equal(t, expected, b)
+			}
+		})
+	}
+}
+
+type concatReadCloser struct {
+	io.Reader
+	readers []io.ReadCloser
+}
+
+func (rc *concatReadCloser) Close() error {
+	for _, r := range rc.readers {
+		_ = r.Close()
+	}
+
+	return nil
+}
+
+// newConcatReader is a Decompressor with any number of inputs that reads
+// each of them in turn.
+func newConcatReader(_ []byte, _ uint64, readers []io.ReadCloser) (io.ReadCloser, error) {
+	r := make([]io.Reader, len(readers))
+	for i, rc := range readers {
+		r[i] = rc
+	}
+
+	return &concatReadCloser{io.MultiReader(r...), readers}, nil
+}
+
+func TestFolderReader_MultipleInputs(t *testing.T) {
+	t.Parallel()
+
+	const methodConcat = "\x7f\xff\xff\xfd"
+
+	packed := []byte("hello world")
+
+	tables := []struct {
+		name   string
+		folder *folder
+		want   string
+	}{
+		{
+			name: "packed streams",
+			folder: &folder{
+				in: 2, out: 1, packedStreams: 2,
+				coder:  []*coder{{id: []byte(methodConcat), in: 2, out: 1}},
+				size:   []uint64{11},
+				packed: []uint64{1, 0},
+			},
+			want: "worldhello ",
+		},
+		{
+			name: "bound and packed streams",
+			folder: &folder{
+				in: 3, out: 2, packedStreams: 2,
+				coder:    []*coder{{id: []byte(methodConcat), in: 2, out: 1}, {id: []byte(MethodCopy), in: 1, out: 1}},
+				bindPair: []*bindPair{{in: 0, out: 1}},
+				size:     []uint64{11, 5},
+				packed:   []uint64{1, 2},
+			},
+			want: "worldhello ",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			si := &streamsInfo{
+				packInfo:     &packInfo{streams: 2, size: []uint64{6, 5}},
+				unpackInfo:   &unpackInfo{folder: []*folder{table.folder}},
+				packedStream: []uint64{0},
+				offset:       []int64{0},
+			}
+
+			opts := decodeOptions{
+				decompressors: map[string]Decompressor{methodConcat: newConcatReader},
+			}
+
+			rc, _, _, err := si.FolderReader(bytes.NewReader(packed), 0, nil, opts)
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+
+			assert.Equal(t, table.want, string(b))
+		})
+	}
+}
+
+func TestFolderReader_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	for _, method := range []string{MethodLZ5, MethodLizard} {
+		si := &streamsInfo{
+			packInfo: &packInfo{streams: 1, size: []uint64{5}},
+			unpackInfo: &unpackInfo{folder: []*folder{{
+				in: 1, out: 1, packedStreams: 1,
+				coder:  []*coder{{id: []byte(method), in: 1, out: 1}},
+				size:   []uint64{5},
+				packed: []uint64{0},
+			}}},
+			packedStream: []uint64{0},
+			offset:       []int64{0},
+		}
+
+		_, _, _, err := si.FolderReader(bytes.NewReader([]byte("hello")), 0, nil, decodeOptions{})
+
+		var ume *UnsupportedMethodError
+		if assert.ErrorAs(t, err, &ume) {
+			assert.Equal(t, []byte(method), ume.ID)
+		}
+
+		assert.ErrorContains(t, err, "unsupported compression method "+MethodName([]byte(method)))
+	}
+}
+
+func TestFolderReader_FastLZMA2(t *testing.T) {
+	t.Parallel()
+
+	want := bytes.Repeat([]byte("fast lzma2 "), 1000)
+
+	// Fast LZMA2 in 7-Zip ZS writes a plain LZMA2 stream, with the usual
+	// one byte dictionary size property, as MethodLZMA2
+	packed := new(bytes.Buffer)
+
+	w, err := lzma.Writer2Config{DictCap: 1 << 24}.NewWriter2(packed)
+	require.NoError(t, err)
+
+	_, err = w.Write(want)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	si := &streamsInfo{
+		packInfo: &packInfo{streams: 1, size: []uint64{uint64(packed.Len())}},
+		unpackInfo: &unpackInfo{folder: []*folder{{
+			in: 1, out: 1, packedStreams: 1,
+			coder:  []*coder{{id: []byte(MethodLZMA2), in: 1, out: 1, properties: []byte{26}}},
+			size:   []uint64{uint64(len(want))},
+			packed: []uint64{0},
+		}}},
+		packedStream: []uint64{0},
+		offset:       []int64{0},
+	}
+
+	rc, _, _, err := si.FolderReader(bytes.NewReader(packed.Bytes()), 0, nil, decodeOptions{})
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	assert.Equal(t, want, b)
+}
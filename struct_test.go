@@ -0,0 +1,103 @@
+package sevenzip_test
+
+import (
+	iofs "io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:funlen
+func TestFileHeaderAttributes(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name                                string
+		fh                                  sevenzip.FileHeader
+		mode                                iofs.FileMode
+		symlink, hidden, readOnly, isSystem bool
+	}{
+		{
+			name: "windows file",
+			fh: sevenzip.FileHeader{
+				Name:       "file.txt",
+				Attributes: sevenzip.AttributeArchive,
+			},
+			mode: 0o666,
+		},
+		{
+			name: "windows hidden system read-only file",
+			fh: sevenzip.FileHeader{
+				Name:       "file.sys",
+				Attributes: sevenzip.AttributeHidden | sevenzip.AttributeSystem | sevenzip.AttributeReadOnly,
+			},
+			mode:     0o444,
+			hidden:   true,
+			readOnly: true,
+			isSystem: true,
+		},
+		{
+			name: "windows directory",
+			fh: sevenzip.FileHeader{
+				Name:       "dir/",
+				Attributes: sevenzip.AttributeDirectory,
+			},
+			mode: iofs.ModeDir | 0o777,
+		},
+		{
+			name: "unix file",
+			fh: sevenzip.FileHeader{
+				Name:       "file",
+				Attributes: 0o100644<<16 | sevenzip.AttributeUnixExtension | sevenzip.AttributeArchive,
+			},
+			mode: 0o644,
+		},
+		{
+			name: "unix dot file",
+			fh: sevenzip.FileHeader{
+				Name:       "dir/.profile",
+				Attributes: 0o100444<<16 | sevenzip.AttributeUnixExtension,
+			},
+			mode:     0o444,
+			readOnly: true,
+		},
+		{
+			name: "unix symlink",
+			fh: sevenzip.FileHeader{
+				Name:       "link",
+				Attributes: 0o120777<<16 | sevenzip.AttributeUnixExtension,
+			},
+			mode:    iofs.ModeSymlink | 0o777,
+			symlink: true,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, table.mode, table.fh.Mode())
+			assert.Equal(t, table.symlink, table.fh.IsSymlink())
+			assert.Equal(t, table.hidden, table.fh.IsHidden())
+			assert.Equal(t, table.readOnly, table.fh.IsReadOnly())
+			assert.Equal(t, table.isSystem, table.fh.IsSystem())
+
+			m, ok := table.fh.UnixMode()
+			assert.Equal(t, table.fh.Attributes>>16, m)
+			assert.Equal(t, m != 0, ok)
+			assert.Equal(t, table.fh.Attributes&0xffff, table.fh.WindowsAttributes())
+		})
+	}
+}
+
+func TestFileHeaderComparable(t *testing.T) {
+	t.Parallel()
+
+	// Callers compare files and use them as map keys
+	assert.True(t, reflect.TypeOf(sevenzip.FileHeader{}).Comparable())
+	assert.True(t, reflect.TypeOf(sevenzip.File{}).Comparable())
+}
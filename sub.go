@@ -0,0 +1,181 @@
+package sevenzip
+
+import (
+	"errors"
+	iofs "io/fs"
+	"path"
+	"strings"
+)
+
+// Sub returns an [fs.FS] corresponding to the subtree rooted at dir, using
+// the semantics of [fs.SubFS]. Unlike [fs.Sub], the returned [fs.FS] uses the
+// index of the archive directly and also implements the optional
+// [fs.GlobFS], [fs.ReadDirFS], [fs.ReadFileFS], [fs.ReadLinkFS], [fs.StatFS]
+// and [fs.SubFS] interfaces.
+func (z *Reader) Sub(dir string) (iofs.FS, error) {
+	if !iofs.ValidPath(dir) {
+		return nil, &iofs.PathError{Op: "sub", Path: dir, Err: iofs.ErrInvalid}
+	}
+
+	if dir == "." {
+		return z, nil
+	}
+
+	return &subFS{z, dir}, nil
+}
+
+type subFS struct {
+	z   *Reader
+	dir string
+}
+
+// fullName maps name to the name within the archive.
+func (f *subFS) fullName(op, name string) (string, error) {
+	if !iofs.ValidPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+
+	return path.Join(f.dir, name), nil
+}
+
+// shorten maps name within the archive to the name within the subtree.
+func (f *subFS) shorten(name string) (string, bool) {
+	if name == f.dir {
+		return ".", true
+	}
+
+	if len(name) > len(f.dir) && name[len(f.dir)] == '/' && name[:len(f.dir)] == f.dir {
+		return name[len(f.dir)+1:], true
+	}
+
+	return "", false
+}
+
+// fixErr shortens any path present in err.
+func (f *subFS) fixErr(err error) error {
+	var pe *iofs.PathError
+	if errors.As(err, &pe) {
+		if short, ok := f.shorten(pe.Path); ok {
+			pe.Path = short
+		}
+	}
+
+	return err
+}
+
+func (f *subFS) Open(name string) (iofs.File, error) {
+	full, err := f.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.z.Open(full)
+
+	return file, f.fixErr(err)
+}
+
+func (f *subFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	full, err := f.fullName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := f.z.ReadDir(full)
+
+	return entries, f.fixErr(err)
+}
+
+func (f *subFS) ReadFile(name string) ([]byte, error) {
+	full, err := f.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := f.z.ReadFile(full)
+
+	return b, f.fixErr(err)
+}
+
+func (f *subFS) Stat(name string) (iofs.FileInfo, error) {
+	full, err := f.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.z.Stat(full)
+
+	return info, f.fixErr(err)
+}
+
+func (f *subFS) ReadLink(name string) (string, error) {
+	full, err := f.fullName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := f.z.ReadLink(full)
+
+	return target, f.fixErr(err)
+}
+
+func (f *subFS) Lstat(name string) (iofs.FileInfo, error) {
+	full, err := f.fullName("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.z.Lstat(full)
+
+	return info, f.fixErr(err)
+}
+
+func (f *subFS) Glob(pattern string) ([]string, error) {
+	// Check the pattern is well-formed
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if pattern == "." {
+		return []string{"."}, nil
+	}
+
+	matches, err := f.z.Glob(escapePattern(f.dir) + "/" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range matches {
+		matches[i], _ = f.shorten(name)
+	}
+
+	return matches, nil
+}
+
+func (f *subFS) Sub(dir string) (iofs.FS, error) {
+	if dir == "." {
+		return f, nil
+	}
+
+	full, err := f.fullName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subFS{f.z, full}, nil
+}
+
+// escapePattern escapes any characters in name that have special meaning to
+// [path.Match].
+func escapePattern(name string) string {
+	var b strings.Builder
+
+	for _, r := range name {
+		if strings.ContainsRune(`*?[]\`, r) {
+			_ = b.WriteByte('\\')
+		}
+
+		_, _ = b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -1,7 +1,6 @@
 package sevenzip
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
@@ -9,11 +8,12 @@ import (
 	"io"
 	"math/bits"
 	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/bodgit/sevenzip/internal/util"
 	"github.com/bodgit/windows"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
 )
 
 const (
@@ -33,7 +33,7 @@ const (
 	idNumUnpackStream
 	idEmptyStream
 	idEmptyFile
-	idAnti //nolint:deadcode,varcheck
+	idAnti
 	idName
 	idCTime
 	idATime
@@ -43,6 +43,7 @@ const (
 	idEncodedHeader
 	idStartPos
 	idDummy
+	idNtSecure
 )
 
 var (
@@ -50,8 +51,57 @@ var (
 	errUnexpectedID           = errors.New("sevenzip: unexpected id")
 	errMissingUnpackInfo      = errors.New("sevenzip: missing unpack info")
 	errWrongNumberOfFilenames = errors.New("sevenzip: wrong number of filenames")
+	errInvalidNtSecure        = errors.New("sevenzip: invalid security descriptors")
+	errInvalidStartPos        = errors.New("sevenzip: invalid start positions")
+	errInvalidName            = errors.New("sevenzip: invalid UTF-16 name")
+	errMissingStream          = errors.New("sevenzip: more files than streams")
+	errTooManyEntries         = errors.New("sevenzip: too many entries in header")
+	errInvalidFolder          = errors.New("sevenzip: invalid folder")
+	errInvalidStreamSizes     = errors.New("sevenzip: stream sizes exceed folder size")
+	errMissingPackedStream    = errors.New("sevenzip: more folder inputs than packed streams")
 )
 
+const (
+	// defaultMaxHeaderEntries is how many files, folders or streams a
+	// header can have unless changed with WithMaxHeaderEntries.
+	defaultMaxHeaderEntries = 1 << 22
+
+	// maxCoders and maxCoderStreams match the limits of 7-Zip itself on
+	// the coders in a folder and their inputs and outputs.
+	maxCoders       = 64
+	maxCoderStreams = 64
+
+	// maxCoderProperties is the longest coder properties that will be
+	// read, far beyond any known method.
+	maxCoderProperties = 1 << 16
+)
+
+// headerOptions controls how the header is read.
+type headerOptions struct {
+	// skipDigests skips storing the CRC of each file, which is only
+	// needed to extract them.
+	skipDigests bool
+	// maxEntries is the most files, folders or streams the header can
+	// have, so that a crafted header can't make huge allocations. Zero
+	// uses defaultMaxHeaderEntries.
+	maxEntries uint64
+}
+
+// checkEntries returns errTooManyEntries if n is more than the header can
+// have.
+func (o headerOptions) checkEntries(n uint64) error {
+	limit := o.maxEntries
+	if limit == 0 {
+		limit = defaultMaxHeaderEntries
+	}
+
+	if n > limit {
+		return errTooManyEntries
+	}
+
+	return nil
+}
+
 func readUint64(r io.ByteReader) (uint64, error) {
 	b, err := r.ReadByte()
 	if err != nil {
@@ -141,19 +191,44 @@ func readCRC(r util.Reader, count uint64) ([]uint32, error) {
 
 	crcs := make([]uint32, count)
 
+	// Reused rather than letting binary.Read allocate for each value
+	var b [4]byte
+
 	for i := range defined {
 		if defined[i] {
-			if err := binary.Read(r, binary.LittleEndian, &crcs[i]); err != nil {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
 				return nil, fmt.Errorf("readCRC: Read error: %w", err)
 			}
+
+			crcs[i] = binary.LittleEndian.Uint32(b[:])
 		}
 	}
 
 	return crcs, nil
 }
 
+// skipCRC reads count optional CRC values without storing them.
+func skipCRC(r util.Reader, count uint64) error {
+	defined, err := readOptionalBool(r, count)
+	if err != nil {
+		return err
+	}
+
+	var b [4]byte
+
+	for i := range defined {
+		if defined[i] {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return fmt.Errorf("skipCRC: Read error: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 //nolint:cyclop
-func readPackInfo(r util.Reader) (*packInfo, error) {
+func readPackInfo(r util.Reader, opts headerOptions) (*packInfo, error) {
 	p := new(packInfo)
 
 	var err error
@@ -168,6 +243,10 @@ func readPackInfo(r util.Reader) (*packInfo, error) {
 		return nil, err
 	}
 
+	if err := opts.checkEntries(p.streams); err != nil {
+		return nil, err
+	}
+
 	id, err := r.ReadByte()
 	if err != nil {
 		return nil, fmt.Errorf("readPackInfo: ReadByte error: %w", err)
@@ -203,32 +282,34 @@ func readPackInfo(r util.Reader) (*packInfo, error) {
 }
 
 //nolint:cyclop
-func readCoder(r util.Reader) (*coder, error) {
-	c := new(coder)
-
+func readCoder(r util.Reader, c *coder) error {
 	v, err := r.ReadByte()
 	if err != nil {
-		return nil, fmt.Errorf("readCoder: ReadByte error: %w", err)
+		return fmt.Errorf("readCoder: ReadByte error: %w", err)
 	}
 
 	c.id = make([]byte, v&0xf)
 	if n, err := r.Read(c.id); err != nil || n != int(v&0xf) {
 		if err != nil {
-			return nil, fmt.Errorf("readCoder: Read error: %w", err)
+			return fmt.Errorf("readCoder: Read error: %w", err)
 		}
 
-		return nil, errIncompleteRead
+		return errIncompleteRead
 	}
 
 	if v&0x10 != 0 {
 		c.in, err = readUint64(r)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		c.out, err = readUint64(r)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if c.in > maxCoderStreams || c.out > maxCoderStreams {
+			return errInvalidFolder
 		}
 	} else {
 		c.in, c.out = 1, 1
@@ -237,57 +318,86 @@ func readCoder(r util.Reader) (*coder, error) {
 	if v&0x20 != 0 {
 		size, err := readUint64(r)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if size > maxCoderProperties {
+			return errInvalidFolder
 		}
 
 		c.properties = make([]byte, size)
 		if n, err := r.Read(c.properties); err != nil || uint64(n) != size { //nolint:gosec
 			if err != nil {
-				return nil, fmt.Errorf("readCoder: Read error: %w", err)
+				return fmt.Errorf("readCoder: Read error: %w", err)
 			}
 
-			return nil, errIncompleteRead
+			return errIncompleteRead
 		}
 	}
 
-	return c, nil
+	return nil
 }
 
 //nolint:cyclop
-func readFolder(r util.Reader) (*folder, error) {
-	f := new(folder)
-
-	coders, err := readUint64(r)
+func readFolder(r util.Reader, f *folder) error {
+	n, err := readUint64(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	f.coder = make([]*coder, coders)
+	if n == 0 || n > maxCoders {
+		return errInvalidFolder
+	}
 
-	for i := uint64(0); i < coders; i++ {
-		if f.coder[i], err = readCoder(r); err != nil {
-			return nil, err
+	// Allocate the coders together
+	coders := make([]coder, n)
+	f.coder = make([]*coder, n)
+
+	for i := range coders {
+		f.coder[i] = &coders[i]
+
+		if err = readCoder(r, f.coder[i]); err != nil {
+			return err
 		}
 
 		f.in += f.coder[i].in
 		f.out += f.coder[i].out
 	}
 
+	// Every output but one is bound to an input, the rest of the inputs
+	// are packed streams
+	if f.out == 0 || f.in > maxCoderStreams || f.out > maxCoderStreams || f.in < f.out-1 {
+		return errInvalidFolder
+	}
+
 	bindPairs := f.out - 1
 
 	f.bindPair = make([]*bindPair, bindPairs)
 
+	// Every input has to be fed by exactly one bind pair or packed stream
+	// and every output can be bound to at most one input, otherwise a
+	// coder would share a stream with another or be left without one. As
+	// there are at most 64 of each, a bit for each is enough to track them
+	var inputs, outputs uint64
+
 	for i := uint64(0); i < bindPairs; i++ {
 		in, err := readUint64(r)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		out, err := readUint64(r)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if in >= f.in || out >= f.out || inputs&(1<<in) != 0 || outputs&(1<<out) != 0 {
+			return errInvalidFolder
 		}
 
+		inputs |= 1 << in
+		outputs |= 1 << out
+
 		f.bindPair[i] = &bindPair{
 			in:  in,
 			out: out,
@@ -297,7 +407,7 @@ func readFolder(r util.Reader) (*folder, error) {
 	f.packedStreams = f.in - bindPairs
 
 	if f.packedStreams == 1 {
-		f.packed = []uint64{}
+		f.packed = make([]uint64, 0, 1)
 		for i := uint64(0); i < f.in; i++ {
 			if f.findInBindPair(i) == nil {
 				f.packed = append(f.packed, i)
@@ -307,16 +417,22 @@ func readFolder(r util.Reader) (*folder, error) {
 		f.packed = make([]uint64, f.packedStreams)
 		for i := uint64(0); i < f.packedStreams; i++ {
 			if f.packed[i], err = readUint64(r); err != nil {
-				return nil, err
+				return err
+			}
+
+			if f.packed[i] >= f.in || inputs&(1<<f.packed[i]) != 0 {
+				return errInvalidFolder
 			}
+
+			inputs |= 1 << f.packed[i]
 		}
 	}
 
-	return f, nil
+	return nil
 }
 
 //nolint:cyclop,funlen
-func readUnpackInfo(r util.Reader) (*unpackInfo, error) {
+func readUnpackInfo(r util.Reader, opts headerOptions) (*unpackInfo, error) {
 	u := new(unpackInfo)
 
 	if id, err := r.ReadByte(); err != nil || id != idFolder {
@@ -332,6 +448,10 @@ func readUnpackInfo(r util.Reader) (*unpackInfo, error) {
 		return nil, err
 	}
 
+	if err := opts.checkEntries(folders); err != nil {
+		return nil, err
+	}
+
 	external, err := r.ReadByte()
 	if err != nil {
 		return nil, fmt.Errorf("readUnpackInfo: ReadByte error: %w", err)
@@ -351,10 +471,15 @@ func readUnpackInfo(r util.Reader) (*unpackInfo, error) {
 		return nil, errors.New("sevenzip: TODO readUnpackInfo external") //nolint:goerr113
 	}
 
+	// Allocate the folders together, non-solid archives have one for
+	// every file
+	f := make([]folder, folders)
 	u.folder = make([]*folder, folders)
 
-	for i := uint64(0); i < folders; i++ {
-		if u.folder[i], err = readFolder(r); err != nil {
+	for i := range f {
+		u.folder[i] = &f[i]
+
+		if err = readFolder(r, u.folder[i]); err != nil {
 			return nil, err
 		}
 	}
@@ -367,13 +492,17 @@ func readUnpackInfo(r util.Reader) (*unpackInfo, error) {
 		return nil, errUnexpectedID
 	}
 
+	var total uint64
 	for _, f := range u.folder {
-		total := uint64(0)
-		for _, c := range f.coder {
-			total += c.out
-		}
+		total += f.out
+	}
 
-		f.size = make([]uint64, total)
+	// Each folder has a size for every coder output, these are sliced
+	// out of one allocation
+	sizes := make([]uint64, total)
+
+	for _, f := range u.folder {
+		f.size, sizes = sizes[:f.out:f.out], sizes[f.out:]
 		for i := range f.size {
 			if f.size[i], err = readUint64(r); err != nil {
 				return nil, err
@@ -405,7 +534,7 @@ func readUnpackInfo(r util.Reader) (*unpackInfo, error) {
 }
 
 //nolint:cyclop,funlen
-func readSubStreamsInfo(r util.Reader, folder []*folder) (*subStreamsInfo, error) {
+func readSubStreamsInfo(r util.Reader, folder []*folder, opts headerOptions) (*subStreamsInfo, error) {
 	s := new(subStreamsInfo)
 
 	id, err := r.ReadByte()
@@ -431,10 +560,19 @@ func readSubStreamsInfo(r util.Reader, folder []*folder) (*subStreamsInfo, error
 		}
 	}
 
-	// Count the files in each stream
+	// Count the files in each stream, checking as it goes so the total
+	// can't overflow
 	files := uint64(0)
 	for _, v := range s.streams {
+		if err := opts.checkEntries(v); err != nil {
+			return nil, err
+		}
+
 		files += v
+
+		if err := opts.checkEntries(files); err != nil {
+			return nil, err
+		}
 	}
 
 	if id == idSize {
@@ -442,6 +580,10 @@ func readSubStreamsInfo(r util.Reader, folder []*folder) (*subStreamsInfo, error
 		k := 0
 
 		for i := range s.streams {
+			if s.streams[i] == 0 {
+				continue
+			}
+
 			total := uint64(0)
 
 			for j := uint64(1); j < s.streams[i]; j++ {
@@ -449,7 +591,10 @@ func readSubStreamsInfo(r util.Reader, folder []*folder) (*subStreamsInfo, error
 					return nil, err
 				}
 
-				total += s.size[k]
+				if total += s.size[k]; total < s.size[k] || total > folder[i].unpackSize() {
+					return nil, errInvalidStreamSizes
+				}
+
 				k++
 			}
 
@@ -464,7 +609,13 @@ func readSubStreamsInfo(r util.Reader, folder []*folder) (*subStreamsInfo, error
 	}
 
 	if id == idCRC {
-		if s.digest, err = readCRC(r, files); err != nil {
+		if opts.skipDigests {
+			err = skipCRC(r, files)
+		} else {
+			s.digest, err = readCRC(r, files)
+		}
+
+		if err != nil {
 			return nil, err
 		}
 
@@ -482,7 +633,7 @@ func readSubStreamsInfo(r util.Reader, folder []*folder) (*subStreamsInfo, error
 }
 
 //nolint:cyclop
-func readStreamsInfo(r util.Reader) (*streamsInfo, error) {
+func readStreamsInfo(r util.Reader, opts headerOptions) (*streamsInfo, error) {
 	s := new(streamsInfo)
 
 	id, err := r.ReadByte()
@@ -491,7 +642,7 @@ func readStreamsInfo(r util.Reader) (*streamsInfo, error) {
 	}
 
 	if id == idPackInfo {
-		if s.packInfo, err = readPackInfo(r); err != nil {
+		if s.packInfo, err = readPackInfo(r, opts); err != nil {
 			return nil, err
 		}
 
@@ -502,7 +653,7 @@ func readStreamsInfo(r util.Reader) (*streamsInfo, error) {
 	}
 
 	if id == idUnpackInfo {
-		if s.unpackInfo, err = readUnpackInfo(r); err != nil {
+		if s.unpackInfo, err = readUnpackInfo(r, opts); err != nil {
 			return nil, err
 		}
 
@@ -517,7 +668,7 @@ func readStreamsInfo(r util.Reader) (*streamsInfo, error) {
 			return nil, errMissingUnpackInfo
 		}
 
-		if s.subStreamsInfo, err = readSubStreamsInfo(r, s.unpackInfo.folder); err != nil {
+		if s.subStreamsInfo, err = readSubStreamsInfo(r, s.unpackInfo.folder, opts); err != nil {
 			return nil, err
 		}
 
@@ -531,6 +682,20 @@ func readStreamsInfo(r util.Reader) (*streamsInfo, error) {
 		return nil, errUnexpectedID
 	}
 
+	// Each folder reads its inputs from consecutive packed streams
+	if s.unpackInfo != nil {
+		var packed uint64
+		for _, f := range s.unpackInfo.folder {
+			packed += f.packedStreams
+		}
+
+		if packed > 0 && (s.packInfo == nil || packed > uint64(len(s.packInfo.size))) {
+			return nil, errMissingPackedStream
+		}
+	}
+
+	s.computeOffsets()
+
 	return s, nil
 }
 
@@ -561,37 +726,53 @@ func readTimes(r util.Reader, count uint64) ([]time.Time, error) {
 
 	times := make([]time.Time, count)
 
+	var b [8]byte
+
 	for i := range defined {
 		if defined[i] {
-			var ft windows.Filetime
-			if err := binary.Read(r, binary.LittleEndian, &ft); err != nil {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
 				return nil, fmt.Errorf("readTimes: Read error: %w", err)
 			}
 
-			times[i] = time.Unix(0, ft.Nanoseconds()).UTC()
+			times[i] = filetimeToTime(windows.Filetime{
+				LowDateTime:  binary.LittleEndian.Uint32(b[:4]),
+				HighDateTime: binary.LittleEndian.Uint32(b[4:]),
+			})
 		}
 	}
 
 	return times, nil
 }
 
-func splitNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
-	}
+// filetimeEpoch is the number of seconds between the FILETIME epoch of
+// 1601-01-01 and the Unix epoch.
+const filetimeEpoch = 11644473600
 
-	if i := bytes.IndexRune(data, rune(0)); i >= 0 {
-		return i + 1, data[0:i], nil
-	}
+// filetimeToTime converts ft to a time.Time without loss of precision. Unlike
+// ft.Nanoseconds() it doesn't overflow for times before 1678 or after 2262,
+// both of which are valid FILETIME values.
+func filetimeToTime(ft windows.Filetime) time.Time {
+	intervals := uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
 
-	if atEOF {
-		return len(data), data, nil
+	return time.Unix(int64(intervals/1e7)-filetimeEpoch, int64(intervals%1e7)*100).UTC() //nolint:gosec
+}
+
+// timeToFiletime is the inverse of filetimeToTime. The zero time.Time, and
+// any time before 1601, returns the zero Filetime.
+func timeToFiletime(t time.Time) windows.Filetime {
+	if t.IsZero() || t.Unix() < -filetimeEpoch {
+		return windows.Filetime{}
 	}
 
-	return
+	intervals := uint64(t.Unix()+filetimeEpoch)*1e7 + uint64(t.Nanosecond()/100) //nolint:gosec
+
+	return windows.Filetime{
+		LowDateTime:  uint32(intervals),       //nolint:gosec
+		HighDateTime: uint32(intervals >> 32), //nolint:gosec
+	}
 }
 
-func readNames(r util.Reader, count, length uint64) ([]string, error) {
+func readNames(r util.Reader, count, length uint64) ([][]byte, error) {
 	external, err := r.ReadByte()
 	if err != nil {
 		return nil, fmt.Errorf("readNames: ReadByte error: %w", err)
@@ -611,27 +792,78 @@ func readNames(r util.Reader, count, length uint64) ([]string, error) {
 		return nil, errors.New("sevenzip: TODO readNames external") //nolint:goerr113
 	}
 
-	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
-	scanner := bufio.NewScanner(transform.NewReader(io.LimitReader(r, int64(length-1)), utf16le.NewDecoder())) //nolint:gosec,lll
-	scanner.Split(splitNull)
+	b, err := io.ReadAll(io.LimitReader(r, int64(length)-1)) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("readNames: ReadAll error: %w", err)
+	}
 
-	names, i := make([]string, 0, count), uint64(0)
-	for scanner.Scan() {
-		names = append(names, scanner.Text())
-		i++
+	if uint64(len(b))+1 != length {
+		return nil, fmt.Errorf("readNames: %w", io.ErrUnexpectedEOF)
 	}
 
-	if err = scanner.Err(); err != nil {
-		return nil, fmt.Errorf("readNames: Scan error: %w", err)
+	if len(b)%2 != 0 {
+		return nil, errInvalidName
 	}
 
-	if i != count {
+	names := make([][]byte, 0, count)
+
+	for len(b) > 0 {
+		i := 0
+		for i < len(b) && (b[i] != 0 || b[i+1] != 0) {
+			i += 2
+		}
+
+		names = append(names, b[:i:i])
+		b = b[min(i+2, len(b)):]
+	}
+
+	if uint64(len(names)) != count {
 		return nil, errWrongNumberOfFilenames
 	}
 
 	return names, nil
 }
 
+// decodeName decodes a UTF-16LE name. Any unpaired surrogates are replaced
+// with U+FFFD, in which case errInvalidName is also returned.
+func decodeName(raw []byte) (string, error) {
+	b, err := appendName(make([]byte, 0, len(raw)/2), raw)
+
+	return string(b), err
+}
+
+// appendName appends the UTF-8 encoding of the UTF-16LE name raw to b, as
+// decodeName, so that one buffer can be reused for many names.
+func appendName(b, raw []byte) ([]byte, error) {
+	var err error
+
+	for i := 0; i+1 < len(raw); i += 2 {
+		r := rune(binary.LittleEndian.Uint16(raw[i:]))
+
+		switch {
+		case r < utf8.RuneSelf:
+			b = append(b, byte(r))
+
+			continue
+		case utf16.IsSurrogate(r):
+			if i+3 < len(raw) {
+				if d := utf16.DecodeRune(r, rune(binary.LittleEndian.Uint16(raw[i+2:]))); d != unicode.ReplacementChar {
+					r = d
+					i += 2
+
+					break
+				}
+			}
+
+			r, err = unicode.ReplacementChar, errInvalidName
+		}
+
+		b = utf8.AppendRune(b, r)
+	}
+
+	return b, err
+}
+
 func readAttributes(r util.Reader, count uint64) ([]uint32, error) {
 	defined, err := readOptionalBool(r, count)
 	if err != nil {
@@ -659,19 +891,123 @@ func readAttributes(r util.Reader, count uint64) ([]uint32, error) {
 
 	attributes := make([]uint32, count)
 
+	var b [4]byte
+
 	for i := range defined {
 		if defined[i] {
-			if err := binary.Read(r, binary.LittleEndian, &attributes[i]); err != nil {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
 				return nil, fmt.Errorf("readAttributes: Read error: %w", err)
 			}
+
+			attributes[i] = binary.LittleEndian.Uint32(b[:])
 		}
 	}
 
 	return attributes, nil
 }
 
+// readSecurityDescriptors reads the kNtSecure property, which stores each
+// distinct NT security descriptor once followed by the index of the
+// descriptor used by each file.
+func readSecurityDescriptors(r util.Reader, count, length uint64) ([]string, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(length))) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("readSecurityDescriptors: ReadAll error: %w", err)
+	}
+
+	if uint64(len(b)) != length {
+		return nil, fmt.Errorf("readSecurityDescriptors: %w", io.ErrUnexpectedEOF)
+	}
+
+	br := bytes.NewReader(b)
+
+	if external, err := br.ReadByte(); err != nil || external != 0 {
+		return nil, errInvalidNtSecure
+	}
+
+	var n uint32
+	if err := binary.Read(br, binary.LittleEndian, &n); err != nil || uint64(n)*4 > uint64(br.Len()) {
+		return nil, errInvalidNtSecure
+	}
+
+	sizes := make([]uint32, n)
+	if err := binary.Read(br, binary.LittleEndian, sizes); err != nil {
+		return nil, errInvalidNtSecure
+	}
+
+	descriptors := make([]string, n)
+
+	for i, size := range sizes {
+		if uint64(size) > uint64(br.Len()) {
+			return nil, errInvalidNtSecure
+		}
+
+		d := make([]byte, size)
+		_, _ = br.Read(d)
+		descriptors[i] = string(d)
+	}
+
+	ids := make([]string, count)
+
+	for i := range ids {
+		id, err := readUint64(br)
+		if err != nil || id >= uint64(n) {
+			return nil, errInvalidNtSecure
+		}
+
+		ids[i] = descriptors[id]
+	}
+
+	return ids, nil
+}
+
+// readStartPos reads the kStartPos property, the position of each file
+// within some larger original. As with the other properties, the positions
+// can be stored in an additional stream instead; as that isn't supported
+// the property is ignored rather than failing to open the archive.
+func readStartPos(r util.Reader, count, length uint64) ([]uint64, []bool, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(length))) //nolint:gosec
+	if err != nil {
+		return nil, nil, fmt.Errorf("readStartPos: ReadAll error: %w", err)
+	}
+
+	if uint64(len(b)) != length {
+		return nil, nil, fmt.Errorf("readStartPos: %w", io.ErrUnexpectedEOF)
+	}
+
+	br := bytes.NewReader(b)
+
+	defined, err := readOptionalBool(br, count)
+	if err != nil {
+		return nil, nil, errInvalidStartPos
+	}
+
+	external, err := br.ReadByte()
+	if err != nil {
+		return nil, nil, errInvalidStartPos
+	}
+
+	if external != 0 {
+		return nil, nil, nil
+	}
+
+	positions := make([]uint64, count)
+
+	for i := range defined {
+		if !defined[i] {
+			continue
+		}
+
+		if err := binary.Read(br, binary.LittleEndian, &positions[i]); err != nil {
+			return nil, nil, errInvalidStartPos
+		}
+	}
+
+	return positions, defined, nil
+}
+
 //nolint:cyclop,funlen,gocognit,gocyclo
-func readFilesInfo(r util.Reader) (*filesInfo, error) {
+func readFilesInfo(r util.Reader, opts headerOptions) (*filesInfo, error) {
 	f := new(filesInfo)
 
 	files, err := readUint64(r)
@@ -679,6 +1015,10 @@ func readFilesInfo(r util.Reader) (*filesInfo, error) {
 		return nil, err
 	}
 
+	if err := opts.checkEntries(files); err != nil {
+		return nil, err
+	}
+
 	f.file = make([]FileHeader, files)
 
 	var emptyStreams uint64
@@ -726,6 +1066,20 @@ func readFilesInfo(r util.Reader) (*filesInfo, error) {
 					j++
 				}
 			}
+		case idAnti:
+			anti, err := readBool(r, emptyStreams)
+			if err != nil {
+				return nil, err
+			}
+
+			j := 0
+
+			for i := range f.file {
+				if f.file[i].isEmptyStream {
+					f.file[i].IsAnti = anti[j]
+					j++
+				}
+			}
 		case idCTime:
 			times, err := readTimes(r, files)
 			if err != nil {
@@ -759,9 +1113,14 @@ func readFilesInfo(r util.Reader) (*filesInfo, error) {
 				return nil, err
 			}
 
+			var b []byte
+
 			for i, n := range names {
-				f.file[i].Name = n
+				b, _ = appendName(b[:0], n)
+				f.file[i].Name = string(b)
 			}
+
+			f.rawName = names
 		case idWinAttributes:
 			attributes, err := readAttributes(r, files)
 			if err != nil {
@@ -771,22 +1130,78 @@ func readFilesInfo(r util.Reader) (*filesInfo, error) {
 			for i, a := range attributes {
 				f.file[i].Attributes = a
 			}
+		case idNtSecure:
+			descriptors, err := readSecurityDescriptors(r, files, length)
+			if err != nil {
+				// 7-Zip ignores the property if it's malformed
+				if !errors.Is(err, errInvalidNtSecure) {
+					return nil, err
+				}
+
+				break
+			}
+
+			for i, d := range descriptors {
+				f.file[i].SecurityDescriptor = d
+			}
 		case idStartPos:
-			return nil, errors.New("sevenzip: TODO idStartPos") //nolint:goerr113
-		case idDummy:
-			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil { //nolint:gosec
-				return nil, fmt.Errorf("readFilesInfo: CopyN error: %w", err)
+			positions, defined, err := readStartPos(r, files, length)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, p := range positions {
+				f.file[i].StartPos = p
+				f.file[i].hasStartPos = defined[i]
 			}
 		default:
-			return nil, errUnexpectedID
+			// kDummy is used by writers to pad the header to an
+			// alignment, anything else is a property this package
+			// doesn't understand; 7-Zip skips both
+			if err := skipData(r, length); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	return f, nil
 }
 
+// skipData discards the next length bytes of the header.
+func skipData(r util.Reader, length uint64) error {
+	if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil { //nolint:gosec
+		return fmt.Errorf("skipData: CopyN error: %w", err)
+	}
+
+	return nil
+}
+
+func readArchiveProperties(r util.Reader) error {
+	for {
+		property, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("readArchiveProperties: ReadByte error: %w", err)
+		}
+
+		if property == idEnd {
+			break
+		}
+
+		length, err := readUint64(r)
+		if err != nil {
+			return err
+		}
+
+		if err := skipData(r, length); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 //nolint:cyclop,funlen
-func readHeader(r util.Reader) (*header, error) {
+func readHeader(r util.Reader, opts headerOptions) (*header, error) {
 	h := new(header)
 
 	id, err := r.ReadByte()
@@ -795,13 +1210,14 @@ func readHeader(r util.Reader) (*header, error) {
 	}
 
 	if id == idArchiveProperties {
-		/*
-			id, err = r.ReadByte()
-			if err != nil {
-				return nil, fmt.Errorf("readHeader: ReadByte error: %w", err)
-			}
-		*/
-		return nil, errors.New("sevenzip: TODO idArchiveProperties") //nolint:goerr113
+		if err := readArchiveProperties(r); err != nil {
+			return nil, err
+		}
+
+		id, err = r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("readHeader: ReadByte error: %w", err)
+		}
 	}
 
 	if id == idAdditionalStreamsInfo {
@@ -815,7 +1231,7 @@ func readHeader(r util.Reader) (*header, error) {
 	}
 
 	if id == idMainStreamsInfo {
-		if h.streamsInfo, err = readStreamsInfo(r); err != nil {
+		if h.streamsInfo, err = readStreamsInfo(r, opts); err != nil {
 			return nil, err
 		}
 
@@ -826,7 +1242,7 @@ func readHeader(r util.Reader) (*header, error) {
 	}
 
 	if id == idFilesInfo {
-		if h.filesInfo, err = readFilesInfo(r); err != nil {
+		if h.filesInfo, err = readFilesInfo(r, opts); err != nil {
 			return nil, err
 		}
 
@@ -840,29 +1256,75 @@ func readHeader(r util.Reader) (*header, error) {
 		return nil, errUnexpectedID
 	}
 
-	if h.streamsInfo == nil || h.filesInfo == nil {
+	if h.filesInfo == nil {
 		return h, nil
 	}
 
-	j := 0
+	if err := h.assignStreams(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// assignStreams sets the stream, size and CRC of each file with a stream by
+// walking the files and streams together, which are in the same order.
+func (h *header) assignStreams() error {
+	var (
+		si     = h.streamsInfo
+		ss     *subStreamsInfo
+		folder int
+		k      uint64 // files seen so far in folder
+		j      int    // files seen so far in all folders
+	)
+
+	// Without any streams, every file has to be empty
+	if si != nil {
+		ss = si.subStreamsInfo
+	}
+
+	// A folder has one file unless the substreams say otherwise
+	files := func(folder int) uint64 {
+		if ss != nil {
+			return ss.streams[folder]
+		}
+
+		return 1
+	}
 
 	for i := range h.filesInfo.file {
-		if h.filesInfo.file[i].isEmptyStream {
+		fh := &h.filesInfo.file[i]
+		if fh.isEmptyStream {
 			continue
 		}
 
-		if h.streamsInfo.subStreamsInfo != nil {
-			h.filesInfo.file[i].CRC32 = h.streamsInfo.subStreamsInfo.digest[j]
+		for folder < si.Folders() && k >= files(folder) {
+			folder, k = folder+1, 0
 		}
 
-		_, h.filesInfo.file[i].UncompressedSize = h.streamsInfo.FileFolderAndSize(j)
+		if folder >= si.Folders() {
+			return errMissingStream
+		}
+
+		fh.Stream = folder
+		fh.UncompressedSize = si.unpackInfo.folder[folder].unpackSize()
+
+		if ss != nil && ss.size != nil {
+			fh.UncompressedSize = ss.size[j]
+		}
+
+		if ss != nil && ss.digest != nil {
+			fh.CRC32 = ss.digest[j]
+		}
+
+		k++
 		j++
 	}
 
-	return h, nil
+	return nil
 }
 
-func readEncodedHeader(r util.Reader) (*header, error) {
+func readEncodedHeader(r util.Reader, opts headerOptions) (*header, error) {
 	if id, err := r.ReadByte(); err != nil || id != idHeader {
 		if err != nil {
 			return nil, fmt.Errorf("readEncodedHeader: ReadByte error: %w", err)
@@ -871,7 +1333,7 @@ func readEncodedHeader(r util.Reader) (*header, error) {
 		return nil, errUnexpectedID
 	}
 
-	header, err := readHeader(r)
+	header, err := readHeader(r, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,541 @@
+package sevenzip
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bodgit/windows"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHeader(t *testing.T) {
+	t.Parallel()
+
+	name := []byte{0x00, 'a', 0x00, 0x00, 0x00}
+	secure := []byte{
+		0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x00,
+		0xaa, 0xbb,
+		0xcc, 0xdd, 0xee,
+		0x01,
+	}
+
+	packInfo := []byte{idPackInfo, 0x00, 0x01, idSize, 0x05, idEnd}
+	unpackInfo := []byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x01, 0x01, 0x00, idCodersUnpackSize, 0x05, idEnd}
+
+	tables := []struct {
+		name       string
+		header     []byte
+		maxEntries uint64
+		anti       bool
+		sd         string
+		startPos   uint64
+		err        error
+	}{
+		{
+			name: "plain",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name: "padding",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idDummy, 0x03, 0x00, 0x00, 0x00},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idDummy, 0x00},
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name: "unknown property",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{0x7f, 0x02, 0xde, 0xad},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name: "archive properties",
+			header: concat(
+				[]byte{idArchiveProperties},
+				[]byte{idDummy, 0x02, 0x00, 0x00},
+				[]byte{0x7f, 0x01, 0xff},
+				[]byte{idEnd},
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name: "anti",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idEmptyFile, 0x01, 0x80},
+				[]byte{idAnti, 0x01, 0x80},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+			anti: true,
+		},
+		{
+			name: "security descriptor",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idNtSecure, byte(len(secure))}, secure,
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+			sd: "\xcc\xdd\xee",
+		},
+		{
+			name: "invalid security descriptor",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idNtSecure, byte(len(secure))}, secure[:len(secure)-1], []byte{0x02},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name: "start position",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idStartPos, 0x0a, 0x01, 0x00, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+			startPos: 0x0102,
+		},
+		{
+			name: "external start position",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idStartPos, 0x03, 0x01, 0x01, 0x00},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name: "truncated start position",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idStartPos, 0x04, 0x01, 0x00, 0x02, 0x01},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+			err: errInvalidStartPos,
+		},
+		{
+			name: "more files than streams",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idPackInfo, 0x00, 0x01, idSize, 0x05, idEnd},
+				[]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x01, 0x01, 0x00, idCodersUnpackSize, 0x05, idEnd},
+				[]byte{idEnd},
+				[]byte{idFilesInfo, 0x02},
+				[]byte{idName, byte(len(name) + 4)}, name, name[1:],
+				[]byte{idEnd, idEnd},
+			),
+			err: errMissingStream,
+		},
+		{
+			name: "files without streams",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+			err: errMissingStream,
+		},
+		{
+			name: "empty folder",
+			header: concat(
+				[]byte{idMainStreamsInfo}, packInfo, unpackInfo,
+				[]byte{idSubStreamsInfo, idNumUnpackStream, 0x00, idSize, idEnd},
+				[]byte{idEnd},
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idName, byte(len(name))}, name,
+				[]byte{idEnd, idEnd},
+			),
+		},
+		{
+			name:   "too many files",
+			header: appendNumber([]byte{idFilesInfo}, defaultMaxHeaderEntries+1),
+			err:    errTooManyEntries,
+		},
+		{
+			name:       "too many files for limit",
+			header:     []byte{idFilesInfo, 0x02},
+			maxEntries: 1,
+			err:        errTooManyEntries,
+		},
+		{
+			name:   "too many packed streams",
+			header: appendNumber([]byte{idMainStreamsInfo, idPackInfo, 0x00}, 1<<62),
+			err:    errTooManyEntries,
+		},
+		{
+			name: "too many substreams",
+			header: concat(
+				[]byte{idMainStreamsInfo}, packInfo, unpackInfo,
+				appendNumber([]byte{idSubStreamsInfo, idNumUnpackStream}, 1<<62),
+				[]byte{idEnd},
+			),
+			err: errTooManyEntries,
+		},
+		{
+			name: "folder without coders",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x00},
+			),
+			err: errInvalidFolder,
+		},
+		{
+			name: "bind pair out of range",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x02, 0x01, 0x00, 0x01, 0x00, 0x05, 0x00},
+			),
+			err: errInvalidFolder,
+		},
+		{
+			name: "input bound twice",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x03, 0x01, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00, 0x02},
+			),
+			err: errInvalidFolder,
+		},
+		{
+			name: "output bound twice",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x03, 0x01, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x01, 0x01},
+			),
+			err: errInvalidFolder,
+		},
+		{
+			name: "bound input also packed",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x02, 0x11, 0x00, 0x03, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x02, 0x03},
+			),
+			err: errInvalidFolder,
+		},
+		{
+			name: "huge coder properties",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				appendNumber([]byte{idUnpackInfo, idFolder, 0x01, 0x00, 0x01, 0x21, 0x00}, 1<<40),
+			),
+			err: errInvalidFolder,
+		},
+		{
+			name: "missing packed stream",
+			header: concat(
+				[]byte{idMainStreamsInfo},
+				[]byte{idPackInfo, 0x00, 0x00, idEnd}, unpackInfo,
+				[]byte{idEnd},
+			),
+			err: errMissingPackedStream,
+		},
+		{
+			name: "stream sizes exceed folder",
+			header: concat(
+				[]byte{idMainStreamsInfo}, packInfo, unpackInfo,
+				[]byte{idSubStreamsInfo, idNumUnpackStream, 0x02, idSize, 0x06, idEnd},
+			),
+			err: errInvalidStreamSizes,
+		},
+		{
+			name: "truncated padding",
+			header: concat(
+				[]byte{idFilesInfo, 0x01},
+				[]byte{idEmptyStream, 0x01, 0x80},
+				[]byte{idDummy, 0x08, 0x00, 0x00},
+			),
+			err: io.EOF,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := readHeader(bufio.NewReader(bytes.NewReader(table.header)), headerOptions{maxEntries: table.maxEntries})
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, h.filesInfo.file, 1)
+			assert.Equal(t, "a", h.filesInfo.file[0].Name)
+			assert.Equal(t, table.anti, h.filesInfo.file[0].IsAnti)
+			assert.Equal(t, table.sd, h.filesInfo.file[0].SecurityDescriptor)
+			assert.Equal(t, table.startPos, h.filesInfo.file[0].StartPos)
+		})
+	}
+}
+
+func FuzzReadHeader(f *testing.F) {
+	name := []byte{0x00, 'a', 0x00, 0x00, 0x00}
+
+	f.Add(concat([]byte{idFilesInfo, 0x01}, []byte{idEmptyStream, 0x01, 0x80}, []byte{idName, byte(len(name))}, name, []byte{idEnd, idEnd}))
+	f.Add(largeHeader(3, true))
+	f.Add(largeHeader(3, false))
+
+	f.Fuzz(func(_ *testing.T, b []byte) {
+		// Anything is allowed except panicking or allocating wildly
+		_, _ = readHeader(bufio.NewReader(bytes.NewReader(b)), headerOptions{maxEntries: 1 << 10})
+	})
+}
+
+func concat(b ...[]byte) []byte {
+	return bytes.Join(b, nil)
+}
+
+func TestFiletime(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		ft   windows.Filetime
+		t    time.Time
+	}{
+		{
+			name: "epoch",
+			ft:   windows.Filetime{LowDateTime: 0xd53e8000, HighDateTime: 0x019db1de},
+			t:    time.Unix(0, 0).UTC(),
+		},
+		{
+			name: "precision",
+			ft:   windows.Filetime{LowDateTime: 0xd53e8001, HighDateTime: 0x019db1de},
+			t:    time.Unix(0, 100).UTC(),
+		},
+		{
+			name: "before 1678",
+			ft:   windows.Filetime{LowDateTime: 1},
+			t:    time.Date(1601, time.January, 1, 0, 0, 0, 100, time.UTC),
+		},
+		{
+			name: "after 2262",
+			ft:   timeToFiletime(time.Date(3000, time.January, 1, 0, 0, 0, 123456700, time.UTC)),
+			t:    time.Date(3000, time.January, 1, 0, 0, 0, 123456700, time.UTC),
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, table.t, filetimeToTime(table.ft))
+			assert.Equal(t, table.ft, timeToFiletime(table.t))
+		})
+	}
+
+	assert.Equal(t, windows.Filetime{}, timeToFiletime(time.Time{}))
+}
+
+func TestDecodeName(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name string
+		raw  []byte
+		s    string
+		err  error
+	}{
+		{
+			name: "empty",
+			s:    "",
+		},
+		{
+			name: "ascii",
+			raw:  []byte{'a', 0x00, 'b', 0x00},
+			s:    "ab",
+		},
+		{
+			name: "surrogate pair",
+			raw:  []byte{0x3d, 0xd8, 0x00, 0xde},
+			s:    "\U0001f600",
+		},
+		{
+			name: "unpaired high surrogate",
+			raw:  []byte{0x3d, 0xd8, 'a', 0x00},
+			s:    "\ufffda",
+			err:  errInvalidName,
+		},
+		{
+			name: "unpaired low surrogate",
+			raw:  []byte{'a', 0x00, 0x00, 0xde},
+			s:    "a\ufffd",
+			err:  errInvalidName,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := decodeName(table.raw)
+			assert.Equal(t, table.s, s)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+// appendNumber appends v in the variable-length encoding used by the header.
+func appendNumber(b []byte, v uint64) []byte {
+	n := 0
+	for n < 8 && v >= 1<<(7*(n+1)) {
+		n++
+	}
+
+	first := byte(0xff << (8 - n))
+	if n < 8 {
+		first |= byte(v >> (8 * n))
+	}
+
+	b = append(b, first)
+
+	for i := 0; i < n; i++ {
+		b = append(b, byte(v>>(8*i)))
+	}
+
+	return b
+}
+
+// largeHeader returns a header for files files, either all in one folder
+// or each in its own.
+func largeHeader(files int, solid bool) []byte {
+	folders := files
+	if solid {
+		folders = 1
+	}
+
+	b := []byte{idMainStreamsInfo, idPackInfo, 0x00}
+	b = appendNumber(b, uint64(folders))
+	b = append(b, idSize)
+
+	for i := 0; i < folders; i++ {
+		b = appendNumber(b, uint64(files/folders)*100)
+	}
+
+	b = append(b, idEnd, idUnpackInfo, idFolder)
+	b = appendNumber(b, uint64(folders))
+	b = append(b, 0x00)
+
+	for i := 0; i < folders; i++ {
+		// One Copy coder
+		b = append(b, 0x01, 0x01, 0x00)
+	}
+
+	b = append(b, idCodersUnpackSize)
+
+	for i := 0; i < folders; i++ {
+		b = appendNumber(b, uint64(files/folders)*100)
+	}
+
+	b = append(b, idEnd, idSubStreamsInfo)
+
+	if solid {
+		b = append(b, idNumUnpackStream)
+		b = appendNumber(b, uint64(files))
+		b = append(b, idSize)
+
+		for i := 1; i < files; i++ {
+			b = appendNumber(b, 100)
+		}
+	}
+
+	b = append(b, idCRC, 0x01)
+	b = append(b, bytes.Repeat([]byte{0xaa, 0xbb, 0xcc, 0xdd}, files)...)
+	b = append(b, idEnd, idEnd, idFilesInfo)
+	b = appendNumber(b, uint64(files))
+
+	var names []byte
+
+	names = append(names, 0x00)
+
+	for i := 0; i < files; i++ {
+		for _, c := range fmt.Sprintf("dir/file%06d.txt", i) {
+			names = append(names, byte(c), 0x00)
+		}
+
+		names = append(names, 0x00, 0x00)
+	}
+
+	b = append(b, idName)
+	b = appendNumber(b, uint64(len(names)))
+	b = append(b, names...)
+
+	b = append(b, idMTime)
+	b = appendNumber(b, uint64(2+8*files))
+	b = append(b, 0x01, 0x00)
+	b = append(b, bytes.Repeat([]byte{0x00, 0x80, 0x3e, 0xd5, 0xde, 0xb1, 0x9d, 0x01}, files)...)
+
+	b = append(b, idWinAttributes)
+	b = appendNumber(b, uint64(2+4*files))
+	b = append(b, 0x01, 0x00)
+	b = append(b, bytes.Repeat([]byte{0x20, 0x00, 0x00, 0x00}, files)...)
+
+	return append(b, idEnd, idEnd)
+}
+
+func BenchmarkReadHeader(b *testing.B) {
+	for _, solid := range []bool{true, false} {
+		solid := solid
+		name := "non-solid"
+
+		if solid {
+			name = "solid"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			header := largeHeader(10000, solid)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				h, err := readHeader(bufio.NewReader(bytes.NewReader(header)), headerOptions{})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if len(h.filesInfo.file) != 10000 {
+					b.Fatal("wrong number of files")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package sevenzip
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+)
+
+// Verify decompresses every file in the archive and checks it against the
+// CRC32 stored for it, without writing it anywhere, like "7z t". Up to
+// workers streams are decompressed concurrently, each hashing its files in
+// order, so verifying a large archive with many streams uses every core. If
+// workers is less than one then [runtime.NumCPU] is used.
+//
+// The first error found is returned, which for a file that doesn't match its
+// CRC32 is a [*ReadError]. Verification stops early if ctx is cancelled.
+func (z *Reader) Verify(ctx context.Context, workers int) error {
+	return z.ExtractConcurrent(ctx, workers, z.verifyFile)
+}
+
+// VerifyAll is like [Reader.Verify] but carries on after a file fails to
+// verify, returning a [Report] of the outcome for every file. The error is
+// only non-nil if ctx is cancelled.
+func (z *Reader) VerifyAll(ctx context.Context, workers int) (*Report, error) {
+	return z.ExtractAll(ctx, workers, z.verifyFile)
+}
+
+func (z *Reader) verifyFile(f *File, r io.Reader) error {
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, r); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return z.checkCRC(f, h.Sum32())
+}
+
+// checkCRC returns a [*ReadError] if sum doesn't match the CRC32 stored for
+// f, if any.
+func (z *Reader) checkCRC(f *File, sum uint32) error {
+	if f.CRC32 != 0 && sum != f.CRC32 {
+		encrypted := z.si.unpackInfo.folder[f.folder].encrypted()
+
+		return newReadError(f, f.folder, encrypted, wrongPassword(errChecksum, encrypted))
+	}
+
+	return nil
+}
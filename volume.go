@@ -0,0 +1,284 @@
+package sevenzip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// A volume is one file of an archive, open while f is non-nil.
+type volume struct {
+	name string
+	size int64
+	f    afero.File
+	refs int
+	used uint64
+}
+
+// volumes is an io.ReaderAt over the concatenated volumes of an archive.
+// Each volume is only opened when it is first read and, if maxOpen is
+// positive, the least recently used idle volume is closed to keep at most
+// maxOpen of them open. Readers wait for a volume to become idle if every
+// open one is in use.
+type volumes struct {
+	fs      afero.Fs
+	mmap    bool
+	maxOpen int
+
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	v      []volume
+	offset []int64 // offset of the end of each volume
+	open   int
+	clock  uint64
+	closed bool
+
+	modTime []time.Time // set by stamp
+}
+
+func newVolumes(fs afero.Fs, mmap bool, maxOpen int) *volumes {
+	vs := &volumes{
+		fs:      fs,
+		mmap:    mmap,
+		maxOpen: maxOpen,
+	}
+	vs.cond = sync.NewCond(&vs.mutex)
+
+	return vs
+}
+
+// add appends a volume of the given size, f is nil if it isn't open yet.
+func (vs *volumes) add(name string, size int64, f afero.File) {
+	vs.v = append(vs.v, volume{name: name, size: size, f: f})
+	vs.offset = append(vs.offset, vs.Size()+size)
+
+	if f != nil {
+		vs.open++
+	}
+}
+
+// Size returns the total size of the volumes.
+func (vs *volumes) Size() int64 {
+	if len(vs.offset) == 0 {
+		return 0
+	}
+
+	return vs.offset[len(vs.offset)-1]
+}
+
+// Names returns the names of the volumes.
+func (vs *volumes) Names() []string {
+	names := make([]string, len(vs.v))
+	for i := range vs.v {
+		names[i] = vs.v[i].name
+	}
+
+	return names
+}
+
+// evict closes the least recently used idle volume, reporting whether there
+// was one.
+func (vs *volumes) evict() (bool, error) {
+	lru := -1
+
+	for i := range vs.v {
+		if vs.v[i].f != nil && vs.v[i].refs == 0 && (lru < 0 || vs.v[i].used < vs.v[lru].used) {
+			lru = i
+		}
+	}
+
+	if lru < 0 {
+		return false, nil
+	}
+
+	err := vs.v[lru].f.Close()
+	vs.v[lru].f = nil
+	vs.open--
+
+	return true, err //nolint:wrapcheck
+}
+
+// acquire returns volume i, opening it if necessary. It must be released
+// with release once it has been read.
+func (vs *volumes) acquire(i int) (afero.File, error) {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	v := &vs.v[i]
+
+	for v.f == nil && vs.maxOpen > 0 && vs.open >= vs.maxOpen && !vs.closed {
+		ok, err := vs.evict()
+		if err != nil {
+			return nil, fmt.Errorf("sevenzip: error closing: %w", err)
+		}
+
+		if !ok {
+			vs.cond.Wait()
+		}
+	}
+
+	if vs.closed {
+		return nil, iofs.ErrClosed
+	}
+
+	if v.f == nil {
+		f, err := vs.fs.Open(v.name)
+		if err != nil {
+			return nil, fmt.Errorf("sevenzip: error opening: %w", err)
+		}
+
+		if vs.mmap {
+			if f, err = mapFile(f, v.size); err != nil {
+				return nil, err
+			}
+		}
+
+		v.f = f
+		vs.open++
+	}
+
+	vs.clock++
+	v.refs++
+	v.used = vs.clock
+
+	return v.f, nil
+}
+
+func (vs *volumes) release(i int) {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	vs.v[i].refs--
+	vs.cond.Broadcast()
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (vs *volumes) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	// Find the first volume that ends after off
+	i := sort.Search(len(vs.offset), func(i int) bool {
+		return vs.offset[i] > off
+	})
+
+	var n int
+
+	for ; len(p) > 0 && i < len(vs.v); i++ {
+		start := vs.offset[i] - vs.v[i].size
+		b := p[:min(int64(len(p)), vs.offset[i]-off)]
+
+		f, err := vs.acquire(i)
+		if err != nil {
+			return n, err
+		}
+
+		m, err := f.ReadAt(b, off-start)
+		vs.release(i)
+
+		n += m
+		off += int64(m)
+		p = p[m:]
+
+		if err != nil && !(errors.Is(err, io.EOF) && m == len(b)) {
+			return n, err //nolint:wrapcheck
+		}
+	}
+
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close closes every open volume, after which they can't be read.
+func (vs *volumes) Close() error {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	errs := make([]error, 0, vs.open)
+
+	for i := range vs.v {
+		if vs.v[i].f != nil {
+			errs = append(errs, vs.v[i].f.Close())
+			vs.v[i].f = nil
+		}
+	}
+
+	vs.open = 0
+	vs.closed = true
+	vs.cond.Broadcast()
+
+	return errors.Join(errs...)
+}
+
+// Sizes returns the size of each volume.
+func (vs *volumes) Sizes() []int64 {
+	sizes := make([]int64, len(vs.v))
+	for i := range vs.v {
+		sizes[i] = vs.v[i].size
+	}
+
+	return sizes
+}
+
+// span returns the index of each volume holding some of the n bytes at off.
+func (vs *volumes) span(off, n int64) []int {
+	if n <= 0 {
+		return nil
+	}
+
+	i := sort.Search(len(vs.offset), func(i int) bool {
+		return vs.offset[i] > off
+	})
+
+	var indexes []int
+
+	for ; i < len(vs.v) && vs.offset[i]-vs.v[i].size < off+n; i++ {
+		indexes = append(indexes, i)
+	}
+
+	return indexes
+}
+
+// stamp records the modification time of each volume so that changed can
+// tell if any of them are modified afterwards.
+func (vs *volumes) stamp() error {
+	vs.modTime = make([]time.Time, len(vs.v))
+
+	for i := range vs.v {
+		info, err := vs.fs.Stat(vs.v[i].name)
+		if err != nil {
+			return fmt.Errorf("sevenzip: error retrieving file info: %w", err)
+		}
+
+		vs.modTime[i] = info.ModTime()
+	}
+
+	return nil
+}
+
+// changed returns ErrArchiveChanged if the size or modification time of any
+// volume is different to when stamp was called.
+func (vs *volumes) changed() error {
+	for i := range vs.modTime {
+		info, err := vs.fs.Stat(vs.v[i].name)
+		if err != nil {
+			return fmt.Errorf("sevenzip: error retrieving file info: %w", err)
+		}
+
+		if info.Size() != vs.v[i].size || !info.ModTime().Equal(vs.modTime[i]) {
+			return ErrArchiveChanged
+		}
+	}
+
+	return nil
+}